@@ -107,7 +107,7 @@ function displayNotes(notes) {
 
 function formatNote(noteData) {
   // Check if it's a cnotes-style note
-  if (noteData.session_id && noteData.conversation_excerpt) {
+  if (noteData.session_id && (noteData.entries || noteData.conversation_excerpt)) {
     return formatCnotesNote(noteData);
   }
   
@@ -143,13 +143,21 @@ function formatCnotesNote(data) {
     html += '</div>';
   }
   
-  // Conversation excerpt
-  if (data.conversation_excerpt) {
+  // Each session's conversation; older notes had one, at the top level
+  const entries = data.entries || [data];
+  entries.forEach(entry => {
+    if (!entry.conversation_excerpt) {
+      return;
+    }
     html += '<div class="conversation-section">';
-    html += '<strong>Conversation:</strong>';
+    if (entries.length > 1) {
+      html += `<strong>Session ${escapeHtml(entry.session_id.substring(0, 8))}...:</strong>`;
+    } else {
+      html += '<strong>Conversation:</strong>';
+    }
     html += '<div class="conversation-excerpt">';
     
-    let formatted = escapeHtml(data.conversation_excerpt);
+    let formatted = escapeHtml(entry.conversation_excerpt);
     formatted = formatted.replace(/👤 User:|🧑 User:/g, '<strong class="user">$&</strong>');
     formatted = formatted.replace(/🤖 Claude:/g, '<strong class="assistant">$&</strong>');
     formatted = formatted.replace(/Tool \(([^)]+)\):/g, '<em class="tool">Tool ($1):</em>');
@@ -157,7 +165,7 @@ function formatCnotesNote(data) {
     
     html += formatted;
     html += '</div></div>';
-  }
+  });
   
   html += '</div>';
   return html;
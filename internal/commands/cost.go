@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/imjasonh/cnotes/internal/config"
+	"github.com/imjasonh/cnotes/internal/notes"
+	"github.com/spf13/cobra"
+)
+
+var costCmd = &cobra.Command{
+	Use:   "cost [range]",
+	Short: "Report token usage and estimated cost of AI-assisted commits",
+	Long: `Sums the token usage recorded in conversation notes and reports it per
+commit, per session and per author, with the cost estimated from the pricing
+table in .claude/notes.json.
+
+A range such as main..feature limits the report to those commits; without one
+every commit with a conversation note is included.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		cfg := config.LoadNotesConfig(".")
+		notesManager := notes.NewNotesManager(".")
+		notesManager.SetNotesRef(cfg.NotesRef)
+
+		noted, err := readNotes(ctx, notesManager, args)
+		if err != nil {
+			return err
+		}
+
+		if len(noted) == 0 {
+			fmt.Println("No conversation notes found.")
+			return nil
+		}
+
+		commits := make([]string, 0, len(noted))
+		for commit, note := range noted {
+			estimateMissingCosts(&note, cfg)
+			noted[commit] = note
+			commits = append(commits, commit)
+		}
+
+		infos, err := notesManager.CommitInfos(ctx, commits)
+		if err != nil {
+			return err
+		}
+
+		printCostReport(notes.BuildCostReport(noted, infos))
+		return nil
+	},
+}
+
+// readNotes returns the notes of the commits in a revision range, the only
+// argument if there is one, or every note if there isn't
+func readNotes(ctx context.Context, notesManager *notes.NotesManager, args []string) (map[string]notes.ConversationNote, error) {
+	if len(args) == 0 {
+		backup, err := notesManager.BackupAllNotes(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list notes: %w", err)
+		}
+		return backup.Notes, nil
+	}
+
+	commits, err := notesManager.RevList(ctx, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return map[string]notes.ConversationNote{}, nil
+	}
+
+	return notesManager.GetConversationNotes(ctx, commits)
+}
+
+// estimateMissingCosts prices usage recorded without a cost, e.g. for a model
+// that had no pricing when the note was written
+func estimateMissingCosts(note *notes.ConversationNote, cfg *config.NotesConfig) {
+	estimate := func(models []notes.ModelUsage) {
+		for i, usage := range models {
+			if usage.EstimatedCostUSD != 0 || usage.TotalTokens() == 0 {
+				continue
+			}
+			if pricing, ok := cfg.PricingFor(usage.Model); ok {
+				models[i].EstimatedCostUSD = pricing.Cost(usage.InputTokens, usage.OutputTokens, usage.CacheReadTokens, usage.CacheCreationTokens)
+			}
+		}
+	}
+
+	// Copy before filling in so the notes map's slices aren't shared
+	note.Models = append([]notes.ModelUsage(nil), note.Models...)
+	estimate(note.Models)
+
+	note.Entries = append([]notes.SessionEntry(nil), note.Entries...)
+	for i := range note.Entries {
+		note.Entries[i].Models = append([]notes.ModelUsage(nil), note.Entries[i].Models...)
+		estimate(note.Entries[i].Models)
+	}
+
+	note.Amendments = append([]notes.Amendment(nil), note.Amendments...)
+	for i := range note.Amendments {
+		note.Amendments[i].Models = append([]notes.ModelUsage(nil), note.Amendments[i].Models...)
+		estimate(note.Amendments[i].Models)
+	}
+}
+
+// printCostReport prints the per-commit, per-session and per-author tables
+func printCostReport(report notes.CostReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "By commit:\n")
+	for _, line := range report.Commits {
+		fmt.Fprintf(w, "  %s\t%s\t%s tokens\t%s\n", shortHash(line.Key), formatCost(line.Usage.EstimatedCostUSD), formatTokens(line.Usage.TotalTokens()), line.Label)
+	}
+
+	fmt.Fprintf(w, "\nBy session:\n")
+	for _, line := range report.Sessions {
+		fmt.Fprintf(w, "  %s\t%s\t%s tokens\t%s\n", line.Key, formatCost(line.Usage.EstimatedCostUSD), formatTokens(line.Usage.TotalTokens()), formatCommitCount(line.Commits))
+	}
+
+	fmt.Fprintf(w, "\nBy author:\n")
+	for _, line := range report.Authors {
+		fmt.Fprintf(w, "  %s\t%s\t%s tokens\t%s\n", line.Key, formatCost(line.Usage.EstimatedCostUSD), formatTokens(line.Usage.TotalTokens()), formatCommitCount(line.Commits))
+	}
+
+	w.Flush()
+
+	total := report.Total
+	fmt.Printf("\nTotal: %s across %s (%s input, %s output, %s cache read, %s cache write tokens)\n",
+		formatCost(total.EstimatedCostUSD), formatCommitCount(len(report.Commits)),
+		formatTokens(total.InputTokens), formatTokens(total.OutputTokens),
+		formatTokens(total.CacheReadTokens), formatTokens(total.CacheCreationTokens))
+	fmt.Printf("💡 Costs are estimates; override model pricing in .claude/notes.json\n")
+}
+
+// formatUsage summarizes a note's token usage and estimated cost on one line
+func formatUsage(usage notes.ModelUsage) string {
+	return fmt.Sprintf("%s tokens (%s input, %s output, %s cache read, %s cache write), about %s",
+		formatTokens(usage.TotalTokens()), formatTokens(usage.InputTokens), formatTokens(usage.OutputTokens),
+		formatTokens(usage.CacheReadTokens), formatTokens(usage.CacheCreationTokens), formatCost(usage.EstimatedCostUSD))
+}
+
+func formatCost(usd float64) string {
+	return fmt.Sprintf("$%.2f", usd)
+}
+
+// formatTokens formats a token count with thousands separators
+func formatTokens(n int) string {
+	digits := fmt.Sprintf("%d", n)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+func formatCommitCount(n int) string {
+	if n == 1 {
+		return "1 commit"
+	}
+	return fmt.Sprintf("%d commits", n)
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+	return hash
+}
+
+func init() {
+	rootCmd.AddCommand(costCmd)
+}
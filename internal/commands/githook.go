@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/imjasonh/cnotes/internal/config"
+	"github.com/imjasonh/cnotes/internal/notes"
+	"github.com/imjasonh/cnotes/internal/redact"
+	"github.com/spf13/cobra"
+)
+
+var gitHookCmd = &cobra.Command{
+	Use:    "git-hook",
+	Short:  "Handlers called from git hooks installed by cnotes",
+	Hidden: true,
+}
+
+var postRewriteCmd = &cobra.Command{
+	Use:   "post-rewrite [amend|rebase]",
+	Short: "Carry conversation notes over to rewritten commits",
+	Long: `Called by git's post-rewrite hook after git commit --amend and git rebase.
+Reads git's old→new commit mapping on stdin and moves each rewritten commit's
+conversation note to its replacement. Notes of commits squashed together are
+merged into a single note.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		mappings, err := notes.ParseRewriteMappings(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		cfg := config.LoadNotesConfig(".")
+		notesManager := notes.NewNotesManager(".")
+		notesManager.SetNotesRef(cfg.NotesRef)
+		notesManager.SetRedactor(redact.FromConfig(cfg))
+
+		written, err := notesManager.RewriteNotes(ctx, mappings)
+		if err != nil {
+			return fmt.Errorf("failed to rewrite notes: %w", err)
+		}
+
+		slog.Debug("rewrote conversation notes", "written", written, "mappings", len(mappings))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(gitHookCmd)
+	gitHookCmd.AddCommand(postRewriteCmd)
+}
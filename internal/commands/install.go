@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/imjasonh/cnotes/internal/config"
+	"github.com/imjasonh/cnotes/internal/notes"
 	"github.com/spf13/cobra"
 )
 
@@ -26,10 +28,12 @@ Use --local for local directory settings (./.claude/settings.json).
 
 This command will:
 1. Find or create the appropriate settings.json file
-2. Add cnotes to handle PostToolUse events for Bash commands containing git commits
-3. Configure git to preserve notes during rebases
+2. Register cnotes for SessionStart, UserPromptSubmit, PreToolUse and PostToolUse
+   (Bash), Stop, SubagentStop and PreCompact events
+3. Configure git to preserve notes during rebases and amends (notes.rewriteRef)
+4. Install a git post-rewrite hook that merges the notes of squashed commits
 
-Use --uninstall to remove cnotes from Claude settings.`,
+Use --uninstall to remove cnotes from Claude settings and undo the git configuration.`,
 		RunE: runInstall,
 	}
 )
@@ -43,6 +47,8 @@ func init() {
 }
 
 func runInstall(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
 	executable, err := os.Executable()
 	if err != nil {
 		return fmt.Errorf("failed to get executable path: %w", err)
@@ -102,6 +108,12 @@ func runInstall(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to uninstall hooks: %w", err)
 		}
 		fmt.Printf("✓ cnotes uninstalled successfully from %s settings\n", scope)
+
+		if err := uninstallGitIntegration(ctx); err != nil {
+			fmt.Printf("⚠️  Could not remove git integration: %v\n", err)
+		} else {
+			fmt.Printf("✓ Removed notes rewrite configuration and post-rewrite hook\n")
+		}
 		return nil
 	}
 
@@ -110,22 +122,53 @@ func runInstall(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to install hooks: %w", err)
 	}
 
+	gitStatus := "  • Notes follow commits through git commit --amend and git rebase\n"
+	if err := installGitIntegration(ctx, executable); err != nil {
+		gitStatus = fmt.Sprintf("  • ⚠️  Could not configure git to preserve notes: %v\n", err)
+	}
+
 	fmt.Printf(`✓ cnotes installed successfully to %s settings
   Binary: %s
   Settings: %s
 
 What cnotes does:
-  • Monitors git commit commands executed through Claude
+  • Records Claude Code hook events in a per-session journal under .git/cnotes
+  • Detects every commit created by commands executed through Claude
   • Automatically captures conversation context in git notes
   • Includes user prompts and tool interactions since last commit
   • Scans all transcript files in the project for cross-session context
 
 Git notes configuration:
-  • Notes ref: claude-conversations
+  • Notes ref: %s
   • Use 'cnotes show' to view conversation notes for commits
   • Use 'cnotes list' to see all commits with notes
   • Use 'cnotes backup/restore' to manage your notes
-`, scope, executable, settingsPath)
+%s`, scope, executable, settingsPath, config.LoadNotesConfig(".").NotesRef, gitStatus)
 
 	return nil
 }
+
+// installGitIntegration configures git to carry notes over rewritten commits
+// and installs the post-rewrite hook that merges notes of squashed commits
+func installGitIntegration(ctx context.Context, executable string) error {
+	cfg := config.LoadNotesConfig(".")
+	notesManager := notes.NewNotesManager(".")
+	notesManager.SetNotesRef(cfg.NotesRef)
+
+	if err := notesManager.ConfigureNotesRewrite(ctx); err != nil {
+		return err
+	}
+
+	return notesManager.InstallPostRewriteHook(ctx, executable)
+}
+
+// uninstallGitIntegration reverses installGitIntegration
+func uninstallGitIntegration(ctx context.Context) error {
+	cfg := config.LoadNotesConfig(".")
+	notesManager := notes.NewNotesManager(".")
+	notesManager.SetNotesRef(cfg.NotesRef)
+
+	notesManager.UnconfigureNotesRewrite(ctx)
+
+	return notesManager.UninstallPostRewriteHook(ctx)
+}
@@ -2,12 +2,17 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"html"
 	"os/exec"
+	"sort"
 	"strings"
 
 	"github.com/imjasonh/cnotes/internal/config"
+	conv "github.com/imjasonh/cnotes/internal/context"
 	"github.com/imjasonh/cnotes/internal/notes"
+	"github.com/imjasonh/cnotes/internal/redact"
 	"github.com/spf13/cobra"
 )
 
@@ -19,7 +24,9 @@ If no filename is provided, creates a timestamped backup file.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
+		cfg := config.LoadNotesConfig(".")
 		notesManager := notes.NewNotesManager(".")
+		notesManager.SetNotesRef(cfg.NotesRef)
 
 		var filename string
 		if len(args) > 0 {
@@ -42,6 +49,12 @@ If no filename is provided, creates a timestamped backup file.`,
 		}
 
 		fmt.Printf("✅ Backed up %d conversation notes to %s\n", len(backup.Notes), filename)
+		if len(backup.Skipped) > 0 {
+			fmt.Printf("⚠️  Left out %d notes that aren't conversation notes:\n", len(backup.Skipped))
+			for _, commit := range backup.Skipped {
+				fmt.Printf("   %s\n", shortHash(commit))
+			}
+		}
 		return nil
 	},
 }
@@ -50,11 +63,18 @@ var restoreCmd = &cobra.Command{
 	Use:   "restore <filename>",
 	Short: "Restore conversation notes from a backup file",
 	Long: `Restores conversation notes from a previously created backup file.
-Only restores notes for commits that still exist and don't already have notes.`,
+Only restores notes for commits that still exist. A backed up note for a commit
+that already has one is merged into it, adding the session entries the commit's
+note doesn't have yet.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
+		cfg := config.LoadNotesConfig(".")
 		notesManager := notes.NewNotesManager(".")
+		notesManager.SetNotesRef(cfg.NotesRef)
+
+		// Restored notes are redacted like any other note cnotes writes
+		notesManager.SetRedactor(redact.FromConfig(cfg))
 
 		filename := args[0]
 		backup, err := notesManager.LoadBackupFromFile(filename)
@@ -73,15 +93,51 @@ Only restores notes for commits that still exist and don't already have notes.`,
 	},
 }
 
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade conversation notes to the current note format",
+	Long: fmt.Sprintf(`Rewrites every conversation note written by an older cnotes to the current
+note format (schema version %d), in a single commit on the notes ref.
+
+cnotes reads older notes without migrating them, so this is only needed to
+share the upgraded notes, e.g. with tools that expect the current format.
+Notes written by a newer cnotes can't be migrated; upgrade cnotes instead.`, notes.CurrentSchemaVersion),
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		cfg := config.LoadNotesConfig(".")
+		notesManager := notes.NewNotesManager(".")
+		notesManager.SetNotesRef(cfg.NotesRef)
+
+		// Migrated notes are redacted like any other note cnotes writes
+		notesManager.SetRedactor(redact.FromConfig(cfg))
+
+		migrated, err := notesManager.MigrateNotes(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to migrate notes: %w", err)
+		}
+		if migrated == 0 {
+			fmt.Printf("All conversation notes are already at schema version %d.\n", notes.CurrentSchemaVersion)
+			return nil
+		}
+
+		fmt.Printf("✅ Migrated %d conversation notes to schema version %d\n", migrated, notes.CurrentSchemaVersion)
+		return nil
+	},
+}
+
 var showCmd = &cobra.Command{
 	Use:   "show [commit]",
 	Short: "Show conversation notes for a commit in Markdown format",
 	Long: `Pretty-prints the conversation context for a commit in readable Markdown format.
 If no commit is specified, shows notes for HEAD.`,
-	Args: cobra.MaximumNArgs(1),
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true, // A git failure isn't a usage error
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
+		cfg := config.LoadNotesConfig(".")
 		notesManager := notes.NewNotesManager(".")
+		notesManager.SetNotesRef(cfg.NotesRef)
 
 		// Default to HEAD if no commit specified
 		commit := "HEAD"
@@ -91,6 +147,9 @@ If no commit is specified, shows notes for HEAD.`,
 
 		// Get the conversation note
 		note, err := notesManager.GetConversationNote(ctx, commit)
+		if errors.Is(err, notes.ErrUnknownRevision) {
+			return fmt.Errorf("unknown commit %s", commit)
+		}
 		if err != nil {
 			return fmt.Errorf("failed to get conversation note: %w", err)
 		}
@@ -102,19 +161,22 @@ If no commit is specified, shows notes for HEAD.`,
 		}
 
 		// Pretty-print in Markdown format
-		cfg := config.LoadNotesConfig(".")
+		estimateMissingCosts(note, cfg)
 		printConversationMarkdown(*note, commit, cfg)
 		return nil
 	},
 }
 
 var listCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List all commits with conversation notes",
-	Long:  `Shows all commits that have conversation notes attached.`,
+	Use:          "list",
+	Short:        "List all commits with conversation notes",
+	Long:         `Shows all commits that have conversation notes attached.`,
+	SilenceUsage: true, // A git failure isn't a usage error
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
+		cfg := config.LoadNotesConfig(".")
 		notesManager := notes.NewNotesManager(".")
+		notesManager.SetNotesRef(cfg.NotesRef)
 
 		backup, err := notesManager.BackupAllNotes(ctx)
 		if err != nil {
@@ -126,11 +188,41 @@ var listCmd = &cobra.Command{
 			return nil
 		}
 
+		commits := make([]string, 0, len(backup.Notes))
+		for commitHash := range backup.Notes {
+			commits = append(commits, commitHash)
+		}
+		// Newest first
+		sort.Slice(commits, func(i, j int) bool {
+			return backup.Notes[commits[i]].Timestamp.After(backup.Notes[commits[j]].Timestamp)
+		})
+
+		// Get commit subjects
+		infos, err := notesManager.CommitInfos(ctx, commits)
+		if err != nil {
+			return err
+		}
+
 		fmt.Printf("Found %d commits with conversation notes:\n\n", len(backup.Notes))
-		for commitHash, note := range backup.Notes {
-			// Get commit subject
-			fmt.Printf("• %s (%s)\n", commitHash[:8], note.Timestamp.Format("2006-01-02 15:04"))
-			fmt.Printf("  Session: %s\n", note.SessionID)
+		for _, commitHash := range commits {
+			note := backup.Notes[commitHash]
+			estimateMissingCosts(&note, cfg)
+			fmt.Printf("• %s %s (%s)\n", commitHash[:8], infos[commitHash].Subject, note.Timestamp.Format("2006-01-02 15:04"))
+			if len(note.Entries) > 1 {
+				var sessions []string
+				for _, entry := range note.Entries {
+					sessions = append(sessions, entry.SessionID)
+				}
+				fmt.Printf("  Sessions: %s\n", strings.Join(sessions, ", "))
+			} else {
+				fmt.Printf("  Session: %s\n", note.SessionID)
+			}
+			if models := formatModels(note.Models, note.ClaudeVersion); models != "" {
+				fmt.Printf("  Models: %s\n", models)
+			}
+			if usage := note.TotalUsage(); usage.TotalTokens() > 0 {
+				fmt.Printf("  Cost: %s (%s tokens)\n", formatCost(usage.EstimatedCostUSD), formatTokens(usage.TotalTokens()))
+			}
 			fmt.Printf("  Tools: %v\n\n", note.ToolsUsed)
 		}
 
@@ -149,22 +241,215 @@ func printConversationMarkdown(note notes.ConversationNote, commit string, cfg *
 	}
 
 	fmt.Printf("**Session ID:** `%s`\n", note.SessionID)
+	if len(note.Sessions) > 1 {
+		fmt.Printf("**Sessions:** `%s`\n", strings.Join(note.Sessions, "`, `"))
+	}
 	fmt.Printf("**Timestamp:** %s\n", note.Timestamp.Format("2006-01-02 15:04:05 MST"))
-	fmt.Printf("**Claude Version:** %s\n", note.ClaudeVersion)
+	if models := formatModels(note.Models, note.ClaudeVersion); models != "" {
+		fmt.Printf("**Models:** %s\n", models)
+	}
+	if usage := note.TotalUsage(); usage.TotalTokens() > 0 {
+		fmt.Printf("**Usage:** %s\n", formatUsage(usage))
+	}
+	if note.PrivacyLevel != "" {
+		fmt.Printf("**Privacy:** %s\n", formatPrivacyLevel(note.PrivacyLevel))
+	}
+	if len(note.Redactions) > 0 {
+		fmt.Printf("**Redacted:** %s\n", formatRedactions(note.Redactions))
+	}
 	fmt.Printf("**Tools Used:** %s\n\n", strings.Join(note.ToolsUsed, ", "))
 
-	// Conversation transcript
-	if note.ConversationExcerpt != "" {
-		fmt.Printf("## Conversation Transcript\n\n")
-		// Clean up and format the conversation excerpt for better readability
-		formatted := formatConversationExcerpt(note.ConversationExcerpt, cfg)
-		fmt.Printf("%s\n\n", formatted)
+	// Each session's conversation, in the order they contributed
+	for _, entry := range note.Entries {
+		fmt.Printf("## Session `%s`\n\n", entry.SessionID)
+		if len(note.Entries) > 1 {
+			if len(entry.Sessions) > 1 {
+				fmt.Printf("**Sessions:** `%s`\n", strings.Join(entry.Sessions, "`, `"))
+			}
+			fmt.Printf("**Timestamp:** %s\n", entry.Timestamp.Format("2006-01-02 15:04:05 MST"))
+			if models := formatModels(entry.Models, ""); models != "" {
+				fmt.Printf("**Models:** %s\n", models)
+			}
+			if entry.PrivacyLevel != "" && entry.PrivacyLevel != note.PrivacyLevel {
+				fmt.Printf("**Privacy:** %s\n", formatPrivacyLevel(entry.PrivacyLevel))
+			}
+			fmt.Printf("**Tools Used:** %s\n\n", strings.Join(entry.ToolsUsed, ", "))
+		}
+
+		rest := entry.Subagents
+		if entry.ConversationExcerpt != "" || len(entry.Events) > 0 {
+			var formatted string
+			formatted, rest = formatConversation(entry.ConversationExcerpt, entry.Events, entry.Excluded, entry.Subagents, cfg)
+			fmt.Printf("%s\n\n", formatted)
+		}
+
+		// Subagents whose Task isn't in the excerpt, e.g. because it started before the previous commit
+		if len(rest) > 0 {
+			fmt.Printf("### Subagents\n\n")
+			for _, subagent := range rest {
+				fmt.Printf("%s\n\n", formatSubagent(subagent, cfg))
+			}
+		}
+	}
+
+	// Conversation from later amends of the commit, oldest first
+	for i, amendment := range note.Amendments {
+		fmt.Printf("## Amendment %d\n\n", i+1)
+		if amendment.AmendedCommit != "" {
+			fmt.Printf("**Amended Commit:** `%s`\n", amendment.AmendedCommit)
+		}
+		fmt.Printf("**Timestamp:** %s\n", amendment.Timestamp.Format("2006-01-02 15:04:05 MST"))
+		if amendment.PrivacyLevel != "" && amendment.PrivacyLevel != note.PrivacyLevel {
+			fmt.Printf("**Privacy:** %s\n", formatPrivacyLevel(amendment.PrivacyLevel))
+		}
+		fmt.Println()
+		if amendment.ConversationExcerpt != "" || len(amendment.Events) > 0 {
+			formatted, rest := formatConversation(amendment.ConversationExcerpt, amendment.Events, amendment.Excluded, amendment.Subagents, cfg)
+			fmt.Printf("%s\n\n", formatted)
+			for _, subagent := range rest {
+				fmt.Printf("%s\n\n", formatSubagent(subagent, cfg))
+			}
+		}
 	}
 
 	fmt.Printf("---\n")
 	fmt.Printf("💡 *Generated by `cnotes`*\n")
 }
 
+// formatModels lists the models that worked on a commit with their message
+// counts, or the version older notes recorded instead
+func formatModels(models []notes.ModelUsage, claudeVersion string) string {
+	if len(models) == 0 {
+		// Older notes only recorded a single, often hardcoded, version
+		return claudeVersion
+	}
+
+	var parts []string
+	for _, usage := range models {
+		unit := "messages"
+		if usage.Messages == 1 {
+			unit = "message"
+		}
+		parts = append(parts, fmt.Sprintf("%s (%d %s)", usage.Model, usage.Messages, unit))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatPrivacyLevel says what a note's privacy level deliberately left out
+func formatPrivacyLevel(level string) string {
+	switch level {
+	case config.PrivacyMinimal:
+		return "minimal (only prompts and file paths were recorded)"
+	case config.PrivacyStandard:
+		return "standard (Claude's reasoning, and tool output unless configured, were not recorded)"
+	case config.PrivacyFull:
+		return "full (tool output and Claude's reasoning were recorded)"
+	}
+	return level
+}
+
+// formatRedactions lists the rules that redacted secrets from a note with
+// how many each redacted
+func formatRedactions(redactions map[string]int) string {
+	rules := make([]string, 0, len(redactions))
+	for rule := range redactions {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+
+	var parts []string
+	for _, rule := range rules {
+		parts = append(parts, fmt.Sprintf("%s (%d)", rule, redactions[rule]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatConversation renders a conversation as Markdown, from its events if
+// the note stored them and otherwise, for older notes, from its excerpt, with
+// each subagent under the Task tool use that started it. It returns the
+// subagents it found no Task for.
+func formatConversation(excerpt string, events []notes.Event, excluded map[string]int, subagents []notes.Subagent, cfg *config.NotesConfig) (string, []notes.Subagent) {
+	if len(events) == 0 {
+		// Clean up and format the conversation excerpt for better readability
+		return nestSubagents(formatConversationExcerpt(excerpt, cfg), subagents, cfg)
+	}
+
+	userEmoji, assistantEmoji := "👤", "🤖"
+	if cfg != nil && cfg.UserEmoji != "" {
+		userEmoji = cfg.UserEmoji
+	}
+	if cfg != nil && cfg.AssistantEmoji != "" {
+		assistantEmoji = cfg.AssistantEmoji
+	}
+
+	var blocks []string
+	if summary := conv.ExcludedSummary(excluded); summary != "" {
+		blocks = append(blocks, "_"+summary+"_")
+	}
+
+	placed := make([]bool, len(subagents))
+	for _, event := range events {
+		var lines []string
+		switch event.Role {
+		case notes.RoleUser:
+			lines = append(lines, fmt.Sprintf("**%s User:** %s", userEmoji, event.Text))
+		case notes.RoleAssistant:
+			lines = append(lines, fmt.Sprintf("%s Claude: %s", assistantEmoji, event.Text))
+		case notes.RoleThinking:
+			lines = append(lines, "💭 _Thinking:_ "+event.Text)
+		case notes.RoleElided:
+			lines = append(lines, fmt.Sprintf("_[%d events elided]_", event.Elided))
+		case notes.RoleTool:
+			if event.Input != "" {
+				lines = append(lines, fmt.Sprintf("Tool (%s):", event.Tool))
+				lines = append(lines, codeBlock(event.Input)...)
+			}
+			if event.Result != "" {
+				label := "Result"
+				if event.IsError {
+					label = "Error"
+				}
+				lines = append(lines, "_"+label+":_")
+				lines = append(lines, codeBlock(event.Result)...)
+			}
+
+			// Nest the conversation of the subagent the Task started
+			if event.Tool != "Task" && event.Tool != "Agent" {
+				break
+			}
+			for j, subagent := range subagents {
+				if !placed[j] && (subagent.Description == "" || subagent.Description == event.Input) {
+					placed[j] = true
+					lines = append(lines, "", formatSubagent(subagent, cfg))
+					break
+				}
+			}
+		}
+		if len(lines) > 0 {
+			blocks = append(blocks, strings.Join(lines, "\n"))
+		}
+	}
+
+	var rest []notes.Subagent
+	for j, subagent := range subagents {
+		if !placed[j] {
+			rest = append(rest, subagent)
+		}
+	}
+
+	return strings.Join(blocks, "\n\n"), rest
+}
+
+// codeBlock fences text as a Markdown code block, with a fence longer than any
+// run of backticks in it
+func codeBlock(text string) []string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return []string{fence, text, fence}
+}
+
 // formatConversationExcerpt cleans up the conversation excerpt for better readability
 func formatConversationExcerpt(excerpt string, cfg *config.NotesConfig) string {
 	// Replace escaped newlines with actual newlines
@@ -217,7 +502,7 @@ func formatConversationExcerpt(excerpt string, cfg *config.NotesConfig) string {
 				formattedLines = append(formattedLines, line)
 			}
 
-		case strings.HasPrefix(line, "Result:"):
+		case strings.HasPrefix(line, "Result:") || strings.HasPrefix(line, "Error:"):
 			// Format results in code blocks
 			resultParts := strings.SplitN(line, ": ", 2)
 			if len(resultParts) == 2 {
@@ -238,6 +523,78 @@ func formatConversationExcerpt(excerpt string, cfg *config.NotesConfig) string {
 	return strings.Join(formattedLines, "\n")
 }
 
+// nestSubagents places each subagent's conversation under the Task tool use
+// that started it in a formatted excerpt, matching Tasks by description in
+// order. It returns the subagents it found no Task for.
+func nestSubagents(formatted string, subagents []notes.Subagent, cfg *config.NotesConfig) (string, []notes.Subagent) {
+	if len(subagents) == 0 {
+		return formatted, nil
+	}
+
+	lines := strings.Split(formatted, "\n")
+	placed := make([]bool, len(subagents))
+	var nested []string
+	for i := 0; i < len(lines); i++ {
+		nested = append(nested, lines[i])
+
+		// A Task tool use is formatted as its label and a one-line code block
+		if !strings.HasPrefix(lines[i], "Tool (Task):") && !strings.HasPrefix(lines[i], "Tool (Agent):") {
+			continue
+		}
+		if i+3 >= len(lines) || lines[i+1] != "```" || lines[i+3] != "```" {
+			continue
+		}
+		description := lines[i+2]
+		nested = append(nested, lines[i+1:i+4]...)
+		i += 3
+
+		for j, subagent := range subagents {
+			if !placed[j] && (subagent.Description == "" || subagent.Description == description) {
+				placed[j] = true
+				nested = append(nested, "", formatSubagent(subagent, cfg))
+				break
+			}
+		}
+	}
+
+	var rest []notes.Subagent
+	for j, subagent := range subagents {
+		if !placed[j] {
+			rest = append(rest, subagent)
+		}
+	}
+
+	return strings.Join(nested, "\n"), rest
+}
+
+// formatSubagent renders a subagent's prompt, conversation and result as a
+// collapsible section
+func formatSubagent(subagent notes.Subagent, cfg *config.NotesConfig) string {
+	title := "Subagent"
+	if subagent.Type != "" {
+		title += fmt.Sprintf(" (%s)", subagent.Type)
+	}
+	if subagent.Description != "" {
+		title += ": " + subagent.Description
+	}
+
+	var parts []string
+	parts = append(parts, "<details>", fmt.Sprintf("<summary>%s</summary>", html.EscapeString(title)), "")
+	if subagent.Prompt != "" {
+		parts = append(parts, "**Prompt:** "+subagent.Prompt, "")
+	}
+	if subagent.ConversationExcerpt != "" || len(subagent.Events) > 0 {
+		formatted, _ := formatConversation(subagent.ConversationExcerpt, subagent.Events, nil, nil, cfg)
+		parts = append(parts, formatted, "")
+	}
+	if subagent.Result != "" {
+		parts = append(parts, "_Reported back:_", "```", subagent.Result, "```", "")
+	}
+	parts = append(parts, "</details>")
+
+	return strings.Join(parts, "\n")
+}
+
 // getCommitInfo returns formatted commit information
 func getCommitInfo(commit string) string {
 	cmd := exec.Command("git", "log", "--oneline", "-1", commit)
@@ -251,6 +608,7 @@ func getCommitInfo(commit string) string {
 func init() {
 	rootCmd.AddCommand(backupCmd)
 	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(migrateCmd)
 	rootCmd.AddCommand(showCmd)
 	rootCmd.AddCommand(listCmd)
 }
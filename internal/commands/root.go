@@ -3,17 +3,20 @@ package commands
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/imjasonh/cnotes/internal/config"
 	conv "github.com/imjasonh/cnotes/internal/context"
+	"github.com/imjasonh/cnotes/internal/journal"
 	"github.com/imjasonh/cnotes/internal/notes"
+	"github.com/imjasonh/cnotes/internal/redact"
 	"github.com/spf13/cobra"
 )
 
@@ -24,7 +27,8 @@ var (
 		Short: "Git notes for Claude conversations",
 		Long: `cnotes automatically captures Claude conversation context in git notes.
 		
-When called by Claude Code hooks, it detects git commit commands and attaches
+When called by Claude Code hooks, it records each hook event in a per-session
+journal under .git/cnotes, detects git commit commands and attaches
 conversation context as git notes for easy reference later.`,
 		RunE: runHook,
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
@@ -44,8 +48,13 @@ type HookInput struct {
 	CWD            string          `json:"cwd"`
 	HookEventName  string          `json:"hook_event_name"`
 	ToolName       string          `json:"tool_name,omitempty"`
+	ToolUseID      string          `json:"tool_use_id,omitempty"`
 	ToolInput      json.RawMessage `json:"tool_input,omitempty"`
 	ToolResponse   json.RawMessage `json:"tool_response,omitempty"`
+	Prompt         string          `json:"prompt,omitempty"`           // UserPromptSubmit
+	Source         string          `json:"source,omitempty"`           // SessionStart
+	Trigger        string          `json:"trigger,omitempty"`          // PreCompact
+	StopHookActive bool            `json:"stop_hook_active,omitempty"` // Stop, SubagentStop
 }
 
 // BashToolInput represents bash tool parameters
@@ -78,10 +87,29 @@ func runHook(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to parse input: %w", err)
 	}
 
-	// Only handle PostToolUse events for Bash commands
-	if input.HookEventName != "PostToolUse" || input.ToolName != "Bash" {
-		// For all other events, just approve
-		return writeOutput(HookOutput{Decision: "approve"})
+	// Load configuration
+	cfg := config.LoadNotesConfig(input.CWD)
+	if !cfg.Enabled {
+		return writeOutput(hookResponse(input))
+	}
+
+	// Everything cnotes records lives in the repository's git directory
+	gitDir, err := notes.NewNotesManager(input.CWD).GitCommonDir(ctx)
+	if err != nil || input.SessionID == "" {
+		// Not in a git repository, nothing to record
+		return writeOutput(hookResponse(input))
+	}
+	sessionJournal := journal.New(gitDir)
+
+	// Record every hook event in the session journal
+	if err := recordJournalEntry(sessionJournal, input); err != nil {
+		slog.Error("failed to record journal entry", "event", input.HookEventName, "error", err)
+		// Don't fail the hook, just log the error
+	}
+
+	// Only Bash tool calls can create commits
+	if input.ToolName != "Bash" {
+		return writeOutput(hookResponse(input))
 	}
 
 	// Extract bash command
@@ -90,73 +118,386 @@ func runHook(cmd *cobra.Command, args []string) error {
 		return writeOutput(HookOutput{Decision: "approve"})
 	}
 
-	// Check if this is a git commit command
-	if !isGitCommitCommand(bashInput.Command) {
+	switch input.HookEventName {
+	case "PreToolUse":
+		// Remember where HEAD was so PostToolUse can tell whether the command committed
+		if err := recordMarker(ctx, sessionJournal, input, bashInput); err != nil {
+			slog.Error("failed to record marker", "error", err)
+		}
 		return writeOutput(HookOutput{Decision: "approve"})
+
+	case "PostToolUse":
+		marker, err := sessionJournal.TakeMarker(input.SessionID, input.ToolUseID)
+		if err != nil {
+			slog.Debug("failed to read marker", "error", err)
+		}
+
+		// Find every commit the command created, whatever the command text was
+		commits, err := findNewCommits(ctx, input, bashInput, marker)
+		if err != nil {
+			slog.Error("failed to identify new commits", "error", err)
+		}
+		if len(commits) == 0 {
+			return writeOutput(HookOutput{Decision: "approve"})
+		}
+
+		// Process the git commits and attach notes
+		if err := processGitCommit(ctx, input, bashInput, commits); err != nil {
+			slog.Error("failed to process git commit", "error", err)
+			// Don't fail the hook, just log the error
+		}
 	}
 
-	// Load configuration
-	cfg := config.LoadNotesConfig(input.CWD)
-	if !cfg.Enabled {
-		return writeOutput(HookOutput{Decision: "approve"})
+	return writeOutput(HookOutput{Decision: "approve"})
+}
+
+// hookResponse returns the default response for an event. Tool events are
+// approved; other events get an empty response since "approve" isn't a valid
+// decision for them and plain output would be added to Claude's context.
+func hookResponse(input HookInput) HookOutput {
+	switch input.HookEventName {
+	case "PreToolUse", "PostToolUse":
+		return HookOutput{Decision: "approve"}
+	default:
+		return HookOutput{}
 	}
+}
 
-	// Process the git commit and attach notes
-	if err := processGitCommit(ctx, input, bashInput); err != nil {
-		slog.Error("failed to process git commit", "error", err)
-		// Don't fail the hook, just log the error
+// recordJournalEntry appends the hook input to the session journal under .git/cnotes
+func recordJournalEntry(sessionJournal *journal.Journal, input HookInput) error {
+	return sessionJournal.Append(journal.Entry{
+		Timestamp:      time.Now(),
+		Event:          input.HookEventName,
+		SessionID:      input.SessionID,
+		ToolUseID:      input.ToolUseID,
+		TranscriptPath: input.TranscriptPath,
+		Prompt:         input.Prompt,
+		Source:         input.Source,
+		Trigger:        input.Trigger,
+		ToolName:       input.ToolName,
+		ToolInput:      input.ToolInput,
+		ToolResponse:   input.ToolResponse,
+		StopHookActive: input.StopHookActive,
+	})
+}
+
+// recordMarker captures HEAD and its reflog position before a Bash command runs
+func recordMarker(ctx context.Context, sessionJournal *journal.Journal, input HookInput, bashInput BashToolInput) error {
+	notesManager := notes.NewNotesManager(input.CWD)
+
+	// HEAD doesn't resolve on an unborn branch; an empty marker head still
+	// tells PostToolUse that any commit it finds is new
+	head, _ := notesManager.ResolveCommit(ctx, "HEAD")
+
+	reflogCount, err := notesManager.HeadReflogCount(ctx)
+	if err != nil {
+		return err
 	}
 
-	return writeOutput(HookOutput{Decision: "approve"})
+	return sessionJournal.SaveMarker(journal.Marker{
+		Timestamp:   time.Now(),
+		SessionID:   input.SessionID,
+		ToolUseID:   input.ToolUseID,
+		Command:     bashInput.Command,
+		Head:        head,
+		ReflogCount: reflogCount,
+	})
 }
 
-func processGitCommit(ctx context.Context, input HookInput, bashInput BashToolInput) error {
-	// Extract git output from tool response
-	var gitOutput string
-	if len(input.ToolResponse) > 0 {
-		var toolResponse struct {
-			Stdout string `json:"stdout"`
+// findNewCommits returns the commits a Bash command created, oldest first. With
+// a marker it compares the HEAD reflog from before and after the command; without
+// one it falls back to recognising git commit commands and their output.
+func findNewCommits(ctx context.Context, input HookInput, bashInput BashToolInput, marker *journal.Marker) ([]notes.ReflogEntry, error) {
+	notesManager := notes.NewNotesManager(input.CWD)
+
+	if marker != nil {
+		commits, err := notesManager.CommitsCreatedSince(ctx, marker.ReflogCount)
+		if err != nil {
+			return nil, err
 		}
-		if err := json.Unmarshal(input.ToolResponse, &toolResponse); err == nil {
-			gitOutput = toolResponse.Stdout
+		if len(commits) > 0 || !notes.IsGitCommitCommand(bashInput.Command) {
+			return commits, nil
 		}
+		// The reflog may be disabled (core.logAllRefUpdates=false); fall through
+	} else if !notes.IsGitCommitCommand(bashInput.Command) {
+		return nil, nil
 	}
 
-	if gitOutput == "" {
-		return fmt.Errorf("no git output found")
+	commitHash, err := identifyCommit(ctx, notesManager, marker, bashOutput(input))
+	if errors.Is(err, notes.ErrNoNewCommit) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// Extract commit hash
-	commitHash := extractCommitHash(gitOutput)
-	if commitHash == "" {
-		return fmt.Errorf("could not extract commit hash")
+	// Use the reflog entry when there is one so amends are still recognised
+	if entries, err := notesManager.HeadReflog(ctx, 2); err == nil && len(entries) > 0 && entries[0].Hash == commitHash {
+		return entries[:1], nil
 	}
 
+	return []notes.ReflogEntry{{Hash: commitHash}}, nil
+}
+
+// bashOutput extracts stdout from a Bash tool response
+func bashOutput(input HookInput) string {
+	if len(input.ToolResponse) == 0 {
+		return ""
+	}
+
+	var toolResponse struct {
+		Stdout string `json:"stdout"`
+	}
+	if err := json.Unmarshal(input.ToolResponse, &toolResponse); err != nil {
+		return ""
+	}
+
+	return toolResponse.Stdout
+}
+
+// commitConversation is the slice of conversation that led to a commit
+type commitConversation struct {
+	context   *conv.ConversationContext
+	excerpt   string
+	events    []notes.Event
+	toolsUsed []string
+	models    []notes.ModelUsage
+	subagents []notes.Subagent
+	privacy   string // The privacy level it was captured at
+}
+
+func processGitCommit(ctx context.Context, input HookInput, bashInput BashToolInput, commits []notes.ReflogEntry) error {
+	gitOutput := bashOutput(input)
+
 	// Create notes manager and load config
 	notesManager := notes.NewNotesManager(input.CWD)
 	cfg := config.LoadNotesConfig(input.CWD)
 	notesManager.SetNotesRef(cfg.NotesRef)
+	notesManager.SetRedactor(redact.FromConfig(cfg))
 
-	// Check if note already exists
-	if notesManager.HasConversationNote(ctx, commitHash) {
-		return nil
+	// Each commit gets the conversation since the session's previous commit
+	// in this worktree, whichever branch that commit is on
+	gitDir, err := notesManager.GitCommonDir(ctx)
+	if err != nil {
+		return err
+	}
+	sessionJournal := journal.New(gitDir)
+
+	worktree, err := notesManager.WorktreeRoot(ctx)
+	if err != nil {
+		worktree = input.CWD
 	}
 
-	// Get the last event time from the previous commit to avoid duplicates
-	lastEventTime := getLastEventTimeFromPreviousCommit(ctx, notesManager)
+	cursor, err := sessionJournal.LoadCursor(input.SessionID, worktree)
+	if err != nil {
+		slog.Debug("failed to load session cursor", "error", err)
+	}
+
+	// Other sessions can only be credited with, and the relevance filter only
+	// keeps conversation about, files the commits changed
+	var commitFiles []string
+	if cfg.Attribution == config.AttributionFiles || cfg.Filter == config.FilterRelevance {
+		for _, commit := range commits {
+			files, err := notesManager.CommitFiles(ctx, commit.Hash)
+			if err != nil {
+				slog.Debug("failed to list committed files", "commit", commit.Hash, "error", err)
+				continue
+			}
+			for _, file := range files {
+				commitFiles = append(commitFiles, filepath.Join(worktree, file))
+			}
+		}
+	}
 
 	// Small delay to ensure transcript is written
 	time.Sleep(100 * time.Millisecond)
 
-	// Extract conversation context since the last event
+	// Every commit the command created or amended shares the conversation
+	// that led to it, extracted once
+	var shared *commitConversation
+	extract := func() (*commitConversation, error) {
+		if shared == nil {
+			var err error
+			shared, err = extractConversation(ctx, input, cfg, notesManager, cursor, commitFiles)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return shared, nil
+	}
+
+	// Where the conversation attributed so far ends
+	next := journal.Cursor{SessionID: input.SessionID, Worktree: worktree, ToolUseID: input.ToolUseID}
+	if cursor != nil {
+		next.Timestamp = cursor.Timestamp
+	}
+	attributed := false
+
+	for _, commit := range commits {
+		if commit.IsAmend() {
+			amended, lastEventTime, err := processAmend(ctx, input, bashInput, notesManager, extract, commit, gitOutput)
+			if err != nil {
+				return err
+			}
+			if amended {
+				if lastEventTime.After(next.Timestamp) {
+					next.Timestamp = lastEventTime
+				}
+				next.Commit = commit.Hash
+				attributed = true
+				continue
+			}
+			// No note to carry over, so annotate it like a new commit
+		}
+
+		conversation, err := extract()
+		if err != nil {
+			return err
+		}
+
+		// Create conversation note, to be merged into any note another
+		// session already gave the commit
+		var note notes.ConversationNote
+		note.AddEntry(sessionEntry(input, conversation, buildCommitContext(bashInput.Command, commit, gitOutput)))
+		note.AddRedactions(conversation.context.Redactions)
+
+		// Add the note
+		if err := notesManager.AddConversationNote(ctx, commit.Hash, note); err != nil {
+			return fmt.Errorf("failed to add conversation note: %w", err)
+		}
+
+		slog.Info("attached conversation context to commit",
+			"commit", commit.Hash,
+			"session_id", input.SessionID)
+
+		if conversation.context.LastEventTime.After(next.Timestamp) {
+			next.Timestamp = conversation.context.LastEventTime
+		}
+		next.Commit = commit.Hash
+		attributed = true
+	}
+
+	if !attributed {
+		return nil
+	}
+
+	// Without any events to go by, everything up to now was attributed
+	if next.Timestamp.IsZero() {
+		next.Timestamp = time.Now()
+	}
+	if err := sessionJournal.SaveCursor(next); err != nil {
+		return fmt.Errorf("failed to save session cursor: %w", err)
+	}
+
+	return nil
+}
+
+// processAmend carries the note from the pre-amend commit over to the amended
+// one and appends the session's conversation since its previous commit as an
+// amendment. It reports false if neither commit has a note to carry over, and
+// otherwise the time of the last event the amendment carries. The
+// conversation is only extracted if there is a note to amend.
+func processAmend(ctx context.Context, input HookInput, bashInput BashToolInput, notesManager *notes.NotesManager, extract func() (*commitConversation, error), commit notes.ReflogEntry, gitOutput string) (bool, time.Time, error) {
+	// git may already have copied the note if notes.rewrite.amend is configured
+	note, err := notesManager.GetConversationNote(ctx, commit.Hash)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("failed to read amended commit's note: %w", err)
+	}
+	if note == nil && commit.Previous != "" {
+		note, err = notesManager.GetConversationNote(ctx, commit.Previous)
+		if err != nil {
+			return false, time.Time{}, fmt.Errorf("failed to read pre-amend note: %w", err)
+		}
+	}
+	if note == nil {
+		return false, time.Time{}, nil
+	}
+
+	conversation, err := extract()
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	note.AddAmendment(notes.Amendment{
+		AmendedCommit: commit.Previous,
+		SessionEntry:  sessionEntry(input, conversation, buildCommitContext(bashInput.Command, commit, gitOutput)),
+	})
+	note.AddRedactions(conversation.context.Redactions)
+
+	if err := notesManager.MoveConversationNote(ctx, commit.Previous, commit.Hash, *note); err != nil {
+		return false, time.Time{}, fmt.Errorf("failed to move amended note: %w", err)
+	}
+
+	slog.Info("carried conversation context across amend",
+		"from", commit.Previous,
+		"to", commit.Hash,
+		"session_id", input.SessionID)
+
+	return true, conversation.context.LastEventTime, nil
+}
+
+// sessionEntry records the session's conversation that led to a commit
+func sessionEntry(input HookInput, conversation *commitConversation, commitContext string) notes.SessionEntry {
+	return notes.SessionEntry{
+		SessionID:           input.SessionID,
+		Sessions:            conversation.context.Sessions,
+		Timestamp:           time.Now(),
+		ConversationExcerpt: conversation.excerpt,
+		Events:              conversation.events,
+		Excluded:            conversation.context.Excluded,
+		ToolsUsed:           conversation.toolsUsed,
+		CommitContext:       commitContext,
+		Models:              conversation.models,
+		LastEventTime:       conversation.context.LastEventTime,
+		PrivacyLevel:        conversation.privacy,
+		Subagents:           conversation.subagents,
+	}
+}
+
+// extractConversation extracts the session's conversation after its cursor
+// from the transcripts, falling back to the session journal. commitFiles are
+// the absolute paths the commits changed, for the files attribution policy and
+// the relevance filter.
+func extractConversation(ctx context.Context, input HookInput, cfg *config.NotesConfig, notesManager *notes.NotesManager, cursor *journal.Cursor, commitFiles []string) (*commitConversation, error) {
+	gitDir, err := notesManager.GitCommonDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sessionJournal := journal.New(gitDir)
+
+	// Extract conversation context since the session's previous commit,
+	// reading each transcript only from where the previous extraction left off
 	contextExtractor := conv.NewContextExtractor(cfg)
-	conversationContext, err := contextExtractor.ExtractContextSince(input.TranscriptPath, input.SessionID, lastEventTime)
+	checkpoints := sessionJournal.LoadCheckpoints()
+	contextExtractor.UseCheckpoints(checkpoints)
+	contextExtractor.UseCommitFiles(commitFiles)
+	conversationContext, err := contextExtractor.ExtractContextAfter(input.TranscriptPath, input.SessionID, cursor)
 	if err != nil {
-		return fmt.Errorf("failed to extract conversation context: %w", err)
+		return nil, fmt.Errorf("failed to extract conversation context: %w", err)
 	}
 
-	// Create conversation excerpt
-	excerpt := contextExtractor.CreateExcerpt(conversationContext)
+	if err := sessionJournal.SaveCheckpoints(checkpoints); err != nil {
+		slog.Debug("failed to save transcript checkpoints", "error", err)
+	}
+
+	if len(conversationContext.FormatWarnings) > 0 {
+		first := conversationContext.FormatWarnings[0]
+		slog.Warn("transcript format has changed; run 'cnotes transcript lint' for details",
+			"warnings", len(conversationContext.FormatWarnings), "first", first.String())
+	}
+
+	// Fall back to the hook data we journaled ourselves when the transcript has nothing
+	if len(conversationContext.Events) == 0 {
+		entries, err := sessionJournal.After(input.SessionID, cursor)
+		if err != nil {
+			slog.Debug("failed to read session journal", "error", err)
+		} else {
+			conversationContext = contextExtractor.ExtractContextFromJournal(entries, time.Time{})
+		}
+	}
+	if len(conversationContext.Sessions) == 0 {
+		conversationContext.Sessions = []string{input.SessionID}
+	}
 
 	// Collect tools used
 	toolsUsed := []string{"Bash"}
@@ -166,62 +507,116 @@ func processGitCommit(ctx context.Context, input HookInput, bashInput BashToolIn
 		}
 	}
 
-	// Create conversation note
-	note := notes.ConversationNote{
-		SessionID:           input.SessionID,
-		Timestamp:           time.Now(),
-		ConversationExcerpt: excerpt,
-		ToolsUsed:           toolsUsed,
-		CommitContext:       buildCommitContext(bashInput.Command, gitOutput),
-		ClaudeVersion:       "claude-sonnet-4-20250514",
-		LastEventTime:       conversationContext.LastEventTime,
-	}
+	return &commitConversation{
+		context:   conversationContext,
+		excerpt:   contextExtractor.CreateExcerpt(conversationContext),
+		events:    noteEvents(contextExtractor.ExcerptEvents(conversationContext)),
+		toolsUsed: toolsUsed,
+		models:    modelUsage(conversationContext.Models, cfg),
+		subagents: subagentNotes(contextExtractor, conversationContext.Subagents),
+		privacy:   contextExtractor.PrivacyLevel(),
+	}, nil
+}
 
-	// Add the note
-	if err := notesManager.AddConversationNote(ctx, commitHash, note); err != nil {
-		return fmt.Errorf("failed to add conversation note: %w", err)
+// subagentNotes converts subagent threads into the note's subagents, each
+// with an excerpt of its own conversation
+func subagentNotes(contextExtractor *conv.ContextExtractor, threads []conv.SubagentThread) []notes.Subagent {
+	var subagents []notes.Subagent
+	for _, thread := range threads {
+		subagents = append(subagents, notes.Subagent{
+			ToolUseID:           thread.ToolUseID,
+			AgentID:             thread.AgentID,
+			Type:                thread.Type,
+			Description:         thread.Description,
+			Prompt:              thread.Prompt,
+			ConversationExcerpt: contextExtractor.CreateExcerpt(&conv.ConversationContext{Events: thread.Events}),
+			Events:              noteEvents(contextExtractor.ExcerptEvents(&conv.ConversationContext{Events: thread.Events})),
+			Result:              thread.Result,
+		})
 	}
+	return subagents
+}
 
-	slog.Info("attached conversation context to commit",
-		"commit", commitHash,
-		"session_id", input.SessionID)
+// noteEvents converts the events an excerpt shows into the note's events
+func noteEvents(excerptEvents []conv.ExcerptEvent) []notes.Event {
+	var events []notes.Event
+	for _, event := range excerptEvents {
+		if event.Elided > 0 {
+			events = append(events, notes.Event{Role: notes.RoleElided, Elided: event.Elided})
+			continue
+		}
 
-	return nil
+		converted := notes.Event{ID: event.ID, Timestamp: event.Timestamp, IsError: event.IsError}
+		switch event.Type {
+		case "user", "assistant", "thinking":
+			converted.Role = event.Type
+			converted.Text = event.Content
+		case "tool":
+			converted.Role = notes.RoleTool
+			converted.Tool = event.ToolName
+			converted.Input = event.Content
+			converted.Result = event.Result
+		case "tool_result":
+			// Output whose tool use the excerpt doesn't show
+			converted.Role = notes.RoleTool
+			converted.Tool = event.ToolName
+			converted.Result = event.Content
+		default:
+			continue
+		}
+		events = append(events, converted)
+	}
+	return events
 }
 
-func isGitCommitCommand(command string) bool {
-	command = strings.TrimSpace(command)
-	patterns := []string{"git commit"}
-	for _, pattern := range patterns {
-		if strings.Contains(command, pattern) {
-			return true
+// modelUsage converts per-model stats into the note's usage list, estimating
+// each model's cost from the configured pricing
+func modelUsage(models map[string]conv.ModelStats, cfg *config.NotesConfig) []notes.ModelUsage {
+	var usage []notes.ModelUsage
+	for model, stats := range models {
+		entry := notes.ModelUsage{
+			Model:               model,
+			Messages:            stats.Messages,
+			InputTokens:         stats.InputTokens,
+			OutputTokens:        stats.OutputTokens,
+			CacheReadTokens:     stats.CacheReadTokens,
+			CacheCreationTokens: stats.CacheCreationTokens,
+		}
+		if pricing, ok := cfg.PricingFor(model); ok {
+			entry.EstimatedCostUSD = pricing.Cost(stats.InputTokens, stats.OutputTokens, stats.CacheReadTokens, stats.CacheCreationTokens)
 		}
+		usage = append(usage, entry)
 	}
-	return false
+	return notes.SortModelUsage(usage)
 }
 
-func extractCommitHash(output string) string {
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "[") && strings.Contains(line, "]") {
-			parts := strings.Split(line, "]")
-			if len(parts) > 0 {
-				beforeBracket := strings.TrimSpace(strings.TrimPrefix(parts[0], "["))
-				hashParts := strings.Split(beforeBracket, " ")
-				if len(hashParts) > 1 {
-					return hashParts[1]
-				}
-			}
+// identifyCommit resolves the full object ID of the commit a Bash command created
+func identifyCommit(ctx context.Context, notesManager *notes.NotesManager, marker *journal.Marker, gitOutput string) (string, error) {
+	if marker == nil {
+		return notesManager.IdentifyCommit(ctx, "", gitOutput)
+	}
+
+	if marker.Head == "" {
+		// The branch was unborn before the command, so any HEAD is new
+		if head, err := notesManager.ResolveCommit(ctx, "HEAD"); err == nil {
+			return head, nil
 		}
 	}
-	return ""
+
+	return notesManager.IdentifyCommit(ctx, marker.Head, gitOutput)
 }
 
-func buildCommitContext(command, output string) string {
+func buildCommitContext(command string, commit notes.ReflogEntry, output string) string {
 	var parts []string
 	parts = append(parts, fmt.Sprintf("Git command: %s", command))
 
+	// Prefer the reflog entry, which names the commit even when the
+	// command created several or printed nothing
+	if commit.Subject != "" {
+		parts = append(parts, fmt.Sprintf("Result: [%s] %s", commit.Hash[:7], commit.Subject))
+		return strings.Join(parts, "\n")
+	}
+
 	lines := strings.Split(output, "\n")
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
@@ -246,37 +641,6 @@ func contains(slice []string, item string) bool {
 	return false
 }
 
-// getLastEventTimeFromPreviousCommit gets the last processed event time from the previous commit
-func getLastEventTimeFromPreviousCommit(ctx context.Context, notesManager *notes.NotesManager) time.Time {
-	// Try to get the previous commit's note
-	note, err := notesManager.GetConversationNote(ctx, "HEAD~1")
-	if err != nil || note == nil {
-		// No previous commit or note, use a time buffer approach
-		cmd := exec.Command("git", "log", "-1", "--format=%cI", "HEAD~1")
-		output, err := cmd.Output()
-		if err != nil {
-			return time.Time{}
-		}
-
-		timeStr := strings.TrimSpace(string(output))
-		commitTime, err := time.Parse(time.RFC3339, timeStr)
-		if err != nil {
-			return time.Time{}
-		}
-
-		// Use a 60 second buffer for the first commit in a session
-		return commitTime.Add(-60 * time.Second)
-	}
-
-	// If we have a previous note with LastEventTime, use it
-	if !note.LastEventTime.IsZero() {
-		return note.LastEventTime
-	}
-
-	// Fall back to commit time with buffer
-	return note.Timestamp.Add(-60 * time.Second)
-}
-
 // readStdinWithTimeout reads from stdin with a timeout
 func readStdinWithTimeout(timeout time.Duration) ([]byte, error) {
 	type result struct {
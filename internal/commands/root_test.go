@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imjasonh/cnotes/internal/notes"
+)
+
+func TestProcessGitCommitAmendAndNewCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		if _, err := (&notes.RealGitExecutor{}).Execute(ctx, dir, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+	git("init", "-q")
+	git("config", "user.name", "Test")
+	git("config", "user.email", "test@example.com")
+
+	notesManager := notes.NewNotesManager(dir)
+	git("commit", "-q", "--allow-empty", "-m", "First commit")
+	first, err := notesManager.ResolveCommit(ctx, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var note notes.ConversationNote
+	note.AddEntry(notes.SessionEntry{SessionID: "s1", Timestamp: time.Now().Add(-time.Hour)})
+	if err := notesManager.AddConversationNote(ctx, first, note); err != nil {
+		t.Fatalf("failed to add note: %v", err)
+	}
+
+	transcript, err := json.Marshal(map[string]interface{}{
+		"type":      "user",
+		"sessionId": "s1",
+		"timestamp": time.Now().Format(time.RFC3339),
+		"message":   map[string]interface{}{"content": "Fix the message, then add the follow-up"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcriptPath := filepath.Join(t.TempDir(), "s1.jsonl")
+	if err := os.WriteFile(transcriptPath, append(transcript, '\n'), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// One command amends the commit and creates another
+	reflogCount, err := notesManager.HeadReflogCount(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	git("commit", "-q", "--amend", "--allow-empty", "-m", "First commit, amended")
+	git("commit", "-q", "--allow-empty", "-m", "Follow-up commit")
+	commits, err := notesManager.CommitsCreatedSince(ctx, reflogCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 || !commits[0].IsAmend() || commits[1].IsAmend() {
+		t.Fatalf("expected an amend and a new commit, got %+v", commits)
+	}
+
+	input := HookInput{SessionID: "s1", TranscriptPath: transcriptPath, CWD: dir, ToolUseID: "tool-1"}
+	bashInput := BashToolInput{Command: `git commit --amend -m "First commit, amended" && git commit -m "Follow-up commit"`}
+	if err := processGitCommit(ctx, input, bashInput, commits); err != nil {
+		t.Fatalf("failed to process commits: %v", err)
+	}
+
+	amended, err := notesManager.GetConversationNote(ctx, commits[0].Hash)
+	if err != nil || amended == nil {
+		t.Fatalf("expected the note carried over to the amended commit, got %v", err)
+	}
+	if len(amended.Entries) != 1 || len(amended.Amendments) != 1 {
+		t.Fatalf("expected the original entry and one amendment, got %+v", amended)
+	}
+	followUp, err := notesManager.GetConversationNote(ctx, commits[1].Hash)
+	if err != nil || followUp == nil || len(followUp.Entries) != 1 {
+		t.Fatalf("expected a note for the new commit, got %+v, %v", followUp, err)
+	}
+
+	// Both commits share the conversation extracted once for the command
+	amendment, entry := amended.Amendments[0], followUp.Entries[0]
+	if !strings.Contains(amendment.ConversationExcerpt, "Fix the message") {
+		t.Errorf("expected the amendment to carry the conversation, got %q", amendment.ConversationExcerpt)
+	}
+	if amendment.ConversationExcerpt != entry.ConversationExcerpt || len(amendment.Events) != len(entry.Events) {
+		t.Errorf("expected the amendment and the new commit to share the conversation, got %q and %q",
+			amendment.ConversationExcerpt, entry.ConversationExcerpt)
+	}
+}
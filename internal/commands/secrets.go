@@ -0,0 +1,220 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/imjasonh/cnotes/internal/config"
+	"github.com/imjasonh/cnotes/internal/notes"
+	"github.com/imjasonh/cnotes/internal/redact"
+	"github.com/spf13/cobra"
+)
+
+var scanCmd = &cobra.Command{
+	Use:   "scan [range]",
+	Short: "Find secrets in stored conversation notes",
+	Long: `Runs the secret detector configured in .claude/notes.json over the
+conversation notes already stored, e.g. those written before redaction covered
+everything, and reports each secret found by commit, field and rule.
+
+A range such as main..feature limits the scan to those commits; without one
+every commit with a conversation note is scanned. Exits with an error if any
+secret is found; remove them with 'cnotes redact'.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true, // Finding secrets isn't a usage error
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		cfg := config.LoadNotesConfig(".")
+		notesManager := notes.NewNotesManager(".")
+		notesManager.SetNotesRef(cfg.NotesRef)
+
+		noted, err := readNotes(ctx, notesManager, args)
+		if err != nil {
+			return err
+		}
+
+		redactor := redact.FromConfig(cfg)
+		var findings []notes.SecretFinding
+		for commit, note := range noted {
+			findings = append(findings, notes.ScanNote(commit, note, redactor)...)
+		}
+
+		if len(findings) == 0 {
+			fmt.Printf("No secrets found in %d conversation notes.\n", len(noted))
+			return nil
+		}
+
+		sort.SliceStable(findings, func(i, j int) bool {
+			return findings[i].Commit < findings[j].Commit
+		})
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		commits := make(map[string]bool)
+		for _, finding := range findings {
+			commits[finding.Commit] = true
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", shortHash(finding.Commit), finding.Field, finding.RuleID, maskSecret(finding.Secret))
+		}
+		w.Flush()
+
+		fmt.Printf("\n💡 Run 'cnotes redact --all --history' to remove them from the notes and their history\n")
+		unit := "secrets"
+		if len(findings) == 1 {
+			unit = "secret"
+		}
+		return fmt.Errorf("found %d %s in %s", len(findings), unit, formatCommitCount(len(commits)))
+	},
+}
+
+var (
+	redactAll      bool
+	redactHistory  bool
+	redactRules    []string
+	redactPatterns []string
+	redactCmd      = &cobra.Command{
+		Use:   "redact [commit|range]...",
+		Short: "Remove secrets from stored conversation notes",
+		Long: `Rewrites the conversation notes of the given commits, or of every commit
+with --all, replacing the secrets the detector configured in .claude/notes.json
+finds with [REDACTED].
+
+--rule limits redaction to the named rules, and --pattern redacts whatever a
+regular expression matches, e.g. a credential 'cnotes scan' can't recognise.
+
+Rewriting a note only adds a commit to the notes ref, so the secret is still in
+its history. --history rewrites every commit of the notes ref instead, so the
+secret is gone from all of them; the notes ref then has to be force-pushed.`,
+		RunE: runRedact,
+	}
+)
+
+func runRedact(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	cfg := config.LoadNotesConfig(".")
+	notesManager := notes.NewNotesManager(".")
+	notesManager.SetNotesRef(cfg.NotesRef)
+
+	if len(args) == 0 && !redactAll {
+		return fmt.Errorf("name the commits whose notes to redact, or use --all")
+	}
+
+	redactor, err := selectRedactor(cfg)
+	if err != nil {
+		return err
+	}
+	notesManager.SetRedactor(redactor)
+
+	// Redact only the named commits' notes
+	var commits []string
+	if !redactAll {
+		for _, arg := range args {
+			resolved, err := resolveCommits(ctx, notesManager, arg)
+			if err != nil {
+				return err
+			}
+			commits = append(commits, resolved...)
+		}
+	}
+
+	if redactHistory {
+		rewritten, err := notesManager.RewriteNotesHistory(ctx, commits)
+		if err != nil {
+			return fmt.Errorf("failed to rewrite notes history: %w", err)
+		}
+		if rewritten == 0 {
+			fmt.Println("No secrets found in the notes history.")
+			return nil
+		}
+
+		fmt.Printf("✅ Rewrote %d commits of %s\n", rewritten, notesManager.FullNotesRef())
+		fmt.Printf("💡 The old notes are still in the reflog until it expires. To drop them now and share the rewrite:\n")
+		fmt.Printf("   git reflog expire --expire=now --all && git gc --prune=now\n")
+		fmt.Printf("   git push --force origin %s\n", notesManager.FullNotesRef())
+		return nil
+	}
+
+	if redactAll {
+		backup, err := notesManager.BackupAllNotes(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list notes: %w", err)
+		}
+		for commit := range backup.Notes {
+			commits = append(commits, commit)
+		}
+		sort.Strings(commits)
+	}
+
+	redacted := 0
+	for _, commit := range commits {
+		ok, err := notesManager.RedactNote(ctx, commit)
+		if err != nil {
+			return fmt.Errorf("failed to redact note for %s: %w", shortHash(commit), err)
+		}
+		if ok {
+			fmt.Printf("Redacted note for %s\n", shortHash(commit))
+			redacted++
+		}
+	}
+
+	if redacted == 0 {
+		fmt.Println("No secrets found in the notes.")
+		return nil
+	}
+
+	fmt.Printf("✅ Redacted %s\n", formatCommitCount(redacted))
+	fmt.Printf("💡 The secrets are still in the history of %s; use --history to remove them from it\n", notesManager.FullNotesRef())
+	return nil
+}
+
+// selectRedactor returns the redactor for the --rule and --pattern flags, or
+// the configured one if neither is given
+func selectRedactor(cfg *config.NotesConfig) (*redact.Redactor, error) {
+	redactor := redact.FromConfig(cfg)
+	if len(redactRules) == 0 && len(redactPatterns) == 0 {
+		return redactor, nil
+	}
+
+	selected, err := redactor.Only(redactRules)
+	if err != nil {
+		return nil, err
+	}
+	for _, pattern := range redactPatterns {
+		selected = selected.With(redact.NewRule(config.SecretRule{ID: "pattern:" + pattern, Pattern: pattern}))
+	}
+	return selected, nil
+}
+
+// resolveCommits returns the commits a revision range such as main..feature
+// names, or the single commit another revision names
+func resolveCommits(ctx context.Context, notesManager *notes.NotesManager, rev string) ([]string, error) {
+	if strings.Contains(rev, "..") {
+		return notesManager.RevList(ctx, rev)
+	}
+
+	commit, err := notesManager.ResolveCommit(ctx, rev)
+	if err != nil {
+		return nil, err
+	}
+	return []string{commit}, nil
+}
+
+// maskSecret shows enough of a secret to recognise it without revealing it
+func maskSecret(secret string) string {
+	runes := []rune(secret)
+	if len(runes) < 12 {
+		return fmt.Sprintf("%s (%d characters)", strings.Repeat("*", len(runes)), len(runes))
+	}
+	return fmt.Sprintf("%s%s (%d characters)", string(runes[:4]), strings.Repeat("*", 8), len(runes))
+}
+
+func init() {
+	rootCmd.AddCommand(scanCmd)
+	rootCmd.AddCommand(redactCmd)
+	redactCmd.Flags().BoolVar(&redactAll, "all", false, "Redact the notes of every commit")
+	redactCmd.Flags().BoolVar(&redactHistory, "history", false, "Rewrite every commit of the notes ref, not just its tip")
+	redactCmd.Flags().StringSliceVar(&redactRules, "rule", nil, "Only redact what these rules detect (repeatable)")
+	redactCmd.Flags().StringArrayVar(&redactPatterns, "pattern", nil, "Redact whatever this regular expression matches (repeatable)")
+}
@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/imjasonh/cnotes/internal/config"
+	"github.com/imjasonh/cnotes/internal/notes"
+	"github.com/imjasonh/cnotes/internal/redact"
+	"github.com/spf13/cobra"
+)
+
+var pushCmd = &cobra.Command{
+	Use:   "push [remote]",
+	Short: "Push conversation notes to a remote",
+	Long: `Pushes the notes ref to a remote, origin if none is given.
+
+If the remote has notes that haven't been fetched yet, the push is rejected;
+run cnotes sync to merge them first.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true, // A git failure isn't a usage error
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		remote := remoteArg(args)
+		notesManager := syncNotesManager()
+
+		return pushNotes(ctx, notesManager, remote)
+	},
+}
+
+var fetchCmd = &cobra.Command{
+	Use:   "fetch [remote]",
+	Short: "Fetch conversation notes from a remote and merge them",
+	Long: `Fetches the notes ref of a remote, origin if none is given, and merges it into
+the local notes.
+
+A note only one side changed is taken from that side. A note both sides
+changed, such as when two people's sessions contributed to the same commit, is
+merged by combining the session entries of the two notes, rather than keeping
+only one of them.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true, // A git failure isn't a usage error
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		remote := remoteArg(args)
+		notesManager := syncNotesManager()
+
+		return fetchNotes(ctx, notesManager, remote)
+	},
+}
+
+var syncCmd = &cobra.Command{
+	Use:   "sync [remote]",
+	Short: "Fetch, merge and push conversation notes",
+	Long: `Fetches the notes of a remote, origin if none is given, merges them into the
+local notes like cnotes fetch, then pushes the result like cnotes push.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true, // A git failure isn't a usage error
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		remote := remoteArg(args)
+		notesManager := syncNotesManager()
+
+		if err := fetchNotes(ctx, notesManager, remote); err != nil {
+			return err
+		}
+		return pushNotes(ctx, notesManager, remote)
+	},
+}
+
+// remoteArg returns the remote named by the only argument, or origin
+func remoteArg(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return "origin"
+}
+
+// syncNotesManager returns a notes manager for the configured notes ref
+func syncNotesManager() *notes.NotesManager {
+	cfg := config.LoadNotesConfig(".")
+	notesManager := notes.NewNotesManager(".")
+	notesManager.SetNotesRef(cfg.NotesRef)
+
+	// Merged notes are redacted like any other note cnotes writes
+	notesManager.SetRedactor(redact.FromConfig(cfg))
+	return notesManager
+}
+
+// fetchNotes fetches and merges a remote's notes, reporting what changed
+func fetchNotes(ctx context.Context, notesManager *notes.NotesManager, remote string) error {
+	result, err := notesManager.FetchNotes(ctx, remote)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !result.Found:
+		fmt.Printf("%s has no conversation notes.\n", remote)
+	case !result.Updated:
+		fmt.Printf("Conversation notes are up to date with %s.\n", remote)
+	default:
+		fmt.Printf("✅ Fetched conversation notes from %s\n", remote)
+	}
+	if result.Merged > 0 {
+		fmt.Printf("   Merged %d notes both sides had changed\n", result.Merged)
+	}
+	if result.Skipped > 0 {
+		fmt.Printf("⚠️  Kept the local version of %d notes that couldn't be read\n", result.Skipped)
+	}
+	return nil
+}
+
+// pushNotes pushes the local notes to a remote
+func pushNotes(ctx context.Context, notesManager *notes.NotesManager, remote string) error {
+	pushed, err := notesManager.PushNotes(ctx, remote)
+	if errors.Is(err, notes.ErrPushRejected) {
+		return fmt.Errorf("%s has conversation notes that haven't been fetched; run cnotes sync %s to merge them", remote, remote)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !pushed {
+		fmt.Println("No conversation notes to push.")
+		return nil
+	}
+	fmt.Printf("✅ Pushed conversation notes to %s\n", remote)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(pushCmd)
+	rootCmd.AddCommand(fetchCmd)
+	rootCmd.AddCommand(syncCmd)
+}
@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	conv "github.com/imjasonh/cnotes/internal/context"
+	"github.com/spf13/cobra"
+)
+
+var transcriptCmd = &cobra.Command{
+	Use:   "transcript",
+	Short: "Inspect Claude Code transcript files",
+}
+
+var transcriptLintCmd = &cobra.Command{
+	Use:   "lint <file>",
+	Short: "Check a transcript for format changes cnotes doesn't understand",
+	Long: `Compares every line of a Claude Code transcript (.jsonl) against the
+transcript schema cnotes parses, and reports unknown entry types, fields and
+content blocks. Run it after a Claude Code update to find out whether the
+transcript format drifted. Exits non-zero when drift is found.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open transcript: %w", err)
+		}
+		defer file.Close()
+
+		drift := conv.NewDriftDetector()
+		reader := bufio.NewReader(file)
+		entries := 0
+		for lineNumber := 1; ; lineNumber++ {
+			line, err := reader.ReadBytes('\n')
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				entries++
+				record, decodeErr := conv.DecodeTranscriptEntry(line)
+				drift.Check(lineNumber, line, record, decodeErr)
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read transcript: %w", err)
+			}
+		}
+
+		fmt.Printf("Checked %d entries written by Claude Code %s (schema checked against %s)\n\n",
+			entries, formatVersions(drift.Versions()), conv.TranscriptSchemaVersion)
+
+		warnings := drift.Warnings()
+		if len(warnings) == 0 {
+			fmt.Println("✅ No format drift found")
+			return nil
+		}
+
+		for _, warning := range warnings {
+			fmt.Printf("⚠️  %s\n", warning)
+		}
+
+		cmd.SilenceUsage = true
+		return fmt.Errorf("found %d kinds of format drift", len(warnings))
+	},
+}
+
+// formatVersions lists the Claude Code versions that wrote a transcript
+func formatVersions(versions map[string]int) string {
+	if len(versions) == 0 {
+		return "an unknown version"
+	}
+
+	names := make([]string, 0, len(versions))
+	for version := range versions {
+		names = append(names, version)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func init() {
+	rootCmd.AddCommand(transcriptCmd)
+	transcriptCmd.AddCommand(transcriptLintCmd)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // NotesConfig controls git notes behavior for conversation logging
@@ -16,6 +17,145 @@ type NotesConfig struct {
 	ExcludePatterns   []string `json:"exclude_patterns"`    // Patterns to exclude from notes
 	UserEmoji         string   `json:"user_emoji"`          // Emoji to use for user messages
 	AssistantEmoji    string   `json:"assistant_emoji"`     // Emoji to use for assistant messages
+	Attribution       string   `json:"attribution"`         // Whose conversation a commit's note carries; see the Attribution constants
+	PrivacyLevel      string   `json:"privacy_level"`       // How much of the conversation notes capture; see the Privacy constants
+	Filter            string   `json:"filter"`              // Which events notes keep; see the Filter constants
+
+	// EventLimits caps, in characters, how much of each type of event an
+	// excerpt shows, keyed by event type: user, assistant, thinking, tool and
+	// tool_result. Entries here override the defaults.
+	EventLimits map[string]int `json:"event_limits,omitempty"`
+
+	// Secrets tunes the detector that redacts secrets from notes
+	Secrets SecretsConfig `json:"secrets"`
+
+	// Pricing maps a model name prefix to its price, used to estimate what a
+	// commit's conversation cost. Entries here override the defaults.
+	Pricing map[string]ModelPricing `json:"pricing,omitempty"`
+}
+
+// Attribution policies, deciding which sessions' conversations go into the
+// note of a commit a session made
+const (
+	AttributionSession   = "session"   // Only the session that made the commit
+	AttributionSubagents = "subagents" // The session and the subagents it ran
+	AttributionFiles     = "files"     // Also other sessions whose edits touched the committed files
+)
+
+// validAttribution reports whether a policy is one of the Attribution constants
+func validAttribution(policy string) bool {
+	switch policy {
+	case AttributionSession, AttributionSubagents, AttributionFiles:
+		return true
+	}
+	return false
+}
+
+// Privacy levels, presets for how much of the conversation a note captures
+const (
+	PrivacyMinimal  = "minimal"  // Only the user's prompts and the paths of files tools read or wrote
+	PrivacyStandard = "standard" // Prompts, responses and tool uses; tool output only with include_tool_output
+	PrivacyFull     = "full"     // Also tool output and Claude's reasoning
+)
+
+// validPrivacyLevel reports whether a level is one of the Privacy constants
+func validPrivacyLevel(level string) bool {
+	switch level {
+	case PrivacyMinimal, PrivacyStandard, PrivacyFull:
+		return true
+	}
+	return false
+}
+
+// Filters, deciding which of the conversation since the previous commit goes
+// into a note
+const (
+	FilterAll       = "all"       // Everything
+	FilterRelevance = "relevance" // Only what read, edited or mentioned the committed files, and the prompts that led to it
+)
+
+// validFilter reports whether a filter is one of the Filter constants
+func validFilter(filter string) bool {
+	switch filter {
+	case FilterAll, FilterRelevance:
+		return true
+	}
+	return false
+}
+
+// SecretsConfig adds to and tunes the built-in secret detection rules
+type SecretsConfig struct {
+	Rules     []SecretRule `json:"rules,omitempty"`     // Extra rules; one with a built-in rule's ID replaces it
+	Disabled  []string     `json:"disabled,omitempty"`  // IDs of built-in rules to turn off
+	Allowlist []string     `json:"allowlist,omitempty"` // Patterns for text that is never a secret, added to the built-in ones
+}
+
+// SecretRule detects one kind of secret
+type SecretRule struct {
+	ID      string  `json:"id"`                // Recorded in notes when the rule redacts something
+	Pattern string  `json:"pattern"`           // Regular expression; only its "secret" or first group is redacted if it has one
+	Entropy float64 `json:"entropy,omitempty"` // Minimum Shannon entropy, in bits per character, of a match
+}
+
+// ModelPricing is what a model charges, in USD per million tokens
+type ModelPricing struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cache_read"`
+	CacheWrite float64 `json:"cache_write"`
+}
+
+// Cost estimates the price in USD of the given token counts
+func (p ModelPricing) Cost(input, output, cacheRead, cacheWrite int) float64 {
+	return (float64(input)*p.Input +
+		float64(output)*p.Output +
+		float64(cacheRead)*p.CacheRead +
+		float64(cacheWrite)*p.CacheWrite) / 1_000_000
+}
+
+// DefaultPricing returns list prices for Claude models, keyed by model name prefix
+func DefaultPricing() map[string]ModelPricing {
+	opus := ModelPricing{Input: 15, Output: 75, CacheRead: 1.5, CacheWrite: 18.75}
+	sonnet := ModelPricing{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75}
+
+	return map[string]ModelPricing{
+		"claude-opus-4-5":   {Input: 5, Output: 25, CacheRead: 0.5, CacheWrite: 6.25},
+		"claude-opus-4":     opus,
+		"claude-3-opus":     opus,
+		"claude-sonnet-4":   sonnet,
+		"claude-3-7-sonnet": sonnet,
+		"claude-3-5-sonnet": sonnet,
+		"claude-haiku-4-5":  {Input: 1, Output: 5, CacheRead: 0.1, CacheWrite: 1.25},
+		"claude-3-5-haiku":  {Input: 0.8, Output: 4, CacheRead: 0.08, CacheWrite: 1},
+		"claude-3-haiku":    {Input: 0.25, Output: 1.25, CacheRead: 0.03, CacheWrite: 0.3},
+	}
+}
+
+// PricingFor returns the pricing for a model, matching the longest configured
+// name prefix so that dated model IDs like claude-sonnet-4-20250514 resolve
+func (c *NotesConfig) PricingFor(model string) (ModelPricing, bool) {
+	var best string
+	for prefix := range c.Pricing {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return ModelPricing{}, false
+	}
+	return c.Pricing[best], true
+}
+
+// DefaultEventLimits returns how many characters of each type of event an
+// excerpt shows by default
+func DefaultEventLimits() map[string]int {
+	return map[string]int{
+		"user":        200,
+		"assistant":   200,
+		"thinking":    200,
+		"tool":        150,
+		"tool_result": 150,
+	}
 }
 
 // DefaultNotesConfig returns the default configuration
@@ -36,6 +176,11 @@ func DefaultNotesConfig() *NotesConfig {
 		},
 		UserEmoji:      "👤",
 		AssistantEmoji: "🤖",
+		Attribution:    AttributionSubagents,
+		PrivacyLevel:   PrivacyStandard,
+		Filter:         FilterAll,
+		EventLimits:    DefaultEventLimits(),
+		Pricing:        DefaultPricing(),
 	}
 }
 
@@ -75,6 +220,32 @@ func LoadNotesConfig(projectDir string) *NotesConfig {
 	if config.AssistantEmoji == "" {
 		config.AssistantEmoji = defaults.AssistantEmoji
 	}
+	if config.ExcludePatterns == nil {
+		config.ExcludePatterns = defaults.ExcludePatterns
+	}
+	if !validAttribution(config.Attribution) {
+		config.Attribution = defaults.Attribution
+	}
+	if !validPrivacyLevel(config.PrivacyLevel) {
+		config.PrivacyLevel = defaults.PrivacyLevel
+	}
+	if !validFilter(config.Filter) {
+		config.Filter = defaults.Filter
+	}
+
+	// Event limits override the defaults one event type at a time
+	for eventType, limit := range config.EventLimits {
+		if limit > 0 {
+			defaults.EventLimits[eventType] = limit
+		}
+	}
+	config.EventLimits = defaults.EventLimits
+
+	// Pricing entries override the defaults one model at a time
+	for prefix, pricing := range config.Pricing {
+		defaults.Pricing[prefix] = pricing
+	}
+	config.Pricing = defaults.Pricing
 
 	return &config
 }
@@ -237,6 +237,10 @@ func TestLoadNotesConfig(t *testing.T) {
 		if config.AssistantEmoji != defaults.AssistantEmoji {
 			t.Errorf("expected default AssistantEmoji %s, got %s", defaults.AssistantEmoji, config.AssistantEmoji)
 		}
+
+		if len(config.ExcludePatterns) != len(defaults.ExcludePatterns) {
+			t.Errorf("expected default ExcludePatterns %v, got %v", defaults.ExcludePatterns, config.ExcludePatterns)
+		}
 	})
 
 	t.Run("config with zero values", func(t *testing.T) {
@@ -451,3 +455,166 @@ func TestConfigRoundTrip(t *testing.T) {
 		t.Error("AssistantEmoji doesn't match after round trip")
 	}
 }
+
+func TestPricing(t *testing.T) {
+	t.Run("longest prefix wins", func(t *testing.T) {
+		config := DefaultNotesConfig()
+
+		pricing, ok := config.PricingFor("claude-opus-4-5-20251101")
+		if !ok || pricing.Input != 5 {
+			t.Errorf("expected opus 4.5 pricing, got %+v", pricing)
+		}
+
+		pricing, ok = config.PricingFor("claude-opus-4-1-20250805")
+		if !ok || pricing.Input != 15 {
+			t.Errorf("expected opus 4 pricing, got %+v", pricing)
+		}
+
+		if _, ok := config.PricingFor("gpt-4o"); ok {
+			t.Error("expected no pricing for an unknown model")
+		}
+	})
+
+	t.Run("cost per million tokens", func(t *testing.T) {
+		pricing := ModelPricing{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75}
+
+		cost := pricing.Cost(1_000_000, 100_000, 2_000_000, 0)
+		if expected := 3 + 1.5 + 0.6; cost < expected-1e-9 || cost > expected+1e-9 {
+			t.Errorf("expected %f, got %f", expected, cost)
+		}
+	})
+
+	t.Run("config overrides defaults", func(t *testing.T) {
+		tempDir := t.TempDir()
+		claudeDir := filepath.Join(tempDir, ".claude")
+		if err := os.MkdirAll(claudeDir, 0755); err != nil {
+			t.Fatalf("failed to create .claude dir: %v", err)
+		}
+
+		data := []byte(`{"enabled": true, "pricing": {"claude-sonnet-4": {"input": 1, "output": 2}, "my-model": {"input": 7}}}`)
+		if err := os.WriteFile(filepath.Join(claudeDir, "notes.json"), data, 0644); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+
+		config := LoadNotesConfig(tempDir)
+
+		if pricing, _ := config.PricingFor("claude-sonnet-4-20250514"); pricing.Input != 1 || pricing.Output != 2 {
+			t.Errorf("expected overridden sonnet pricing, got %+v", pricing)
+		}
+
+		if pricing, ok := config.PricingFor("my-model"); !ok || pricing.Input != 7 {
+			t.Errorf("expected custom model pricing, got %+v", pricing)
+		}
+
+		if _, ok := config.PricingFor("claude-3-5-haiku-20241022"); !ok {
+			t.Error("expected defaults to remain for models not overridden")
+		}
+	})
+}
+
+func TestAttribution(t *testing.T) {
+	if policy := DefaultNotesConfig().Attribution; policy != AttributionSubagents {
+		t.Errorf("expected subagents attribution by default, got %q", policy)
+	}
+
+	for _, tt := range []struct {
+		config   string
+		expected string
+	}{
+		{`{"enabled": true, "attribution": "files"}`, AttributionFiles},
+		{`{"enabled": true, "attribution": "session"}`, AttributionSession},
+		{`{"enabled": true, "attribution": "everyone"}`, AttributionSubagents},
+		{`{"enabled": true}`, AttributionSubagents},
+	} {
+		tempDir := t.TempDir()
+		claudeDir := filepath.Join(tempDir, ".claude")
+		if err := os.MkdirAll(claudeDir, 0755); err != nil {
+			t.Fatalf("failed to create .claude dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(claudeDir, "notes.json"), []byte(tt.config), 0644); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+
+		if policy := LoadNotesConfig(tempDir).Attribution; policy != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.config, tt.expected, policy)
+		}
+	}
+}
+
+func TestPrivacyLevel(t *testing.T) {
+	if level := DefaultNotesConfig().PrivacyLevel; level != PrivacyStandard {
+		t.Errorf("expected standard privacy by default, got %q", level)
+	}
+
+	for _, tt := range []struct {
+		config   string
+		expected string
+	}{
+		{`{"enabled": true, "privacy_level": "minimal"}`, PrivacyMinimal},
+		{`{"enabled": true, "privacy_level": "full"}`, PrivacyFull},
+		{`{"enabled": true, "privacy_level": "paranoid"}`, PrivacyStandard},
+		{`{"enabled": true}`, PrivacyStandard},
+	} {
+		tempDir := t.TempDir()
+		claudeDir := filepath.Join(tempDir, ".claude")
+		if err := os.MkdirAll(claudeDir, 0755); err != nil {
+			t.Fatalf("failed to create .claude dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(claudeDir, "notes.json"), []byte(tt.config), 0644); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+
+		if level := LoadNotesConfig(tempDir).PrivacyLevel; level != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.config, tt.expected, level)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	if filter := DefaultNotesConfig().Filter; filter != FilterAll {
+		t.Errorf("expected every event to be kept by default, got %q", filter)
+	}
+
+	for _, tt := range []struct {
+		config   string
+		expected string
+	}{
+		{`{"enabled": true, "filter": "relevance"}`, FilterRelevance},
+		{`{"enabled": true, "filter": "everything"}`, FilterAll},
+		{`{"enabled": true}`, FilterAll},
+	} {
+		tempDir := t.TempDir()
+		claudeDir := filepath.Join(tempDir, ".claude")
+		if err := os.MkdirAll(claudeDir, 0755); err != nil {
+			t.Fatalf("failed to create .claude dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(claudeDir, "notes.json"), []byte(tt.config), 0644); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+
+		if filter := LoadNotesConfig(tempDir).Filter; filter != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.config, tt.expected, filter)
+		}
+	}
+}
+
+func TestEventLimits(t *testing.T) {
+	tempDir := t.TempDir()
+	claudeDir := filepath.Join(tempDir, ".claude")
+	if err := os.MkdirAll(claudeDir, 0755); err != nil {
+		t.Fatalf("failed to create .claude dir: %v", err)
+	}
+
+	data := []byte(`{"enabled": true, "event_limits": {"user": 1000, "tool_result": 0}}`)
+	if err := os.WriteFile(filepath.Join(claudeDir, "notes.json"), data, 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	limits := LoadNotesConfig(tempDir).EventLimits
+	if limits["user"] != 1000 {
+		t.Errorf("expected the user limit to be overridden, got %d", limits["user"])
+	}
+	if limits["tool_result"] != 150 || limits["assistant"] != 200 {
+		t.Errorf("expected defaults for limits not overridden, got %v", limits)
+	}
+}
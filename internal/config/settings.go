@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 )
 
 type Settings struct {
@@ -88,6 +89,18 @@ func InstallHooks(binaryPath string) error {
 	return InstallHooksToPath(binaryPath, GetSettingsPath())
 }
 
+// HookEvents maps each Claude Code hook event cnotes handles to the matcher
+// it registers with. Events that don't support matchers use an empty matcher.
+var HookEvents = map[string]string{
+	"SessionStart":     "",
+	"UserPromptSubmit": "",
+	"PreToolUse":       "Bash",
+	"PostToolUse":      "Bash",
+	"Stop":             "",
+	"SubagentStop":     "",
+	"PreCompact":       "",
+}
+
 func InstallHooksToPath(binaryPath, settingsPath string) error {
 	settings, err := LoadSettings(settingsPath)
 	if err != nil {
@@ -103,33 +116,43 @@ func InstallHooksToPath(binaryPath, settingsPath string) error {
 		Command: binaryPath,
 	}
 
-	hookDef := HookDefinition{
-		Matcher: ".*",
-		Hooks:   []HookAction{hookAction},
-	}
-
-	// Only register for PostToolUse events since that's all we handle
-	claudeEvent := "PostToolUse"
-
-	// Check if our hook is already installed
-	found := false
-	for i, def := range settings.Hooks[claudeEvent] {
-		for j, action := range def.Hooks {
-			if action.Command == binaryPath {
-				// Update existing hook
-				settings.Hooks[claudeEvent][i].Hooks[j] = hookAction
-				found = true
+	for claudeEvent, matcher := range HookEvents {
+		// Check if our hook is already installed
+		found := false
+		for i, def := range settings.Hooks[claudeEvent] {
+			for j, action := range def.Hooks {
+				if action.Command != binaryPath {
+					continue
+				}
+				switch {
+				case len(def.Hooks) == 1:
+					// Update existing hook, including matchers from older installs
+					settings.Hooks[claudeEvent][i].Matcher = matcher
+					settings.Hooks[claudeEvent][i].Hooks[j] = hookAction
+					found = true
+				case def.Matcher == matcher:
+					settings.Hooks[claudeEvent][i].Hooks[j] = hookAction
+					found = true
+				default:
+					// The definition is shared with other hooks, whose matcher
+					// is the user's to keep, so move ours to a definition of
+					// its own below
+					settings.Hooks[claudeEvent][i].Hooks = slices.Delete(slices.Clone(def.Hooks), j, j+1)
+				}
+				break
+			}
+			if found {
 				break
 			}
 		}
-		if found {
-			break
-		}
-	}
 
-	if !found {
-		// Add new hook
-		settings.Hooks[claudeEvent] = append(settings.Hooks[claudeEvent], hookDef)
+		if !found {
+			// Add new hook
+			settings.Hooks[claudeEvent] = append(settings.Hooks[claudeEvent], HookDefinition{
+				Matcher: matcher,
+				Hooks:   []HookAction{hookAction},
+			})
+		}
 	}
 
 	return SaveSettings(settingsPath, settings)
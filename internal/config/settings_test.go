@@ -216,9 +216,20 @@ func TestInstallHooksToPath(t *testing.T) {
 			t.Fatalf("failed to load settings: %v", err)
 		}
 
-		// Should only have PostToolUse
-		if len(settings.Hooks) != 1 {
-			t.Errorf("expected 1 hook event, got %d", len(settings.Hooks))
+		// Should have every handled event
+		if len(settings.Hooks) != len(HookEvents) {
+			t.Errorf("expected %d hook events, got %d", len(HookEvents), len(settings.Hooks))
+		}
+
+		for event, matcher := range HookEvents {
+			defs, ok := settings.Hooks[event]
+			if !ok {
+				t.Errorf("%s not found", event)
+				continue
+			}
+			if defs[0].Matcher != matcher {
+				t.Errorf("expected %s matcher %q, got %q", event, matcher, defs[0].Matcher)
+			}
 		}
 
 		postToolUse, ok := settings.Hooks["PostToolUse"]
@@ -331,6 +342,92 @@ func TestInstallHooksToPath(t *testing.T) {
 			t.Errorf("expected 2 hooks total, got %d", hookCount)
 		}
 	})
+
+	t.Run("update matcher from older install", func(t *testing.T) {
+		oldSettingsPath := filepath.Join(tempDir, "old-settings.json")
+
+		// Older versions registered PostToolUse with a catch-all matcher
+		oldSettings := &Settings{
+			Hooks: map[string][]HookDefinition{
+				"PostToolUse": {
+					{
+						Matcher: ".*",
+						Hooks: []HookAction{
+							{
+								Type:    "command",
+								Command: binaryPath,
+							},
+						},
+					},
+				},
+			},
+		}
+
+		if err := SaveSettings(oldSettingsPath, oldSettings); err != nil {
+			t.Fatalf("failed to save old settings: %v", err)
+		}
+
+		if err := InstallHooksToPath(binaryPath, oldSettingsPath); err != nil {
+			t.Fatalf("failed to install hooks: %v", err)
+		}
+
+		settings, err := LoadSettings(oldSettingsPath)
+		if err != nil {
+			t.Fatalf("failed to load settings: %v", err)
+		}
+
+		postToolUse := settings.Hooks["PostToolUse"]
+		if len(postToolUse) != 1 {
+			t.Fatalf("expected 1 hook definition, got %d", len(postToolUse))
+		}
+
+		if postToolUse[0].Matcher != "Bash" {
+			t.Errorf("expected matcher Bash, got %q", postToolUse[0].Matcher)
+		}
+	})
+
+	t.Run("keep matcher of a shared definition", func(t *testing.T) {
+		sharedSettingsPath := filepath.Join(tempDir, "shared-settings.json")
+
+		// The user put cnotes alongside their own hook
+		sharedSettings := &Settings{
+			Hooks: map[string][]HookDefinition{
+				"PostToolUse": {
+					{
+						Matcher: "Edit|Write",
+						Hooks: []HookAction{
+							{Type: "command", Command: "/other/command"},
+							{Type: "command", Command: binaryPath},
+						},
+					},
+				},
+			},
+		}
+
+		if err := SaveSettings(sharedSettingsPath, sharedSettings); err != nil {
+			t.Fatalf("failed to save shared settings: %v", err)
+		}
+
+		if err := InstallHooksToPath(binaryPath, sharedSettingsPath); err != nil {
+			t.Fatalf("failed to install hooks: %v", err)
+		}
+
+		settings, err := LoadSettings(sharedSettingsPath)
+		if err != nil {
+			t.Fatalf("failed to load settings: %v", err)
+		}
+
+		postToolUse := settings.Hooks["PostToolUse"]
+		if len(postToolUse) != 2 {
+			t.Fatalf("expected 2 hook definitions, got %+v", postToolUse)
+		}
+		if shared := postToolUse[0]; shared.Matcher != "Edit|Write" || len(shared.Hooks) != 1 || shared.Hooks[0].Command != "/other/command" {
+			t.Errorf("expected the user's definition left with its matcher and hook, got %+v", shared)
+		}
+		if own := postToolUse[1]; own.Matcher != "Bash" || len(own.Hooks) != 1 || own.Hooks[0].Command != binaryPath {
+			t.Errorf("expected cnotes in a definition of its own, got %+v", own)
+		}
+	})
 }
 
 func TestUninstallHooksFromPath(t *testing.T) {
@@ -0,0 +1,185 @@
+package context
+
+import (
+	"path/filepath"
+
+	"github.com/imjasonh/cnotes/internal/config"
+)
+
+// threadKey identifies one thread of conversation in a transcript: a
+// session's main conversation, or one of its subagents' sidechains
+type threadKey struct {
+	sessionID string
+	agentID   string // Subagent that wrote a sidechain
+	sidechain string // First entry of a sidechain, for transcripts that don't name the subagent
+}
+
+// isSidechain reports whether the thread is a subagent's
+func (key threadKey) isSidechain() bool {
+	return key.agentID != "" || key.sidechain != ""
+}
+
+// transcriptThread is the conversation of one thread of a transcript
+type transcriptThread struct {
+	key     threadKey
+	context *ConversationContext
+	edited  map[string]bool   // Files the thread's tool uses wrote to
+	tasks   []*SubagentThread // Subagents the thread started, in order
+}
+
+// parsedTranscript is a transcript's conversation, split into threads in the
+// order they first appeared
+type parsedTranscript struct {
+	threads        []*transcriptThread
+	byKey          map[threadKey]*transcriptThread
+	formatWarnings []FormatWarning
+}
+
+// thread returns the thread for a key, starting it if it's new
+func (t *parsedTranscript) thread(key threadKey) *transcriptThread {
+	if thread, ok := t.byKey[key]; ok {
+		return thread
+	}
+
+	thread := &transcriptThread{
+		key:     key,
+		context: &ConversationContext{UserPrompts: []string{}, ClaudeResponses: []string{}, ToolInteractions: []ToolInteraction{}, Events: []ConversationEvent{}},
+		edited:  make(map[string]bool),
+	}
+	if t.byKey == nil {
+		t.byKey = make(map[threadKey]*transcriptThread)
+	}
+	t.byKey[key] = thread
+	t.threads = append(t.threads, thread)
+	return thread
+}
+
+// combined merges every thread of the transcript into one context
+func (t *parsedTranscript) combined() *ConversationContext {
+	context := mergeThreads(t.threads)
+	context.FormatWarnings = t.formatWarnings
+	return context
+}
+
+// editedFile records a file written by a tool use, from the tool's input
+func (thread *transcriptThread) editedFile(toolName string, input map[string]interface{}) {
+	switch toolName {
+	case "Write", "Edit", "MultiEdit", "NotebookEdit":
+		for _, field := range []string{"file_path", "notebook_path"} {
+			if path, ok := input[field].(string); ok && path != "" {
+				thread.edited[filepath.Clean(path)] = true
+			}
+		}
+	}
+}
+
+// editedAny reports whether the thread wrote to any of the given files
+func (thread *transcriptThread) editedAny(files []string) bool {
+	for _, file := range files {
+		if thread.edited[filepath.Clean(file)] {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeThreads combines the conversation of several threads, recording the
+// sessions they came from. Sidechains become subagent threads rather than
+// joining the main timeline, though their tools and tokens still count.
+func mergeThreads(threads []*transcriptThread) *ConversationContext {
+	combined := &ConversationContext{
+		UserPrompts:      []string{},
+		ClaudeResponses:  []string{},
+		ToolInteractions: []ToolInteraction{},
+		Events:           []ConversationEvent{},
+	}
+
+	var tasks []*SubagentThread
+	var sidechains []*transcriptThread
+	for _, thread := range threads {
+		context := thread.context
+		combined.ToolInteractions = append(combined.ToolInteractions, context.ToolInteractions...)
+		if thread.key.isSidechain() {
+			sidechains = append(sidechains, thread)
+		} else {
+			combined.UserPrompts = append(combined.UserPrompts, context.UserPrompts...)
+			combined.ClaudeResponses = append(combined.ClaudeResponses, context.ClaudeResponses...)
+			combined.Events = append(combined.Events, context.Events...)
+			tasks = append(tasks, thread.tasks...)
+		}
+		for model, stats := range context.Models {
+			combined.addModelStats(model, stats)
+		}
+		if context.LastEventTime.After(combined.LastEventTime) {
+			combined.LastEventTime = context.LastEventTime
+		}
+
+		if id := thread.key.sessionID; id != "" && !contains(combined.Sessions, id) {
+			combined.Sessions = append(combined.Sessions, id)
+		}
+	}
+
+	combined.Subagents = linkSubagents(tasks, sidechains)
+
+	return combined
+}
+
+// UseCommitFiles tells the extractor which files the commit being annotated
+// changed, as absolute paths. The files attribution policy credits other
+// sessions that edited them, and the relevance filter keeps the conversation
+// about them.
+func (ce *ContextExtractor) UseCommitFiles(files []string) {
+	ce.commitFiles = files
+}
+
+// attributionPolicy returns the configured attribution policy
+func (ce *ContextExtractor) attributionPolicy() string {
+	if ce.config == nil || ce.config.Attribution == "" {
+		return config.AttributionSubagents
+	}
+	return ce.config.Attribution
+}
+
+// attribute picks the threads whose conversation belongs to a commit made by
+// sessionID, according to the attribution policy. Entries without a session
+// are taken to be the current session's, and an empty sessionID takes every
+// session.
+func (ce *ContextExtractor) attribute(threads []*transcriptThread, sessionID string) []*transcriptThread {
+	policy := ce.attributionPolicy()
+
+	ours := func(thread *transcriptThread) bool {
+		return sessionID == "" || thread.key.sessionID == "" || thread.key.sessionID == sessionID
+	}
+
+	// Other sessions, or their subagents, that edited the committed files
+	editors := make(map[string]bool)
+	if policy == config.AttributionFiles {
+		for _, thread := range threads {
+			if !ours(thread) && thread.editedAny(ce.commitFiles) {
+				editors[thread.key.sessionID] = true
+			}
+		}
+	}
+
+	var attributed []*transcriptThread
+	for _, thread := range threads {
+		if thread.key.isSidechain() && policy == config.AttributionSession {
+			continue
+		}
+		if ours(thread) || editors[thread.key.sessionID] {
+			attributed = append(attributed, thread)
+		}
+	}
+
+	return attributed
+}
+
+// contains reports whether a string slice holds an item
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package context
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imjasonh/cnotes/internal/config"
+)
+
+func TestAttribution(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().Truncate(time.Second)
+
+	prompt := func(sessionID, agentID, text string) map[string]interface{} {
+		entry := map[string]interface{}{
+			"type":      "user",
+			"sessionId": sessionID,
+			"timestamp": now.Format(time.RFC3339),
+			"message":   map[string]interface{}{"content": text},
+		}
+		if agentID != "" {
+			entry["isSidechain"] = true
+			entry["agentId"] = agentID
+		}
+		return entry
+	}
+	edit := func(sessionID, path string) map[string]interface{} {
+		return map[string]interface{}{
+			"type":      "assistant",
+			"sessionId": sessionID,
+			"timestamp": now.Add(time.Second).Format(time.RFC3339),
+			"message": map[string]interface{}{"content": []map[string]interface{}{
+				{"type": "tool_use", "id": "toolu_" + sessionID, "name": "Edit", "input": map[string]interface{}{"file_path": path}},
+			}},
+		}
+	}
+
+	transcripts := map[string][]map[string]interface{}{
+		"s1.jsonl":       {prompt("s1", "", "Fix the parser")},
+		"agent-a1.jsonl": {prompt("s1", "a1", "Find the parser tests")},
+		"s2.jsonl":       {prompt("s2", "", "Unrelated work"), edit("s2", "/repo/other.go")},
+		"s3.jsonl":       {prompt("s3", "", "Parallel parser fix"), edit("s3", "/repo/parser.go")},
+	}
+	for name, entries := range transcripts {
+		var lines []string
+		for _, entry := range entries {
+			data, _ := json.Marshal(entry)
+			lines = append(lines, string(data))
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		policy    string
+		prompts   []string
+		subagents int
+		sessions  []string
+	}{
+		{config.AttributionSession, []string{"Fix the parser"}, 0, []string{"s1"}},
+		{config.AttributionSubagents, []string{"Fix the parser"}, 1, []string{"s1"}},
+		{config.AttributionFiles, []string{"Fix the parser", "Parallel parser fix"}, 1, []string{"s1", "s3"}},
+	} {
+		t.Run(tt.policy, func(t *testing.T) {
+			cfg := config.DefaultNotesConfig()
+			cfg.Attribution = tt.policy
+			ce := NewContextExtractor(cfg)
+			ce.UseCommitFiles([]string{"/repo/parser.go"})
+
+			context, err := ce.ExtractContextAfter(filepath.Join(dir, "s1.jsonl"), "s1", nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			prompts := append([]string(nil), context.UserPrompts...)
+			sort.Strings(prompts)
+			if strings.Join(prompts, "|") != strings.Join(tt.prompts, "|") {
+				t.Errorf("expected prompts %v, got %v", tt.prompts, prompts)
+			}
+
+			if len(context.Subagents) != tt.subagents {
+				t.Errorf("expected %d subagents, got %+v", tt.subagents, context.Subagents)
+			}
+
+			sessions := append([]string(nil), context.Sessions...)
+			sort.Strings(sessions)
+			if strings.Join(sessions, "|") != strings.Join(tt.sessions, "|") {
+				t.Errorf("expected sessions %v, got %v", tt.sessions, sessions)
+			}
+		})
+	}
+}
@@ -1,34 +1,96 @@
 package context
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-	"regexp"
-	"sort"
 	"strings"
 	"time"
 
 	"github.com/imjasonh/cnotes/internal/config"
+	"github.com/imjasonh/cnotes/internal/journal"
+	"github.com/imjasonh/cnotes/internal/redact"
 )
 
 // ConversationContext represents relevant conversation context for a commit
 type ConversationContext struct {
-	UserPrompts      []string            `json:"user_prompts"`
-	ClaudeResponses  []string            `json:"claude_responses"`
-	ToolInteractions []ToolInteraction   `json:"tool_interactions"`
-	Events           []ConversationEvent `json:"events"`          // New: chronological events
-	LastEventTime    time.Time           `json:"last_event_time"` // Track the latest event timestamp
+	UserPrompts      []string              `json:"user_prompts"`
+	ClaudeResponses  []string              `json:"claude_responses"`
+	ToolInteractions []ToolInteraction     `json:"tool_interactions"`
+	Events           []ConversationEvent   `json:"events"`                    // New: chronological events
+	LastEventTime    time.Time             `json:"last_event_time"`           // Track the latest event timestamp
+	Models           map[string]ModelStats `json:"models,omitempty"`          // Model name -> messages and tokens
+	FormatWarnings   []FormatWarning       `json:"format_warnings,omitempty"` // Transcript content the schema doesn't cover
+	Sessions         []string              `json:"sessions,omitempty"`        // Sessions the conversation was attributed from
+	Subagents        []SubagentThread      `json:"subagents,omitempty"`       // Conversations of the subagents Task tool uses started
+	Redactions       map[string]int        `json:"redactions,omitempty"`      // How many secrets each detection rule redacted from the events and subagents
+	Excluded         map[string]int        `json:"excluded,omitempty"`        // Events the relevance filter left out, by type
+}
+
+// ModelStats counts the assistant messages and tokens attributed to a model
+type ModelStats struct {
+	Messages            int `json:"messages"`
+	InputTokens         int `json:"input_tokens"`
+	OutputTokens        int `json:"output_tokens"`
+	CacheReadTokens     int `json:"cache_read_tokens"`
+	CacheCreationTokens int `json:"cache_creation_tokens"`
+}
+
+// Add sums another set of stats into s
+func (s *ModelStats) Add(other ModelStats) {
+	s.Messages += other.Messages
+	s.InputTokens += other.InputTokens
+	s.OutputTokens += other.OutputTokens
+	s.CacheReadTokens += other.CacheReadTokens
+	s.CacheCreationTokens += other.CacheCreationTokens
+}
+
+// addModelStats adds messages and tokens to a model's totals
+func (c *ConversationContext) addModelStats(model string, stats ModelStats) {
+	if c.Models == nil {
+		c.Models = make(map[string]ModelStats)
+	}
+	total := c.Models[model]
+	total.Add(stats)
+	c.Models[model] = total
+}
+
+// modelMessage is one API message seen in a transcript, possibly across
+// several entries
+type modelMessage struct {
+	model  string
+	usage  ModelStats
+	thread *transcriptThread
+}
+
+// stats converts the API's usage report into model stats; a message
+// without usage counts no tokens
+func (u *MessageUsage) stats() ModelStats {
+	if u == nil {
+		return ModelStats{}
+	}
+
+	return ModelStats{
+		InputTokens:         u.InputTokens,
+		OutputTokens:        u.OutputTokens,
+		CacheReadTokens:     u.CacheReadInputTokens,
+		CacheCreationTokens: u.CacheCreationInputTokens,
+	}
 }
 
 // ConversationEvent represents any event in the conversation
 type ConversationEvent struct {
+	ID        string    `json:"id,omitempty"` // Tells the event apart from others, so a note doesn't record it twice
 	Timestamp time.Time `json:"timestamp"`
 	Type      string    `json:"type"` // "user", "assistant", "tool", "system"
 	Content   string    `json:"content"`
 	ToolName  string    `json:"tool_name,omitempty"`
+	ToolUseID string    `json:"tool_use_id,omitempty"` // For tool and tool_result events, pairs a result with its tool use
+	IsError   bool      `json:"is_error,omitempty"`    // For tool_result events, whether the tool failed
 }
 
 // ToolInteraction represents a tool use and its result
@@ -36,35 +98,36 @@ type ToolInteraction struct {
 	Tool     string `json:"tool"`
 	Input    string `json:"input"`
 	Output   string `json:"output"`
-	Duration string `json:"duration,omitempty"`
+	IsError  bool   `json:"is_error,omitempty"`
+	Duration string `json:"duration,omitempty"` // Time from the tool use to its result
 }
 
 // ContextExtractor extracts relevant conversation context from transcripts
 type ContextExtractor struct {
-	maxExcerptLength  int
-	sensitivePatterns []*regexp.Regexp
-	config            *config.NotesConfig
+	maxExcerptLength int
+	redactor         *redact.Redactor
+	config           *config.NotesConfig
+	checkpoints      map[string]journal.TranscriptCheckpoint // By transcript path; nil reads transcripts in full
+	commitFiles      []string                                // Files the commit changed, for the files attribution policy and relevance filter
+}
+
+// UseCheckpoints makes the extractor resume reading transcripts from the
+// given checkpoints, updating them in place as it reads further
+func (ce *ContextExtractor) UseCheckpoints(checkpoints map[string]journal.TranscriptCheckpoint) {
+	ce.checkpoints = checkpoints
 }
 
 // NewContextExtractor creates a new context extractor with default settings
 func NewContextExtractor(cfg *config.NotesConfig) *ContextExtractor {
-	// Patterns to filter out sensitive information
-	sensitivePatterns := []*regexp.Regexp{
-		regexp.MustCompile(`(?i)(password|token|key|secret)[:\s]*[^\s\n]+`),
-		regexp.MustCompile(`(?i)(api[_-]?key)[:\s]*[^\s\n]+`),
-		regexp.MustCompile(`-----BEGIN [A-Z ]+-----`),  // Private keys
-		regexp.MustCompile(`[A-Za-z0-9+/]{40,}={0,2}`), // Base64 encoded secrets
-	}
-
 	maxLength := 5000
 	if cfg != nil && cfg.MaxExcerptLength > 0 {
 		maxLength = cfg.MaxExcerptLength
 	}
 
 	return &ContextExtractor{
-		maxExcerptLength:  maxLength,
-		sensitivePatterns: sensitivePatterns,
-		config:            cfg,
+		maxExcerptLength: maxLength,
+		redactor:         redact.FromConfig(cfg),
+		config:           cfg,
 	}
 }
 
@@ -75,357 +138,725 @@ func (ce *ContextExtractor) ExtractRecentContext(transcriptPath string, sessionI
 
 // ExtractContextSince extracts conversation context since a given timestamp
 func (ce *ContextExtractor) ExtractContextSince(transcriptPath string, sessionID string, since time.Time) (*ConversationContext, error) {
-	if transcriptPath == "" {
-		return &ConversationContext{}, nil
+	return ce.extractContext(transcriptPath, sessionID, cutoff{since: since})
+}
+
+// ExtractContextAfter extracts the conversation that followed a session's
+// previous commit: the events after the cursor, without the result of the tool
+// call that made that commit. Without a cursor, the session hasn't committed
+// yet and everything from the session's first entry is extracted.
+func (ce *ContextExtractor) ExtractContextAfter(transcriptPath string, sessionID string, cursor *journal.Cursor) (*ConversationContext, error) {
+	if cursor == nil {
+		return ce.extractContext(transcriptPath, sessionID, cutoff{since: sessionStart(transcriptPath, sessionID)})
 	}
 
-	// Get the directory containing transcripts
-	transcriptDir := filepath.Dir(transcriptPath)
+	return ce.extractContext(transcriptPath, sessionID, cutoff{
+		since:         cursor.Timestamp,
+		exclusive:     true,
+		skipToolUseID: cursor.ToolUseID,
+	})
+}
 
-	// Initialize combined context
-	combinedContext := &ConversationContext{
-		UserPrompts:      []string{},
-		ClaudeResponses:  []string{},
-		ToolInteractions: []ToolInteraction{},
-		Events:           []ConversationEvent{},
+// cutoff is where the conversation being extracted begins
+type cutoff struct {
+	since         time.Time // Entries before this are skipped; zero keeps everything
+	exclusive     bool      // Also skip entries at exactly since
+	skipToolUseID string    // Tool call whose result was already attributed
+}
+
+// excludes reports whether an entry at time t falls before the cutoff.
+// Entries without a timestamp are always kept.
+func (c cutoff) excludes(t time.Time) bool {
+	if c.since.IsZero() || t.IsZero() {
+		return false
+	}
+	if c.exclusive {
+		return !t.After(c.since)
 	}
+	return t.Before(c.since)
+}
 
-	// Read all transcript files in the directory
-	files, err := os.ReadDir(transcriptDir)
+// sessionStart returns the timestamp of a session's first entry in a
+// transcript, or the zero time if the transcript has none
+func sessionStart(transcriptPath, sessionID string) time.Time {
+	file, err := os.Open(transcriptPath)
 	if err != nil {
-		// If we can't read the directory, fall back to just the current transcript
-		return ce.extractFromSingleTranscript(transcriptPath, sessionID, since)
+		return time.Time{}
 	}
+	defer file.Close()
 
-	// Process each transcript file
-	for _, file := range files {
-		if !strings.HasSuffix(file.Name(), ".jsonl") {
-			continue
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if record, decodeErr := DecodeTranscriptEntry(bytes.TrimSpace(line)); decodeErr == nil {
+			if headed, ok := record.(interface{ Header() *EntryHeader }); ok {
+				header := headed.Header()
+				if t := header.Time(); !t.IsZero() && (sessionID == "" || header.SessionID == sessionID) {
+					return t
+				}
+			}
 		}
+		if err != nil {
+			return time.Time{}
+		}
+	}
+}
 
-		filePath := filepath.Join(transcriptDir, file.Name())
-		context, err := ce.extractFromSingleTranscript(filePath, "", since) // Empty sessionID to get all sessions
+// extractContext extracts the conversation after a cutoff from every
+// transcript in the transcript's directory, keeping the threads the
+// attribution policy credits to the session
+func (ce *ContextExtractor) extractContext(transcriptPath string, sessionID string, cut cutoff) (*ConversationContext, error) {
+	if transcriptPath == "" {
+		return &ConversationContext{}, nil
+	}
+
+	// Subagents write their own transcripts next to the session's, and
+	// other sessions' transcripts may have edited the committed files
+	transcriptDir := filepath.Dir(transcriptPath)
+	paths := []string{transcriptPath}
+	if files, err := os.ReadDir(transcriptDir); err == nil {
+		paths = nil
+		for _, file := range files {
+			if strings.HasSuffix(file.Name(), ".jsonl") {
+				paths = append(paths, filepath.Join(transcriptDir, file.Name()))
+			}
+		}
+	}
+
+	// Newer Claude Code releases keep subagent transcripts in a directory
+	// named after the session
+	subagentPaths, _ := filepath.Glob(filepath.Join(transcriptDir, "*", "subagents", "*.jsonl"))
+	paths = append(paths, subagentPaths...)
+
+	// Other sessions only matter if they can be credited with the commit
+	filter := sessionID
+	if ce.attributionPolicy() == config.AttributionFiles {
+		filter = ""
+	}
+
+	var threads []*transcriptThread
+	var formatWarnings []FormatWarning
+	for _, path := range paths {
+		parsed, err := ce.extractFromSingleTranscript(path, filter, cut)
 		if err != nil {
 			continue // Skip files that can't be read
 		}
-
-		// Merge contexts
-		combinedContext.UserPrompts = append(combinedContext.UserPrompts, context.UserPrompts...)
-		combinedContext.ClaudeResponses = append(combinedContext.ClaudeResponses, context.ClaudeResponses...)
-		combinedContext.ToolInteractions = append(combinedContext.ToolInteractions, context.ToolInteractions...)
-		combinedContext.Events = append(combinedContext.Events, context.Events...)
+		threads = append(threads, parsed.threads...)
+		formatWarnings = append(formatWarnings, parsed.formatWarnings...)
 	}
 
+	combinedContext := mergeThreads(ce.attribute(threads, sessionID))
+	combinedContext.FormatWarnings = formatWarnings
+
+	ce.filterRelevant(combinedContext)
+
 	// Apply privacy filters
 	combinedContext = ce.filterSensitiveContent(combinedContext)
 
 	return combinedContext, nil
 }
 
-// extractFromSingleTranscript extracts context from a single transcript file
-func (ce *ContextExtractor) extractFromSingleTranscript(transcriptPath string, sessionID string, since time.Time) (*ConversationContext, error) {
+// extractFromSingleTranscript extracts context from a single transcript file.
+// With checkpoints in use, it resumes where the previous extraction stopped
+// whenever everything of the session before that point falls before the
+// cutoff anyway.
+func (ce *ContextExtractor) extractFromSingleTranscript(transcriptPath string, sessionID string, cut cutoff) (*parsedTranscript, error) {
 	file, err := os.Open(transcriptPath)
 	if err != nil {
-		return &ConversationContext{}, nil
+		return &parsedTranscript{}, nil
 	}
 	defer file.Close()
 
-	content, err := io.ReadAll(file)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	var offset int64
+	checkpoint, ok := ce.checkpoints[transcriptPath]
+	if ok && !cut.since.IsZero() && !checkpointTimestamp(checkpoint, sessionID).After(cut.since) {
+		// A transcript shorter than the checkpoint was replaced; start over
+		if info, err := file.Stat(); err == nil && checkpoint.Offset <= info.Size() {
+			if _, err := file.Seek(checkpoint.Offset, io.SeekStart); err == nil {
+				offset = checkpoint.Offset
+			}
+		}
 	}
 
 	// Parse the transcript content
-	context := ce.parseTranscriptContent(string(content), sessionID, since)
+	parsed, progress, err := ce.parseTranscript(file, sessionID, cut)
+	if err != nil {
+		return nil, err
+	}
 
-	return context, nil
+	if ce.checkpoints != nil {
+		lastTimestamp := progress.lastTimestamp
+		sessions := progress.sessionTimestamps
+		if offset > 0 {
+			if checkpoint.LastTimestamp.After(lastTimestamp) {
+				lastTimestamp = checkpoint.LastTimestamp
+			}
+			for session, timestamp := range checkpoint.Sessions {
+				if timestamp.After(sessions[session]) {
+					sessions[session] = timestamp
+				}
+			}
+		}
+		ce.checkpoints[transcriptPath] = journal.TranscriptCheckpoint{
+			Offset:        offset + progress.bytes,
+			LastTimestamp: lastTimestamp,
+			Sessions:      sessions,
+		}
+	}
+
+	return parsed, nil
+}
+
+// checkpointTimestamp returns the latest timestamp before a checkpoint of the
+// entries an extraction for sessionID would keep, so that other sessions
+// writing to the same transcript don't keep it from resuming. Entries without
+// a session are kept for every session.
+func checkpointTimestamp(checkpoint journal.TranscriptCheckpoint, sessionID string) time.Time {
+	if sessionID == "" || checkpoint.Sessions == nil {
+		return checkpoint.LastTimestamp // Older checkpoints don't tell sessions apart
+	}
+
+	timestamp := checkpoint.Sessions[sessionID]
+	if unsessioned := checkpoint.Sessions[""]; unsessioned.After(timestamp) {
+		timestamp = unsessioned
+	}
+	return timestamp
 }
 
 // parseTranscriptContent parses transcript content and extracts conversation elements
 func (ce *ContextExtractor) parseTranscriptContent(content, sessionID string, since time.Time) *ConversationContext {
-	context := &ConversationContext{
-		UserPrompts:      []string{},
-		ClaudeResponses:  []string{},
-		ToolInteractions: []ToolInteraction{},
-		Events:           []ConversationEvent{},
-	}
+	parsed, _, _ := ce.parseTranscript(strings.NewReader(content), sessionID, cutoff{since: since})
+	return parsed.combined()
+}
 
-	lines := strings.Split(content, "\n")
+// transcriptProgress is how far parseTranscript got through a transcript
+type transcriptProgress struct {
+	bytes             int64                // Length of the complete lines parsed
+	lastTimestamp     time.Time            // Latest entry timestamp seen, cutoff or not
+	sessionTimestamps map[string]time.Time // Latest entry timestamp seen of each session, cutoff or not
+}
 
-	// Parse JSONL format
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
+// parseTranscript streams transcript lines from r, however long they are, and
+// extracts conversation elements thread by thread. A trailing partial line
+// that Claude Code is still writing is left unparsed and not counted in the
+// progress.
+func (ce *ContextExtractor) parseTranscript(r io.Reader, sessionID string, cut cutoff) (*parsedTranscript, transcriptProgress, error) {
+	parser := &transcriptParser{
+		ce:             ce,
+		sessionID:      sessionID,
+		cutoff:         cut,
+		transcript:     &parsedTranscript{},
+		messages:       make(map[string]*modelMessage),
+		pendingTools:   make(map[string]*pendingTool),
+		tasks:          make(map[string]*SubagentThread),
+		sidechainRoots: make(map[string]string),
+		drift:          NewDriftDetector(),
+
+		sessionTimestamps: make(map[string]time.Time),
+	}
 
-		// Parse each line as JSON
-		var entry map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &entry); err != nil {
-			continue // Skip invalid JSON lines
-		}
+	progress := transcriptProgress{sessionTimestamps: parser.sessionTimestamps}
+	reader := bufio.NewReader(r)
+	for lineNumber := 1; ; lineNumber++ {
+		raw, err := reader.ReadBytes('\n')
+		if len(raw) > 0 {
+			line := bytes.TrimSpace(raw)
+			if raw[len(raw)-1] != '\n' && len(line) > 0 && !json.Valid(line) {
+				break // Still being written
+			}
 
-		// Only process entries for the current session (unless sessionID is empty)
-		if sessionID != "" {
-			entrySessionID, _ := entry["sessionId"].(string)
-			if entrySessionID != "" && entrySessionID != sessionID {
-				continue
+			if len(line) > 0 {
+				if timestamp := parser.parseLine(lineNumber, line); timestamp.After(progress.lastTimestamp) {
+					progress.lastTimestamp = timestamp
+				}
 			}
+			progress.bytes += int64(len(raw))
 		}
 
-		// Extract timestamp
-		var entryTime time.Time
-		if timestampStr, ok := entry["timestamp"].(string); ok {
-			entryTime, _ = time.Parse(time.RFC3339, timestampStr)
+		if err == io.EOF {
+			break
 		}
+		if err != nil {
+			return nil, progress, fmt.Errorf("failed to read transcript: %w", err)
+		}
+	}
+
+	return parser.finish(), progress, nil
+}
+
+// transcriptParser holds the state of one parseTranscript run
+type transcriptParser struct {
+	ce         *ContextExtractor
+	sessionID  string
+	cutoff     cutoff
+	transcript *parsedTranscript
+
+	// Assistant messages by ID, in the order they first appeared
+	messageIDs []string
+	messages   map[string]*modelMessage
+
+	// Tool uses waiting for their result, by tool_use ID
+	pendingTools map[string]*pendingTool
+
+	// Task tool uses waiting for their subagent's result, by tool_use ID
+	tasks map[string]*SubagentThread
+
+	// The first entry of each sidechain, by the UUID of its entries
+	sidechainRoots map[string]string
+
+	// Compares each line against the schema to notice format changes
+	drift *DriftDetector
+
+	// Latest entry timestamp of each session, cutoff or not
+	sessionTimestamps map[string]time.Time
+}
+
+// parseLine adds one transcript line to the context and returns its timestamp
+func (p *transcriptParser) parseLine(lineNumber int, line []byte) time.Time {
+	record, err := DecodeTranscriptEntry(line)
+	p.drift.Check(lineNumber, line, record, err)
+	if err != nil || record == nil {
+		return time.Time{} // Skip lines we can't make sense of
+	}
+
+	// Entries that aren't part of the conversation itself carry no header
+	headed, ok := record.(interface{ Header() *EntryHeader })
+	if !ok {
+		return time.Time{}
+	}
+	header := headed.Header()
+	entryTime := header.Time()
+	key := p.threadKey(header)
+	if entryTime.After(p.sessionTimestamps[header.SessionID]) {
+		p.sessionTimestamps[header.SessionID] = entryTime
+	}
+
+	// Only process entries for the current session (unless sessionID is empty)
+	if p.sessionID != "" && header.SessionID != "" && header.SessionID != p.sessionID {
+		return entryTime
+	}
+
+	// Filter by timestamp if provided
+	if p.cutoff.excludes(entryTime) {
+		return entryTime // Skip entries before the cutoff
+	}
 
-		// Filter by timestamp if provided
-		if !since.IsZero() && !entryTime.IsZero() && entryTime.Before(since) {
-			continue // Skip entries before the cutoff
+	thread := p.transcript.thread(key)
+	context := thread.context
+
+	switch entry := record.(type) {
+	case *UserEntry:
+		// Direct string content is a prompt; block content can also carry
+		// the results of the previous assistant message's tool uses
+		blocks := entry.Message.Content.Blocks
+		if blocks == nil {
+			blocks = []ContentBlock{{Type: "text", Text: entry.Message.Content.Text}}
 		}
 
-		// Extract based on type
-		entryType, _ := entry["type"].(string)
-
-		switch entryType {
-		case "user":
-			// Extract user prompts
-			if msg, ok := entry["message"].(map[string]interface{}); ok {
-				// Handle both string content and array content formats
-				if content, ok := msg["content"].(string); ok && content != "" {
-					// Direct string content
-					if !strings.Contains(content, "[Request interrupted by user") {
-						context.UserPrompts = append(context.UserPrompts, content)
-						// Add to events
-						context.Events = append(context.Events, ConversationEvent{
-							Timestamp: entryTime,
-							Type:      "user",
-							Content:   content,
-						})
-					}
-				} else if contentArray, ok := msg["content"].([]interface{}); ok {
-					// Array of content objects
-					for _, c := range contentArray {
-						if textContent, ok := c.(map[string]interface{}); ok {
-							if text, ok := textContent["text"].(string); ok && text != "" {
-								// Skip system messages about interruptions
-								if !strings.Contains(text, "[Request interrupted by user") {
-									context.UserPrompts = append(context.UserPrompts, text)
-									// Add to events
-									context.Events = append(context.Events, ConversationEvent{
-										Timestamp: entryTime,
-										Type:      "user",
-										Content:   text,
-									})
-								}
-							}
-						}
-					}
+		for i, block := range blocks {
+			if block.Type == "tool_result" {
+				if block.ToolUseID != "" && block.ToolUseID == p.cutoff.skipToolUseID {
+					continue // Belongs to the previous commit
+				}
+				if task, ok := p.tasks[block.ToolUseID]; ok {
+					delete(p.tasks, block.ToolUseID)
+					finishTask(task, block, entry.ToolUseResult)
 				}
+				p.ce.addToolResult(context, p.pendingTools, block, entryTime)
+				continue
 			}
 
-		case "assistant":
-			// Extract tool uses and text responses from assistant messages
-			if msg, ok := entry["message"].(map[string]interface{}); ok {
-				if content, ok := msg["content"].([]interface{}); ok {
-					for _, c := range content {
-						if contentItem, ok := c.(map[string]interface{}); ok {
-							contentType, _ := contentItem["type"].(string)
-
-							switch contentType {
-							case "text":
-								// Assistant text response
-								if text, ok := contentItem["text"].(string); ok && text != "" {
-									context.ClaudeResponses = append(context.ClaudeResponses, text)
-									// Add to events
-									context.Events = append(context.Events, ConversationEvent{
-										Timestamp: entryTime,
-										Type:      "assistant",
-										Content:   text,
-									})
-								}
-
-							case "tool_use":
-								// Tool use
-								toolName, _ := contentItem["name"].(string)
-								if input, ok := contentItem["input"].(map[string]interface{}); ok {
-									interaction := ToolInteraction{
-										Tool: toolName,
-									}
-
-									// Extract key information based on tool type
-									switch toolName {
-									case "Bash":
-										if cmd, ok := input["command"].(string); ok {
-											interaction.Input = cmd
-										}
-									case "Write", "Edit", "MultiEdit":
-										if path, ok := input["file_path"].(string); ok {
-											interaction.Input = path
-										}
-									case "Read":
-										if path, ok := input["file_path"].(string); ok {
-											interaction.Input = path
-										}
-									case "WebFetch":
-										if url, ok := input["url"].(string); ok {
-											interaction.Input = url
-										}
-									default:
-										// For other tools, try to get a meaningful representation
-										if bytes, err := json.Marshal(input); err == nil {
-											interaction.Input = string(bytes)
-										}
-									}
-
-									if interaction.Input != "" {
-										context.ToolInteractions = append(context.ToolInteractions, interaction)
-										// Add to events
-										context.Events = append(context.Events, ConversationEvent{
-											Timestamp: entryTime,
-											Type:      "tool",
-											Content:   interaction.Input,
-											ToolName:  toolName,
-										})
-									}
-								}
-							}
-						}
-					}
-				}
+			// Skip system messages about interruptions
+			if block.Text == "" || strings.Contains(block.Text, "[Request interrupted by user") {
+				continue
 			}
+			context.UserPrompts = append(context.UserPrompts, block.Text)
+			context.Events = append(context.Events, ConversationEvent{
+				ID:        blockEventID(header, i),
+				Timestamp: entryTime,
+				Type:      "user",
+				Content:   block.Text,
+			})
+		}
 
-		case "tool_result":
-			// Extract tool results
-			if result, ok := entry["result"].(map[string]interface{}); ok {
-				var resultContent string
-				toolName, _ := entry["tool_name"].(string)
+	case *AssistantEntry:
+		// Extract tool uses and text responses from assistant messages
+		msg := entry.Message
+
+		// Claude Code writes one entry per content block, so count each
+		// API message once by its ID, keeping the usage from its last
+		// entry, which has the final output token count. "<synthetic>"
+		// marks messages Claude Code generated itself rather than a model.
+		if msg.Model != "" && msg.Model != "<synthetic>" {
+			messageID := msg.ID
+			if messageID == "" {
+				messageID = fmt.Sprintf("entry-%d", len(p.messageIDs))
+			}
+			if _, ok := p.messages[messageID]; !ok {
+				p.messageIDs = append(p.messageIDs, messageID)
+			}
+			p.messages[messageID] = &modelMessage{model: msg.Model, usage: msg.Usage.stats(), thread: thread}
+		}
 
-				if stdout, ok := result["stdout"].(string); ok && stdout != "" {
-					resultContent = stdout
-				} else if output, ok := result["output"].(string); ok && output != "" {
-					resultContent = output
+		for i, block := range msg.Content.Blocks {
+			switch block.Type {
+			case "text":
+				// Assistant text response
+				if block.Text != "" {
+					context.ClaudeResponses = append(context.ClaudeResponses, block.Text)
+					context.Events = append(context.Events, ConversationEvent{
+						ID:        blockEventID(header, i),
+						Timestamp: entryTime,
+						Type:      "assistant",
+						Content:   block.Text,
+					})
 				}
 
-				if resultContent != "" {
-					// Add to events
+			case "thinking":
+				// Claude's reasoning, only kept at the full privacy level
+				if block.Thinking != "" && p.ce.includeReasoning() {
 					context.Events = append(context.Events, ConversationEvent{
+						ID:        blockEventID(header, i),
 						Timestamp: entryTime,
-						Type:      "tool_result",
-						Content:   resultContent,
-						ToolName:  toolName,
+						Type:      "thinking",
+						Content:   block.Thinking,
 					})
 				}
+
+			case "tool_use":
+				if block.Input == nil {
+					continue
+				}
+				interaction := ToolInteraction{
+					Tool:  block.Name,
+					Input: summarizeToolInput(block.Name, block.Input),
+				}
+				if interaction.Input == "" {
+					continue
+				}
+
+				thread.editedFile(block.Name, block.Input)
+				if isTaskTool(block.Name) && block.ID != "" {
+					p.tasks[block.ID] = thread.startTask(block)
+				}
+				context.ToolInteractions = append(context.ToolInteractions, interaction)
+				id := block.ID
+				if id == "" {
+					id = blockEventID(header, i)
+				}
+				context.Events = append(context.Events, ConversationEvent{
+					ID:        id,
+					Timestamp: entryTime,
+					Type:      "tool",
+					Content:   interaction.Input,
+					ToolName:  block.Name,
+					ToolUseID: block.ID,
+				})
+				if block.ID != "" {
+					p.pendingTools[block.ID] = &pendingTool{
+						context:   context,
+						index:     len(context.ToolInteractions) - 1,
+						name:      block.Name,
+						startedAt: entryTime,
+					}
+				}
 			}
 		}
+
+	case *ToolResultEntry:
+		// Older transcripts recorded tool output as a top-level entry
+		var result struct {
+			Stdout string `json:"stdout"`
+			Output string `json:"output"`
+		}
+		if err := json.Unmarshal(entry.Result, &result); err != nil {
+			return entryTime
+		}
+
+		resultContent := result.Stdout
+		if resultContent == "" {
+			resultContent = result.Output
+		}
+
+		if resultContent != "" && p.ce.includeToolOutput() {
+			context.Events = append(context.Events, ConversationEvent{
+				ID:        header.UUID,
+				Timestamp: entryTime,
+				Type:      "tool_result",
+				Content:   resultContent,
+				ToolName:  entry.ToolName,
+			})
+		}
+	}
+
+	return entryTime
+}
+
+// blockEventID identifies the event from one content block of a transcript
+// entry, or returns "" if the entry has no UUID to go by
+func blockEventID(header *EntryHeader, block int) string {
+	if header.UUID == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%d", header.UUID, block)
+}
+
+// toolResultID identifies the result of a tool use, or returns "" if the tool
+// use has no ID
+func toolResultID(toolUseID string) string {
+	if toolUseID == "" {
+		return ""
+	}
+	return toolUseID + "/result"
+}
+
+// threadKey returns the thread a transcript entry belongs to. Older
+// transcripts don't name the subagent that wrote a sidechain entry, so those
+// sidechains are told apart by the entry each one started from.
+func (p *transcriptParser) threadKey(header *EntryHeader) threadKey {
+	key := threadKey{sessionID: header.SessionID}
+	if !header.IsSidechain {
+		return key
+	}
+
+	if header.AgentID != "" {
+		key.agentID = header.AgentID
+		return key
+	}
+
+	root, ok := p.sidechainRoots[header.ParentUUID]
+	if !ok || header.ParentUUID == "" {
+		root = header.UUID
+	}
+	if header.UUID != "" {
+		p.sidechainRoots[header.UUID] = root
+	}
+
+	key.sidechain = root
+	if key.sidechain == "" {
+		key.sidechain = "sidechain"
+	}
+	return key
+}
+
+// finish totals each thread's model usage and returns the parsed transcript
+func (p *transcriptParser) finish() *parsedTranscript {
+	for _, id := range p.messageIDs {
+		message := p.messages[id]
+		message.usage.Messages = 1
+		message.thread.context.addModelStats(message.model, message.usage)
 	}
 
+	p.transcript.formatWarnings = p.drift.Warnings()
+
 	// Track the last event time from all events
-	for _, event := range context.Events {
-		if event.Timestamp.After(context.LastEventTime) {
-			context.LastEventTime = event.Timestamp
+	for _, thread := range p.transcript.threads {
+		context := thread.context
+		for _, event := range context.Events {
+			if event.Timestamp.After(context.LastEventTime) {
+				context.LastEventTime = event.Timestamp
+			}
 		}
 	}
 
-	return context
+	return p.transcript
+}
+
+// pendingTool is a tool use whose result hasn't been seen yet
+type pendingTool struct {
+	context   *ConversationContext // Context of the thread that used the tool
+	index     int                  // Position in its ToolInteractions
+	name      string
+	startedAt time.Time
+}
+
+// addToolResult pairs a tool_result block with the tool use it answers,
+// filling in the interaction's output, error status and duration
+func (ce *ContextExtractor) addToolResult(context *ConversationContext, pendingTools map[string]*pendingTool, block ContentBlock, resultTime time.Time) {
+	var output string
+	if block.Content != nil {
+		output = block.Content.String()
+	}
+
+	var toolName string
+	if pending, ok := pendingTools[block.ToolUseID]; ok {
+		delete(pendingTools, block.ToolUseID)
+		toolName = pending.name
+
+		interaction := &pending.context.ToolInteractions[pending.index]
+		interaction.Output = output
+		interaction.IsError = block.IsError
+		if !pending.startedAt.IsZero() && !resultTime.IsZero() && !resultTime.Before(pending.startedAt) {
+			interaction.Duration = resultTime.Sub(pending.startedAt).String()
+		}
+	}
+
+	if output == "" || !ce.includeToolOutput() {
+		return
+	}
+
+	context.Events = append(context.Events, ConversationEvent{
+		ID:        toolResultID(block.ToolUseID),
+		Timestamp: resultTime,
+		Type:      "tool_result",
+		Content:   output,
+		ToolName:  toolName,
+		ToolUseID: block.ToolUseID,
+		IsError:   block.IsError,
+	})
+}
+
+// summarizeToolInput extracts the key information from a tool's input based on tool type
+func summarizeToolInput(toolName string, input map[string]interface{}) string {
+	switch toolName {
+	case "Bash":
+		if cmd, ok := input["command"].(string); ok {
+			return cmd
+		}
+	case "Write", "Edit", "MultiEdit", "Read":
+		if path, ok := input["file_path"].(string); ok {
+			return path
+		}
+	case "NotebookEdit":
+		if path, ok := input["notebook_path"].(string); ok {
+			return path
+		}
+	case "Task", "Agent":
+		if description, ok := input["description"].(string); ok {
+			return description
+		}
+	case "WebFetch":
+		if url, ok := input["url"].(string); ok {
+			return url
+		}
+	default:
+		// For other tools, try to get a meaningful representation
+		if bytes, err := json.Marshal(input); err == nil {
+			return string(bytes)
+		}
+	}
+	return ""
 }
 
-// filterSensitiveContent removes sensitive information from context
+// filterSensitiveContent removes sensitive information from context: what
+// the privacy level leaves out, then secrets. Only what a note stores, its
+// events and subagents, counts towards Redactions.
 func (ce *ContextExtractor) filterSensitiveContent(context *ConversationContext) *ConversationContext {
+	ce.applyPrivacyLevel(context)
+	ce.limitPrompts(context)
+
 	// Filter user prompts
 	for i, prompt := range context.UserPrompts {
-		context.UserPrompts[i] = ce.sanitizeText(prompt)
+		context.UserPrompts[i] = ce.sanitizeText(prompt, nil)
 	}
 
 	// Filter Claude responses
 	for i, response := range context.ClaudeResponses {
-		context.ClaudeResponses[i] = ce.sanitizeText(response)
+		context.ClaudeResponses[i] = ce.sanitizeText(response, nil)
 	}
 
 	// Filter tool interactions
 	for i, interaction := range context.ToolInteractions {
-		context.ToolInteractions[i].Input = ce.sanitizeText(interaction.Input)
-		context.ToolInteractions[i].Output = ce.sanitizeText(interaction.Output)
+		context.ToolInteractions[i].Input = ce.sanitizeText(interaction.Input, nil)
+		context.ToolInteractions[i].Output = ce.sanitizeText(interaction.Output, nil)
+	}
+
+	redactions := make(map[string]int)
+
+	// Filter the events the excerpt is built from
+	ce.sanitizeEvents(context.Events, redactions)
+
+	// Filter what subagents were asked, did and reported
+	for i, subagent := range context.Subagents {
+		context.Subagents[i].Description = ce.sanitizeText(subagent.Description, redactions)
+		context.Subagents[i].Prompt = ce.sanitizeText(subagent.Prompt, redactions)
+		context.Subagents[i].Result = ce.sanitizeText(subagent.Result, redactions)
+		ce.sanitizeEvents(subagent.Events, redactions)
+	}
+
+	if len(redactions) > 0 {
+		context.Redactions = redactions
 	}
 
 	return context
 }
 
-// sanitizeText removes sensitive patterns from text
-func (ce *ContextExtractor) sanitizeText(text string) string {
-	for _, pattern := range ce.sensitivePatterns {
-		text = pattern.ReplaceAllString(text, "[REDACTED]")
+// sanitizeEvents removes sensitive patterns from each event's content
+func (ce *ContextExtractor) sanitizeEvents(events []ConversationEvent, redactions map[string]int) {
+	for i := range events {
+		events[i].Content = ce.sanitizeText(events[i].Content, redactions)
 	}
-	return text
 }
 
-// CreateExcerpt creates a concise excerpt from conversation context
-func (ce *ContextExtractor) CreateExcerpt(context *ConversationContext) string {
-	// Sort events by timestamp
-	sort.Slice(context.Events, func(i, j int) bool {
-		return context.Events[i].Timestamp.Before(context.Events[j].Timestamp)
-	})
+// sanitizeText removes sensitive patterns from text, counting the secrets
+// each rule found in redactions unless it's nil
+func (ce *ContextExtractor) sanitizeText(text string, redactions map[string]int) string {
+	redacted, findings := ce.redactor.RedactFindings(text)
+	if redactions != nil {
+		redact.Count(redactions, findings)
+	}
+	return redacted
+}
 
-	var parts []string
-	for _, event := range context.Events {
-		var line string
+// CreateExcerpt creates a concise excerpt from conversation context, fitting
+// it into the maximum excerpt length as described by fitLines
+func (ce *ContextExtractor) CreateExcerpt(context *ConversationContext) string {
+	lines := ce.excerptLines(context)
+	kept := ce.fitLines(lines)
 
-		switch event.Type {
-		case "user":
-			// Format user prompts
-			content := event.Content
-			if len(content) > 200 {
-				content = content[:197] + "..."
-			}
-			emoji := "👤"
-			if ce.config != nil && ce.config.UserEmoji != "" {
-				emoji = ce.config.UserEmoji
-			}
-			line = fmt.Sprintf("%s User: %s", emoji, content)
+	// Too many lines to keep even cut short
+	return truncateBytes(strings.Join(excerptParts(lines, kept), "\n\n"), ce.maxExcerptLength)
+}
 
-		case "assistant":
-			// Format assistant responses
-			content := event.Content
-			if len(content) > 200 {
-				content = content[:197] + "..."
-			}
-			emoji := "🤖"
-			if ce.config != nil && ce.config.AssistantEmoji != "" {
-				emoji = ce.config.AssistantEmoji
-			}
-			line = fmt.Sprintf("%s Claude: %s", emoji, content)
+// cutEvent cuts an event's content to its type's limit
+func (ce *ContextExtractor) cutEvent(event ConversationEvent) ConversationEvent {
+	if event.Type == "tool_result" {
+		// Show abbreviated output
+		lines := strings.Split(event.Content, "\n")
+		if len(lines) > 3 {
+			event.Content = strings.Join(lines[:3], "\n") + "\n[...]"
+			return event
+		}
+	}
+	event.Content = truncateRunes(event.Content, ce.eventLimit(event.Type))
+	return event
+}
 
-		case "tool":
-			// Format tool uses
-			content := event.Content
-			if len(content) > 150 {
-				content = content[:147] + "..."
-			}
-			line = fmt.Sprintf("Tool (%s): %s", event.ToolName, content)
-
-		case "tool_result":
-			// Format tool results - show abbreviated output
-			content := event.Content
-			lines := strings.Split(content, "\n")
-			if len(lines) > 3 {
-				content = strings.Join(lines[:3], "\n") + "\n[...]"
-			} else if len(content) > 150 {
-				content = content[:147] + "..."
-			}
-			line = fmt.Sprintf("Result: %s", content)
+// formatEvent renders an event as a line of the excerpt
+func (ce *ContextExtractor) formatEvent(event ConversationEvent) string {
+	switch event.Type {
+	case "user":
+		// Format user prompts
+		emoji := "👤"
+		if ce.config != nil && ce.config.UserEmoji != "" {
+			emoji = ce.config.UserEmoji
 		}
+		return fmt.Sprintf("%s User: %s", emoji, event.Content)
 
-		if line != "" {
-			parts = append(parts, line)
+	case "assistant":
+		// Format assistant responses
+		emoji := "🤖"
+		if ce.config != nil && ce.config.AssistantEmoji != "" {
+			emoji = ce.config.AssistantEmoji
 		}
-	}
+		return fmt.Sprintf("%s Claude: %s", emoji, event.Content)
+
+	case "thinking":
+		// Format Claude's reasoning
+		return fmt.Sprintf("💭 Thinking: %s", event.Content)
 
-	excerpt := strings.Join(parts, "\n\n")
+	case "tool":
+		// Format tool uses
+		return fmt.Sprintf("Tool (%s): %s", event.ToolName, event.Content)
 
-	// Truncate if too long
-	if len(excerpt) > ce.maxExcerptLength {
-		excerpt = excerpt[:ce.maxExcerptLength-3] + "..."
+	case "tool_result":
+		// Format tool results
+		label := "Result"
+		if event.IsError {
+			label = "Error"
+		}
+		return fmt.Sprintf("%s: %s", label, event.Content)
 	}
 
-	return excerpt
+	return ""
 }
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/imjasonh/cnotes/internal/config"
+	"github.com/imjasonh/cnotes/internal/journal"
 )
 
 func TestNewContextExtractor(t *testing.T) {
@@ -20,8 +21,8 @@ func TestNewContextExtractor(t *testing.T) {
 			t.Errorf("expected default maxExcerptLength 5000, got %d", ce.maxExcerptLength)
 		}
 
-		if len(ce.sensitivePatterns) == 0 {
-			t.Error("expected sensitive patterns to be initialized")
+		if ce.redactor == nil {
+			t.Error("expected redactor to be initialized")
 		}
 	})
 
@@ -320,7 +321,7 @@ func TestSanitizeText(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := ce.sanitizeText(tt.input)
+			result := ce.sanitizeText(tt.input, nil)
 
 			if tt.exact != "" {
 				// Exact match
@@ -458,10 +459,11 @@ func TestExtractFromSingleTranscript(t *testing.T) {
 	ce := NewContextExtractor(nil)
 
 	t.Run("extract from existing file", func(t *testing.T) {
-		context, err := ce.extractFromSingleTranscript(transcriptPath, "test-session", time.Time{})
+		parsed, err := ce.extractFromSingleTranscript(transcriptPath, "test-session", cutoff{})
 		if err != nil {
 			t.Fatalf("failed to extract: %v", err)
 		}
+		context := parsed.combined()
 
 		if len(context.UserPrompts) != 1 {
 			t.Errorf("expected 1 user prompt, got %d", len(context.UserPrompts))
@@ -469,10 +471,11 @@ func TestExtractFromSingleTranscript(t *testing.T) {
 	})
 
 	t.Run("handle non-existent file", func(t *testing.T) {
-		context, err := ce.extractFromSingleTranscript("/non/existent/file.jsonl", "", time.Time{})
+		parsed, err := ce.extractFromSingleTranscript("/non/existent/file.jsonl", "", cutoff{})
 		if err != nil {
 			t.Fatalf("expected no error for non-existent file, got: %v", err)
 		}
+		context := parsed.combined()
 
 		if len(context.UserPrompts) != 0 {
 			t.Error("expected empty context for non-existent file")
@@ -557,6 +560,10 @@ func TestExtractContextSince(t *testing.T) {
 		if context.UserPrompts[0] != "Message from file 2" {
 			t.Errorf("unexpected message after cutoff: %s", context.UserPrompts[0])
 		}
+
+		if context.LastEventTime.Unix() != now.Add(2*time.Hour).Unix() {
+			t.Errorf("expected LastEventTime from file 2, got %v", context.LastEventTime)
+		}
 	})
 
 	t.Run("handle empty transcript path", func(t *testing.T) {
@@ -576,7 +583,7 @@ func TestFilterSensitiveContent(t *testing.T) {
 
 	context := &ConversationContext{
 		UserPrompts: []string{
-			"My password is secret123",
+			`My password is "secret123"`,
 			"Clean prompt",
 		},
 		ClaudeResponses: []string{
@@ -614,6 +621,46 @@ func TestFilterSensitiveContent(t *testing.T) {
 	}
 }
 
+func TestExcerptRedaction(t *testing.T) {
+	cfg := config.DefaultNotesConfig()
+	cfg.ExcludePatterns = append(cfg.ExcludePatterns, "dbpass")
+	ce := NewContextExtractor(cfg)
+
+	now := time.Now()
+	context := &ConversationContext{
+		Events: []ConversationEvent{
+			{Type: "user", Timestamp: now, Content: "Connect with DBPASS=hunter2"},
+			{Type: "tool_use", Timestamp: now.Add(time.Second), ToolName: "Bash", Content: "export GITHUB_TOKEN=ghp_abc123"},
+			{Type: "assistant", Timestamp: now.Add(2 * time.Second), Content: `Done, the secret is "xyzzy"`},
+		},
+		Subagents: []SubagentThread{{
+			Prompt: "Use password: swordfish",
+			Events: []ConversationEvent{{Type: "assistant", Content: "Logged in with password: swordfish"}},
+		}},
+	}
+
+	filtered := ce.filterSensitiveContent(context)
+	excerpt := ce.CreateExcerpt(filtered)
+
+	for _, secret := range []string{"hunter2", "ghp_abc123", "xyzzy"} {
+		if strings.Contains(excerpt, secret) {
+			t.Errorf("excerpt contains %q: %s", secret, excerpt)
+		}
+	}
+	if !strings.Contains(excerpt, "DBPASS=[REDACTED]") {
+		t.Errorf("expected custom exclude pattern to keep the name and redact the value, got: %s", excerpt)
+	}
+
+	if filtered.Redactions["keyword:dbpass"] != 1 || filtered.Redactions["keyword:token"] != 1 || filtered.Redactions["keyword:password"] != 2 {
+		t.Errorf("unexpected redaction counts: %v", filtered.Redactions)
+	}
+
+	subagent := filtered.Subagents[0]
+	if strings.Contains(subagent.Prompt, "swordfish") || strings.Contains(subagent.Events[0].Content, "swordfish") {
+		t.Errorf("subagent thread not redacted: %+v", subagent)
+	}
+}
+
 func TestLastEventTimeTracking(t *testing.T) {
 	ce := NewContextExtractor(nil)
 
@@ -661,3 +708,594 @@ func TestLastEventTimeTracking(t *testing.T) {
 		t.Errorf("expected LastEventTime to be %v, got %v", laterTime, context.LastEventTime)
 	}
 }
+
+func TestModelTracking(t *testing.T) {
+	ce := NewContextExtractor(nil)
+	now := time.Now()
+
+	entries := []map[string]interface{}{
+		{
+			"type":      "assistant",
+			"timestamp": now.Format(time.RFC3339),
+			"message": map[string]interface{}{
+				"id":    "msg_1",
+				"model": "claude-opus-4-1-20250805",
+				"content": []interface{}{
+					map[string]interface{}{"type": "text", "text": "Let me look."},
+				},
+			},
+		},
+		{
+			// Second content block of the same API message
+			"type":      "assistant",
+			"timestamp": now.Format(time.RFC3339),
+			"message": map[string]interface{}{
+				"id":    "msg_1",
+				"model": "claude-opus-4-1-20250805",
+				"content": []interface{}{
+					map[string]interface{}{"type": "tool_use", "name": "Read", "input": map[string]interface{}{"file_path": "main.go"}},
+				},
+			},
+		},
+		{
+			"type":      "assistant",
+			"timestamp": now.Add(time.Second).Format(time.RFC3339),
+			"message": map[string]interface{}{
+				"id":    "msg_2",
+				"model": "claude-3-5-haiku-20241022",
+				"content": []interface{}{
+					map[string]interface{}{"type": "text", "text": "Summary"},
+				},
+			},
+		},
+		{
+			"type":      "assistant",
+			"timestamp": now.Add(2 * time.Second).Format(time.RFC3339),
+			"message": map[string]interface{}{
+				"id":    "msg_3",
+				"model": "<synthetic>",
+				"content": []interface{}{
+					map[string]interface{}{"type": "text", "text": "No response requested."},
+				},
+			},
+		},
+	}
+
+	var lines []string
+	for _, entry := range entries {
+		data, _ := json.Marshal(entry)
+		lines = append(lines, string(data))
+	}
+
+	context := ce.parseTranscriptContent(strings.Join(lines, "\n"), "", time.Time{})
+
+	if len(context.Models) != 2 {
+		t.Fatalf("expected 2 models, got %v", context.Models)
+	}
+
+	if context.Models["claude-opus-4-1-20250805"].Messages != 1 {
+		t.Errorf("expected 1 opus message, got %d", context.Models["claude-opus-4-1-20250805"].Messages)
+	}
+
+	if context.Models["claude-3-5-haiku-20241022"].Messages != 1 {
+		t.Errorf("expected 1 haiku message, got %d", context.Models["claude-3-5-haiku-20241022"].Messages)
+	}
+
+	// Models outside the requested slice are not counted
+	context = ce.parseTranscriptContent(strings.Join(lines, "\n"), "", now.Add(500*time.Millisecond))
+	if _, ok := context.Models["claude-opus-4-1-20250805"]; ok {
+		t.Error("expected model from before the cutoff to be excluded")
+	}
+}
+
+func TestTokenUsage(t *testing.T) {
+	ce := NewContextExtractor(nil)
+	now := time.Now().Truncate(time.Second)
+
+	usage := func(input, output, cacheRead, cacheCreation int) map[string]interface{} {
+		return map[string]interface{}{
+			"input_tokens":                input,
+			"output_tokens":               output,
+			"cache_read_input_tokens":     cacheRead,
+			"cache_creation_input_tokens": cacheCreation,
+		}
+	}
+
+	entries := []map[string]interface{}{
+		{
+			// First content block of msg_1, written before output finished
+			"type":      "assistant",
+			"timestamp": now.Format(time.RFC3339),
+			"message": map[string]interface{}{
+				"id":    "msg_1",
+				"model": "claude-sonnet-4-20250514",
+				"usage": usage(10, 1, 1000, 200),
+				"content": []interface{}{
+					map[string]interface{}{"type": "text", "text": "Let me look"},
+				},
+			},
+		},
+		{
+			"type":      "assistant",
+			"timestamp": now.Format(time.RFC3339),
+			"message": map[string]interface{}{
+				"id":    "msg_1",
+				"model": "claude-sonnet-4-20250514",
+				"usage": usage(10, 50, 1000, 200),
+				"content": []interface{}{
+					map[string]interface{}{"type": "tool_use", "name": "Bash", "input": map[string]interface{}{"command": "ls"}},
+				},
+			},
+		},
+		{
+			"type":      "assistant",
+			"timestamp": now.Add(time.Second).Format(time.RFC3339),
+			"message": map[string]interface{}{
+				"id":    "msg_2",
+				"model": "claude-sonnet-4-20250514",
+				"usage": usage(5, 20, 1200, 0),
+				"content": []interface{}{
+					map[string]interface{}{"type": "text", "text": "Done"},
+				},
+			},
+		},
+	}
+
+	var lines []string
+	for _, entry := range entries {
+		data, _ := json.Marshal(entry)
+		lines = append(lines, string(data))
+	}
+
+	context := ce.parseTranscriptContent(strings.Join(lines, "\n"), "", time.Time{})
+
+	expected := ModelStats{
+		Messages:            2,
+		InputTokens:         15,
+		OutputTokens:        70,
+		CacheReadTokens:     2200,
+		CacheCreationTokens: 200,
+	}
+	if got := context.Models["claude-sonnet-4-20250514"]; got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestToolResultPairing(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	entries := []map[string]interface{}{
+		{
+			"type":      "user",
+			"timestamp": now.Format(time.RFC3339),
+			"message":   map[string]interface{}{"role": "user", "content": "Run the tests"},
+		},
+		{
+			"type":      "assistant",
+			"timestamp": now.Add(time.Second).Format(time.RFC3339),
+			"message": map[string]interface{}{
+				"role": "assistant",
+				"content": []interface{}{
+					map[string]interface{}{"type": "tool_use", "id": "toolu_1", "name": "Bash", "input": map[string]interface{}{"command": "go test ./..."}},
+					map[string]interface{}{"type": "tool_use", "id": "toolu_2", "name": "Read", "input": map[string]interface{}{"file_path": "/missing.go"}},
+				},
+			},
+		},
+		{
+			"type":      "user",
+			"timestamp": now.Add(4 * time.Second).Format(time.RFC3339),
+			"message": map[string]interface{}{
+				"role": "user",
+				"content": []interface{}{
+					map[string]interface{}{"type": "tool_result", "tool_use_id": "toolu_1", "content": "ok  \texample.com/pkg"},
+				},
+			},
+		},
+		{
+			"type":      "user",
+			"timestamp": now.Add(5 * time.Second).Format(time.RFC3339),
+			"message": map[string]interface{}{
+				"role": "user",
+				"content": []interface{}{
+					map[string]interface{}{
+						"type":        "tool_result",
+						"tool_use_id": "toolu_2",
+						"is_error":    true,
+						"content": []interface{}{
+							map[string]interface{}{"type": "text", "text": "File does not exist."},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var lines []string
+	for _, entry := range entries {
+		data, _ := json.Marshal(entry)
+		lines = append(lines, string(data))
+	}
+	content := strings.Join(lines, "\n")
+
+	t.Run("results pair with tool uses", func(t *testing.T) {
+		ce := NewContextExtractor(nil)
+		context := ce.parseTranscriptContent(content, "", time.Time{})
+
+		// Tool results are not user prompts
+		if len(context.UserPrompts) != 1 || context.UserPrompts[0] != "Run the tests" {
+			t.Errorf("unexpected user prompts: %v", context.UserPrompts)
+		}
+
+		if len(context.ToolInteractions) != 2 {
+			t.Fatalf("expected 2 tool interactions, got %d", len(context.ToolInteractions))
+		}
+
+		bash := context.ToolInteractions[0]
+		if bash.Output != "ok  \texample.com/pkg" || bash.IsError || bash.Duration != "3s" {
+			t.Errorf("unexpected Bash interaction: %+v", bash)
+		}
+
+		read := context.ToolInteractions[1]
+		if read.Output != "File does not exist." || !read.IsError || read.Duration != "4s" {
+			t.Errorf("unexpected Read interaction: %+v", read)
+		}
+
+		var results []ConversationEvent
+		for _, event := range context.Events {
+			if event.Type == "tool_result" {
+				results = append(results, event)
+			}
+		}
+		if len(results) != 2 || results[0].ToolName != "Bash" || !results[1].IsError {
+			t.Errorf("unexpected tool result events: %+v", results)
+		}
+
+		excerpt := ce.CreateExcerpt(context)
+		if !strings.Contains(excerpt, "Error: File does not exist.") {
+			t.Errorf("expected failed tool result in excerpt, got %q", excerpt)
+		}
+	})
+
+	t.Run("output left out of events by default", func(t *testing.T) {
+		ce := NewContextExtractor(config.DefaultNotesConfig())
+		context := ce.parseTranscriptContent(content, "", time.Time{})
+
+		for _, event := range context.Events {
+			if event.Type == "tool_result" {
+				t.Errorf("expected no tool result events, got %+v", event)
+			}
+		}
+
+		// The interaction still knows how the tool went
+		if context.ToolInteractions[1].Output != "File does not exist." {
+			t.Errorf("expected output on the interaction, got %+v", context.ToolInteractions[1])
+		}
+	})
+}
+
+func TestParseTranscriptStreaming(t *testing.T) {
+	ce := NewContextExtractor(nil)
+	now := time.Now().Truncate(time.Second)
+
+	longPrompt := strings.Repeat("x", 1<<20)
+	first, _ := json.Marshal(map[string]interface{}{
+		"type":      "user",
+		"timestamp": now.Format(time.RFC3339),
+		"message":   map[string]interface{}{"content": longPrompt},
+	})
+	second, _ := json.Marshal(map[string]interface{}{
+		"type":      "user",
+		"timestamp": now.Add(time.Second).Format(time.RFC3339),
+		"message":   map[string]interface{}{"content": "Short prompt"},
+	})
+
+	t.Run("very long lines", func(t *testing.T) {
+		content := string(first) + "\n" + string(second) + "\n"
+		parsed, progress, err := ce.parseTranscript(strings.NewReader(content), "", cutoff{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		context := parsed.combined()
+
+		if len(context.UserPrompts) != 2 || context.UserPrompts[0] != longPrompt {
+			t.Errorf("expected both prompts, got %d", len(context.UserPrompts))
+		}
+
+		if progress.bytes != int64(len(content)) {
+			t.Errorf("expected %d bytes parsed, got %d", len(content), progress.bytes)
+		}
+
+		if !progress.lastTimestamp.Equal(now.Add(time.Second)) {
+			t.Errorf("unexpected last timestamp %v", progress.lastTimestamp)
+		}
+	})
+
+	t.Run("partial trailing line", func(t *testing.T) {
+		content := string(second) + "\n" + string(first[:100])
+		parsed, progress, err := ce.parseTranscript(strings.NewReader(content), "", cutoff{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		context := parsed.combined()
+
+		if len(context.UserPrompts) != 1 {
+			t.Errorf("expected only the complete line, got %d prompts", len(context.UserPrompts))
+		}
+
+		if progress.bytes != int64(len(second)+1) {
+			t.Errorf("expected the partial line to be left for later, got %d bytes", progress.bytes)
+		}
+	})
+}
+
+func TestTranscriptCheckpoints(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.jsonl")
+	now := time.Now().Truncate(time.Second)
+
+	line := func(offset time.Duration, prompt string) string {
+		data, _ := json.Marshal(map[string]interface{}{
+			"type":      "user",
+			"timestamp": now.Add(offset).Format(time.RFC3339),
+			"message":   map[string]interface{}{"content": prompt},
+		})
+		return string(data) + "\n"
+	}
+
+	initial := line(0, "First prompt") + line(time.Second, "Second prompt")
+	if err := os.WriteFile(path, []byte(initial), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoints := make(map[string]journal.TranscriptCheckpoint)
+	ce := NewContextExtractor(nil)
+	ce.UseCheckpoints(checkpoints)
+
+	context, err := ce.ExtractContextSince(path, "", time.Time{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(context.UserPrompts) != 2 {
+		t.Fatalf("expected 2 prompts, got %d", len(context.UserPrompts))
+	}
+
+	checkpoint := checkpoints[path]
+	if checkpoint.Offset != int64(len(initial)) || !checkpoint.LastTimestamp.Equal(now.Add(time.Second)) {
+		t.Fatalf("unexpected checkpoint %+v", checkpoint)
+	}
+
+	// Scribble over the parsed bytes: a resumed read must not look at them again
+	appended := line(2*time.Second, "Third prompt")
+	scribbled := strings.Repeat("#", len(initial)-1) + "\n" + appended
+	if err := os.WriteFile(path, []byte(scribbled), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("resume after the checkpoint", func(t *testing.T) {
+		context, err := ce.ExtractContextSince(path, "", now.Add(time.Second))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(context.UserPrompts) != 1 || context.UserPrompts[0] != "Third prompt" {
+			t.Errorf("expected only the appended prompt, got %v", context.UserPrompts)
+		}
+
+		if len(context.FormatWarnings) != 0 {
+			t.Errorf("expected the parsed bytes to be skipped, got warnings %v", context.FormatWarnings)
+		}
+
+		if checkpoints[path].Offset != int64(len(scribbled)) {
+			t.Errorf("expected checkpoint to advance to %d, got %d", len(scribbled), checkpoints[path].Offset)
+		}
+	})
+
+	t.Run("earlier cutoff reads from the start", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte(initial+appended), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		context, err := ce.ExtractContextSince(path, "", now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(context.UserPrompts) != 3 {
+			t.Errorf("expected events before the checkpoint to be read again, got %v", context.UserPrompts)
+		}
+	})
+
+	t.Run("replaced transcript reads from the start", func(t *testing.T) {
+		checkpoints[path] = journal.TranscriptCheckpoint{Offset: 1 << 30, LastTimestamp: now}
+		context, err := ce.ExtractContextSince(path, "", now.Add(time.Second))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(context.UserPrompts) != 2 {
+			t.Errorf("expected prompts from the cutoff on, got %v", context.UserPrompts)
+		}
+	})
+}
+
+func TestTranscriptCheckpointsInterleavedSessions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.jsonl")
+	now := time.Now().Truncate(time.Second)
+
+	line := func(sessionID string, offset time.Duration, prompt string) string {
+		data, _ := json.Marshal(map[string]interface{}{
+			"type":      "user",
+			"sessionId": sessionID,
+			"timestamp": now.Add(offset).Format(time.RFC3339),
+			"message":   map[string]interface{}{"content": prompt},
+		})
+		return string(data) + "\n"
+	}
+
+	// Another session keeps writing to the transcript after s1's cutoff
+	initial := line("s1", 0, "First prompt") + line("s2", 2*time.Second, "Parallel prompt")
+	if err := os.WriteFile(path, []byte(initial), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoints := make(map[string]journal.TranscriptCheckpoint)
+	ce := NewContextExtractor(nil)
+	ce.UseCheckpoints(checkpoints)
+
+	if _, err := ce.extractFromSingleTranscript(path, "s1", cutoff{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkpoint := checkpoints[path]
+	if !checkpoint.LastTimestamp.Equal(now.Add(2*time.Second)) || !checkpoint.Sessions["s1"].Equal(now) {
+		t.Fatalf("unexpected checkpoint %+v", checkpoint)
+	}
+
+	// Scribble over the parsed bytes: a resumed read must not look at them again
+	appended := line("s1", 3*time.Second, "Second prompt")
+	scribbled := strings.Repeat("#", len(initial)-1) + "\n" + appended
+	if err := os.WriteFile(path, []byte(scribbled), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ce.extractFromSingleTranscript(path, "s1", cutoff{since: now.Add(time.Second)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	context := parsed.combined()
+	if len(context.UserPrompts) != 1 || context.UserPrompts[0] != "Second prompt" {
+		t.Errorf("expected only the appended prompt, got %v", context.UserPrompts)
+	}
+	if len(parsed.formatWarnings) != 0 {
+		t.Errorf("expected to resume from the checkpoint, got warnings %v", parsed.formatWarnings)
+	}
+
+	checkpoint = checkpoints[path]
+	if checkpoint.Offset != int64(len(scribbled)) {
+		t.Errorf("expected checkpoint to advance to %d, got %d", len(scribbled), checkpoint.Offset)
+	}
+	if !checkpoint.Sessions["s2"].Equal(now.Add(2*time.Second)) || !checkpoint.Sessions["s1"].Equal(now.Add(3*time.Second)) {
+		t.Errorf("expected both sessions' timestamps kept, got %v", checkpoint.Sessions)
+	}
+
+	// The other session's own cutoff still comes before its parsed entries
+	parsed, err = ce.extractFromSingleTranscript(path, "s2", cutoff{since: now.Add(time.Second)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(parsed.formatWarnings) == 0 {
+		t.Error("expected s2 to read the transcript from the start")
+	}
+}
+
+func TestExtractContextAfter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.jsonl")
+	now := time.Now().Truncate(time.Second)
+
+	var transcript strings.Builder
+	for _, entry := range []map[string]interface{}{
+		{"type": "user", "sessionId": "s1", "timestamp": now.Format(time.RFC3339),
+			"message": map[string]interface{}{"content": "Write the parser"}},
+		{"type": "assistant", "sessionId": "s1", "timestamp": now.Add(time.Second).Format(time.RFC3339),
+			"message": map[string]interface{}{"content": []map[string]interface{}{
+				{"type": "tool_use", "id": "toolu_commit", "name": "Bash", "input": map[string]interface{}{"command": "git commit -m parser"}},
+			}}},
+		{"type": "user", "sessionId": "s1", "timestamp": now.Add(2 * time.Second).Format(time.RFC3339),
+			"message": map[string]interface{}{"content": []map[string]interface{}{
+				{"type": "tool_result", "tool_use_id": "toolu_commit", "content": "[main abc1234] parser"},
+			}}},
+		{"type": "user", "sessionId": "s1", "timestamp": now.Add(3 * time.Second).Format(time.RFC3339),
+			"message": map[string]interface{}{"content": "Now document it"}},
+	} {
+		data, _ := json.Marshal(entry)
+		transcript.Write(data)
+		transcript.WriteString("\n")
+	}
+	if err := os.WriteFile(path, []byte(transcript.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ce := NewContextExtractor(nil)
+
+	t.Run("no cursor extracts the whole session", func(t *testing.T) {
+		context, err := ce.ExtractContextAfter(path, "s1", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(context.UserPrompts) != 2 || len(context.ToolInteractions) != 1 {
+			t.Errorf("expected the whole session, got prompts %v and tools %v", context.UserPrompts, context.ToolInteractions)
+		}
+	})
+
+	t.Run("cursor skips the attributed commit", func(t *testing.T) {
+		cursor := &journal.Cursor{SessionID: "s1", ToolUseID: "toolu_commit", Timestamp: now.Add(time.Second)}
+		context, err := ce.ExtractContextAfter(path, "s1", cursor)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(context.Events) != 1 || context.Events[0].Content != "Now document it" {
+			t.Errorf("expected only the prompt after the commit, got %+v", context.Events)
+		}
+	})
+}
+
+func TestEventIDs(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	entries := []map[string]interface{}{
+		{
+			"type":      "user",
+			"uuid":      "u1",
+			"timestamp": now.Format(time.RFC3339),
+			"message":   map[string]interface{}{"role": "user", "content": "Run the tests"},
+		},
+		{
+			"type":      "assistant",
+			"uuid":      "a1",
+			"timestamp": now.Add(time.Second).Format(time.RFC3339),
+			"message": map[string]interface{}{
+				"role": "assistant",
+				"content": []interface{}{
+					map[string]interface{}{"type": "text", "text": "Running them"},
+					map[string]interface{}{"type": "tool_use", "id": "toolu_1", "name": "Bash", "input": map[string]interface{}{"command": "go test ./..."}},
+				},
+			},
+		},
+		{
+			"type":      "user",
+			"uuid":      "u2",
+			"timestamp": now.Add(2 * time.Second).Format(time.RFC3339),
+			"message": map[string]interface{}{
+				"role": "user",
+				"content": []interface{}{
+					map[string]interface{}{"type": "tool_result", "tool_use_id": "toolu_1", "content": "ok"},
+				},
+			},
+		},
+		{
+			"type":      "user",
+			"timestamp": now.Add(3 * time.Second).Format(time.RFC3339),
+			"message":   map[string]interface{}{"role": "user", "content": "No UUID"},
+		},
+	}
+
+	var lines []string
+	for _, entry := range entries {
+		data, _ := json.Marshal(entry)
+		lines = append(lines, string(data))
+	}
+
+	ce := NewContextExtractor(nil)
+	context := ce.parseTranscriptContent(strings.Join(lines, "\n"), "", time.Time{})
+
+	var ids []string
+	for _, event := range context.Events {
+		ids = append(ids, event.ID)
+	}
+	if got, expected := strings.Join(ids, ","), "u1/0,a1/0,toolu_1,toolu_1/result,"; got != expected {
+		t.Errorf("expected event IDs %q, got %q", expected, got)
+	}
+}
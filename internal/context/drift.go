@@ -0,0 +1,178 @@
+package context
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FormatWarning is something in a transcript that cnotes doesn't understand,
+// usually a sign that a Claude Code update changed the transcript format
+type FormatWarning struct {
+	Kind      string `json:"kind"`   // "unknown_entry_type", "unknown_field", "unknown_block_type", "invalid_entry" or "invalid_json"
+	Detail    string `json:"detail"` // The entry type, field path like "assistant.message.container", or error
+	Count     int    `json:"count"`
+	FirstLine int    `json:"first_line"`
+}
+
+// String describes the warning for humans
+func (w FormatWarning) String() string {
+	var what string
+	switch w.Kind {
+	case "unknown_entry_type":
+		what = fmt.Sprintf("unknown entry type %q", w.Detail)
+	case "unknown_field":
+		what = fmt.Sprintf("unknown field %s", w.Detail)
+	case "unknown_block_type":
+		what = fmt.Sprintf("unknown content block type %s", w.Detail)
+	case "invalid_entry":
+		what = fmt.Sprintf("entry doesn't match the schema: %s", w.Detail)
+	default:
+		what = fmt.Sprintf("line is not valid JSON: %s", w.Detail)
+	}
+
+	unit := "lines"
+	if w.Count == 1 {
+		unit = "line"
+	}
+	return fmt.Sprintf("%s (%d %s, first on line %d)", what, w.Count, unit, w.FirstLine)
+}
+
+// DriftDetector compares transcript lines against the typed schema and
+// collects a warning for everything it doesn't cover
+type DriftDetector struct {
+	warnings map[string]*FormatWarning
+	order    []string
+	versions map[string]int
+}
+
+// NewDriftDetector creates an empty drift detector
+func NewDriftDetector() *DriftDetector {
+	return &DriftDetector{
+		warnings: make(map[string]*FormatWarning),
+		versions: make(map[string]int),
+	}
+}
+
+// Check records drift in one transcript line, given what DecodeTranscriptEntry
+// returned for it
+func (d *DriftDetector) Check(lineNumber int, line []byte, record TranscriptRecord, decodeErr error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		d.add("invalid_json", err.Error(), lineNumber)
+		return
+	}
+
+	var entryType string
+	_ = json.Unmarshal(fields["type"], &entryType)
+
+	if decodeErr != nil {
+		d.add("invalid_entry", decodeErr.Error(), lineNumber)
+		return
+	}
+	if record == nil {
+		d.add("unknown_entry_type", entryType, lineNumber)
+		return
+	}
+
+	if header, ok := record.(interface{ Header() *EntryHeader }); ok && header.Header().Version != "" {
+		d.versions[header.Header().Version]++
+	}
+
+	d.checkFields(lineNumber, entryType, fields, reflect.TypeOf(record).Elem())
+
+	if entryType != "user" && entryType != "assistant" {
+		return
+	}
+
+	var message map[string]json.RawMessage
+	if err := json.Unmarshal(fields["message"], &message); err != nil {
+		return
+	}
+	d.checkFields(lineNumber, entryType+".message", message, reflect.TypeOf(TranscriptMessage{}))
+
+	var usage map[string]json.RawMessage
+	if err := json.Unmarshal(message["usage"], &usage); err == nil {
+		d.checkFields(lineNumber, entryType+".message.usage", usage, reflect.TypeOf(MessageUsage{}))
+	}
+
+	// String content has no blocks to check
+	var blocks []map[string]json.RawMessage
+	if err := json.Unmarshal(message["content"], &blocks); err != nil {
+		return
+	}
+	for _, block := range blocks {
+		var blockType string
+		_ = json.Unmarshal(block["type"], &blockType)
+		if !knownBlockTypes[blockType] {
+			d.add("unknown_block_type", entryType+".message.content["+blockType+"]", lineNumber)
+			continue
+		}
+		d.checkFields(lineNumber, entryType+".message.content["+blockType+"]", block, reflect.TypeOf(ContentBlock{}))
+	}
+}
+
+// checkFields warns about every field the schema type doesn't declare
+func (d *DriftDetector) checkFields(lineNumber int, path string, fields map[string]json.RawMessage, schema reflect.Type) {
+	known := jsonFieldNames(schema)
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !known[name] {
+			d.add("unknown_field", path+"."+name, lineNumber)
+		}
+	}
+}
+
+func (d *DriftDetector) add(kind, detail string, lineNumber int) {
+	key := kind + "\x00" + detail
+	if warning, ok := d.warnings[key]; ok {
+		warning.Count++
+		return
+	}
+
+	d.warnings[key] = &FormatWarning{Kind: kind, Detail: detail, Count: 1, FirstLine: lineNumber}
+	d.order = append(d.order, key)
+}
+
+// Warnings returns the drift found so far, in the order it was first seen
+func (d *DriftDetector) Warnings() []FormatWarning {
+	warnings := make([]FormatWarning, 0, len(d.order))
+	for _, key := range d.order {
+		warnings = append(warnings, *d.warnings[key])
+	}
+	return warnings
+}
+
+// Versions returns how many entries each Claude Code version wrote
+func (d *DriftDetector) Versions() map[string]int {
+	return d.versions
+}
+
+// jsonFieldNames returns the JSON names of a struct's fields, including
+// those of embedded structs
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			for name := range jsonFieldNames(field.Type) {
+				names[name] = true
+			}
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
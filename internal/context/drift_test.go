@@ -0,0 +1,70 @@
+package context
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDriftDetector(t *testing.T) {
+	lines := []string{
+		`{"type":"user","sessionId":"s1","version":"2.0.5","message":{"role":"user","content":"hi"}}`,
+		`{"type":"assistant","version":"2.0.5","message":{"id":"m1","model":"claude-sonnet-4-20250514","container":null,"content":[{"type":"text","text":"hello"},{"type":"hologram","data":"x"}],"usage":{"input_tokens":1,"output_tokens":1,"new_counter":3}}}`,
+		`{"type":"assistant","version":"2.1.0","message":{"id":"m2","container":null,"content":[{"type":"tool_use","id":"t1","name":"Bash","input":{},"caller":"x"}]}}`,
+		`{"type":"telemetry","data":{}}`,
+		`{"type":"user","message":{"content":42}}`,
+		`not json`,
+		`{"type":"summary","summary":"Fix login","leafUuid":"u1"}`,
+	}
+
+	drift := NewDriftDetector()
+	for i, line := range lines {
+		record, err := DecodeTranscriptEntry([]byte(line))
+		drift.Check(i+1, []byte(line), record, err)
+	}
+
+	got := make(map[string]FormatWarning)
+	for _, warning := range drift.Warnings() {
+		got[warning.Kind+" "+warning.Detail] = warning
+	}
+
+	expected := []string{
+		"unknown_field assistant.message.container",
+		"unknown_field assistant.message.usage.new_counter",
+		"unknown_block_type assistant.message.content[hologram]",
+		"unknown_field assistant.message.content[tool_use].caller",
+		"unknown_entry_type telemetry",
+	}
+	for _, key := range expected {
+		if _, ok := got[key]; !ok {
+			t.Errorf("expected warning %q, got %v", key, drift.Warnings())
+		}
+	}
+
+	if warning := got["unknown_field assistant.message.container"]; warning.Count != 2 || warning.FirstLine != 2 {
+		t.Errorf("expected repeated drift to be counted once per line, got %+v", warning)
+	}
+
+	var invalid, invalidJSON bool
+	for _, warning := range drift.Warnings() {
+		invalid = invalid || (warning.Kind == "invalid_entry" && warning.FirstLine == 5)
+		invalidJSON = invalidJSON || (warning.Kind == "invalid_json" && warning.FirstLine == 6)
+	}
+	if !invalid || !invalidJSON {
+		t.Errorf("expected invalid entry and invalid JSON warnings, got %v", drift.Warnings())
+	}
+
+	// Nothing the schema knows about is reported
+	for _, warning := range drift.Warnings() {
+		if strings.Contains(warning.Detail, "sessionId") || strings.Contains(warning.Detail, "summary") {
+			t.Errorf("unexpected warning for known field: %+v", warning)
+		}
+	}
+
+	if versions := drift.Versions(); versions["2.0.5"] != 2 || versions["2.1.0"] != 1 {
+		t.Errorf("unexpected versions: %v", versions)
+	}
+
+	if s := got["unknown_entry_type telemetry"].String(); s != `unknown entry type "telemetry" (1 line, first on line 4)` {
+		t.Errorf("unexpected description: %s", s)
+	}
+}
@@ -0,0 +1,342 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/imjasonh/cnotes/internal/config"
+)
+
+// Priorities of excerpt lines; when an excerpt is too long, lines with lower
+// priorities are dropped first. Within a tool's priority its output goes
+// first, so a result never outlives its tool use.
+const (
+	dropNoiseResult = iota // Output of tools that only looked around
+	dropNoise              // Tool uses that only looked around
+	dropToolResult         // Other tool output
+	dropThinking           // Claude's reasoning
+	dropTool               // Other tool uses
+	dropResponse           // Claude's responses, bar the final one
+	keepAlways             // User prompts, the final response and the relevance summary
+)
+
+// minLineLength is how short fitLines will cut a line it has to keep
+const minLineLength = 40
+
+// excerptLine is one line of an excerpt
+type excerptLine struct {
+	event    *ConversationEvent // The event it shows; nil for the relevance summary
+	text     string
+	priority int
+}
+
+// ExcerptEvent is an event as an excerpt shows it, cut to fit, or a marker for
+// events left out to fit the excerpt into its maximum length
+type ExcerptEvent struct {
+	ConversationEvent
+	Result string // For tool uses, the tool's output, if the excerpt shows it
+	Elided int    // If set, how many events were left out here; the rest is empty
+}
+
+// isNoiseTool reports whether a tool only looks around, so that its uses say
+// little about why a commit was made
+func isNoiseTool(toolName string) bool {
+	switch toolName {
+	case "Read", "Glob", "LS", "Grep":
+		return true
+	}
+	return false
+}
+
+// dropPriority returns the priority of an event's excerpt line
+func dropPriority(event ConversationEvent, final bool) int {
+	switch event.Type {
+	case "user":
+		return keepAlways
+	case "assistant":
+		if final {
+			return keepAlways
+		}
+		return dropResponse
+	case "thinking":
+		return dropThinking
+	case "tool":
+		if isNoiseTool(event.ToolName) {
+			return dropNoise
+		}
+		return dropTool
+	case "tool_result":
+		if isNoiseTool(event.ToolName) {
+			return dropNoiseResult
+		}
+		return dropToolResult
+	}
+	return dropNoiseResult
+}
+
+// eventLimit returns how many characters of an event type an excerpt shows
+func (ce *ContextExtractor) eventLimit(eventType string) int {
+	if ce.config != nil && ce.config.EventLimits[eventType] > 0 {
+		return ce.config.EventLimits[eventType]
+	}
+	return config.DefaultEventLimits()[eventType]
+}
+
+// excerptLines returns a line for the relevance summary and each event, in
+// order, with the events cut to their types' limits
+func (ce *ContextExtractor) excerptLines(context *ConversationContext) []excerptLine {
+	// Sort events by timestamp
+	sort.Slice(context.Events, func(i, j int) bool {
+		return context.Events[i].Timestamp.Before(context.Events[j].Timestamp)
+	})
+
+	// The final response usually sums up what led to the commit
+	final := -1
+	for i, event := range context.Events {
+		if event.Type == "assistant" {
+			final = i
+		}
+	}
+
+	var lines []excerptLine
+	if summary := ExcludedSummary(context.Excluded); summary != "" {
+		lines = append(lines, excerptLine{text: summary, priority: keepAlways})
+	}
+	for i, event := range context.Events {
+		event := ce.cutEvent(event)
+		if text := ce.formatEvent(event); text != "" {
+			lines = append(lines, excerptLine{event: &event, text: text, priority: dropPriority(event, i == final)})
+		}
+	}
+	return lines
+}
+
+// fitLines picks the lines to keep for the excerpt to fit into its maximum
+// length. Lines are dropped lowest priority first and, within a priority,
+// oldest first, since the events right before a commit matter most; each run
+// of dropped lines leaves a "[N events elided]" marker. If the lines that are
+// always kept are still too long, the longest events are cut short.
+func (ce *ContextExtractor) fitLines(lines []excerptLine) []bool {
+	budget := ce.maxExcerptLength
+	kept := make([]bool, len(lines))
+	for i := range kept {
+		kept[i] = true
+	}
+
+	// Sort the lines that may be dropped once, in the order they're dropped in
+	var candidates []int
+	for i, line := range lines {
+		if line.priority < keepAlways {
+			candidates = append(candidates, i)
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return lines[candidates[a]].priority < lines[candidates[b]].priority
+	})
+
+	size := newExcerptSizer(lines)
+	for _, drop := range candidates {
+		if size.size() <= budget {
+			break
+		}
+		size.drop(drop)
+		kept[drop] = false
+	}
+
+	for excess := size.size() - budget; excess > 0; excess = size.size() - budget {
+		longest := -1
+		for i, line := range lines {
+			if kept[i] && line.event != nil && (longest < 0 || len(line.text) > len(lines[longest].text)) {
+				longest = i
+			}
+		}
+		if longest < 0 || len(lines[longest].event.Content) <= minLineLength {
+			break
+		}
+
+		line := &lines[longest]
+		line.event.Content = truncateBytes(line.event.Content, max(len(line.event.Content)-excess, minLineLength))
+		text := ce.formatEvent(*line.event)
+		size.resize(longest, len(text))
+		line.text = text
+	}
+
+	return kept
+}
+
+// ExcerptEvents returns the events CreateExcerpt shows, cut and elided the
+// same way, with each tool's output joined to its use
+func (ce *ContextExtractor) ExcerptEvents(context *ConversationContext) []ExcerptEvent {
+	lines := ce.excerptLines(context)
+	kept := ce.fitLines(lines)
+
+	var events []ExcerptEvent
+	uses := make(map[string]int) // Tool uses in events, by tool_use ID
+	elided := 0
+	for i, line := range lines {
+		if !kept[i] {
+			elided++
+			continue
+		}
+		if elided > 0 {
+			events = append(events, ExcerptEvent{Elided: elided})
+			elided = 0
+		}
+		if line.event == nil {
+			continue // The relevance summary is rebuilt from Excluded
+		}
+
+		event := *line.event
+		if event.Type == "tool_result" {
+			if use, ok := toolUseFor(events, uses, event); ok {
+				events[use].Result = event.Content
+				events[use].IsError = event.IsError
+				continue
+			}
+		}
+		if event.Type == "tool" && event.ToolUseID != "" {
+			uses[event.ToolUseID] = len(events)
+		}
+		events = append(events, ExcerptEvent{ConversationEvent: event})
+	}
+	if elided > 0 {
+		events = append(events, ExcerptEvent{Elided: elided})
+	}
+
+	return events
+}
+
+// toolUseFor returns the position in events of the tool use a result answers:
+// the one with its tool_use ID or, for results without one, the tool use of
+// the same tool right before it
+func toolUseFor(events []ExcerptEvent, uses map[string]int, result ConversationEvent) (int, bool) {
+	if result.ToolUseID != "" {
+		use, ok := uses[result.ToolUseID]
+		return use, ok && events[use].Result == ""
+	}
+
+	last := len(events) - 1
+	if last < 0 {
+		return 0, false
+	}
+	previous := events[last]
+	return last, previous.Type == "tool" && previous.ToolName == result.ToolName && previous.Result == ""
+}
+
+// excerptParts returns the kept lines, with a marker for each run of dropped
+// ones
+func excerptParts(lines []excerptLine, kept []bool) []string {
+	var parts []string
+	elided := 0
+	for i, line := range lines {
+		if !kept[i] {
+			elided++
+			continue
+		}
+		if elided > 0 {
+			parts = append(parts, elidedMarker(elided))
+			elided = 0
+		}
+		parts = append(parts, line.text)
+	}
+	if elided > 0 {
+		parts = append(parts, elidedMarker(elided))
+	}
+	return parts
+}
+
+// excerptSizer keeps track of the length of the excerpt excerptParts would
+// make as lines are dropped, without making it
+type excerptSizer struct {
+	lines   []excerptLine
+	lengths int   // Total length of the parts
+	parts   int   // Number of parts, joined by blank lines
+	runs    []int // Length of the run of dropped lines, at both ends of each run
+}
+
+// newExcerptSizer returns an excerptSizer with all lines kept
+func newExcerptSizer(lines []excerptLine) *excerptSizer {
+	s := &excerptSizer{lines: lines, parts: len(lines), runs: make([]int, len(lines))}
+	for _, line := range lines {
+		s.lengths += len(line.text)
+	}
+	return s
+}
+
+// size returns the length of the excerpt
+func (s *excerptSizer) size() int {
+	if s.parts == 0 {
+		return 0
+	}
+	return s.lengths + len("\n\n")*(s.parts-1)
+}
+
+// drop drops a kept line, joining the markers of the runs of dropped lines
+// on either side of it into one
+func (s *excerptSizer) drop(i int) {
+	before, after := 0, 0
+	if i > 0 {
+		before = s.runs[i-1]
+	}
+	if i+1 < len(s.runs) {
+		after = s.runs[i+1]
+	}
+
+	s.lengths -= len(s.lines[i].text)
+	s.parts--
+	for _, run := range []int{before, after} {
+		if run > 0 {
+			s.lengths -= len(elidedMarker(run))
+			s.parts--
+		}
+	}
+
+	run := before + 1 + after
+	s.lengths += len(elidedMarker(run))
+	s.parts++
+	s.runs[i-before] = run
+	s.runs[i+after] = run
+}
+
+// resize records that a kept line's text changed length
+func (s *excerptSizer) resize(i, length int) {
+	s.lengths += length - len(s.lines[i].text)
+}
+
+// elidedMarker stands in for events dropped from an excerpt
+func elidedMarker(count int) string {
+	if count == 1 {
+		return "[1 event elided]"
+	}
+	return fmt.Sprintf("[%d events elided]", count)
+}
+
+// truncateRunes cuts text to at most limit characters, ending it with "..."
+// if anything was cut
+func truncateRunes(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	if limit <= 3 {
+		return string([]rune(text)[:max(limit, 0)])
+	}
+	return string([]rune(text)[:limit-3]) + "..."
+}
+
+// truncateBytes cuts text to at most limit bytes without splitting a
+// character, ending it with "..." if anything was cut
+func truncateBytes(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	if limit <= 3 {
+		return ""
+	}
+
+	cut := limit - 3
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "..."
+}
@@ -0,0 +1,232 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/imjasonh/cnotes/internal/config"
+)
+
+func TestExcerptBudget(t *testing.T) {
+	now := time.Now()
+	at := func(seconds int) time.Time { return now.Add(time.Duration(seconds) * time.Second) }
+	long := strings.Repeat("x", 100)
+
+	context := &ConversationContext{
+		Events: []ConversationEvent{
+			{Type: "user", Content: "Fix the panic", Timestamp: at(0)},
+			{Type: "tool", ToolName: "Glob", Content: "**/*.go", Timestamp: at(1)},
+			{Type: "tool", ToolName: "Read", Content: "/repo/cmd/main.go", Timestamp: at(2)},
+			{Type: "assistant", Content: "The config is nil " + long, Timestamp: at(3)},
+			{Type: "tool", ToolName: "Edit", Content: "/repo/cmd/main.go", Timestamp: at(4)},
+			{Type: "tool", ToolName: "Bash", Content: "go test ./...", Timestamp: at(5)},
+			{Type: "tool_result", ToolName: "Bash", Content: "ok", Timestamp: at(6)},
+			{Type: "user", Content: "Commit it", Timestamp: at(7)},
+			{Type: "assistant", Content: "Committed the nil check", Timestamp: at(8)},
+		},
+	}
+
+	for _, tt := range []struct {
+		budget   int
+		expected []string
+	}{
+		{
+			budget: 1000,
+			expected: []string{
+				"👤 User: Fix the panic",
+				"Tool (Glob): **/*.go",
+				"Tool (Read): /repo/cmd/main.go",
+				"🤖 Claude: The config is nil " + long,
+				"Tool (Edit): /repo/cmd/main.go",
+				"Tool (Bash): go test ./...",
+				"Result: ok",
+				"👤 User: Commit it",
+				"🤖 Claude: Committed the nil check",
+			},
+		},
+		{
+			// Looking around goes first
+			budget: 310,
+			expected: []string{
+				"👤 User: Fix the panic",
+				"[2 events elided]",
+				"🤖 Claude: The config is nil " + long,
+				"Tool (Edit): /repo/cmd/main.go",
+				"Tool (Bash): go test ./...",
+				"Result: ok",
+				"👤 User: Commit it",
+				"🤖 Claude: Committed the nil check",
+			},
+		},
+		{
+			// Then output, other tools and earlier responses, but never the
+			// prompts or the final response
+			budget: 150,
+			expected: []string{
+				"👤 User: Fix the panic",
+				"[6 events elided]",
+				"👤 User: Commit it",
+				"🤖 Claude: Committed the nil check",
+			},
+		},
+	} {
+		ce := NewContextExtractor(&config.NotesConfig{MaxExcerptLength: tt.budget})
+		excerpt := ce.CreateExcerpt(context)
+		if expected := strings.Join(tt.expected, "\n\n"); excerpt != expected {
+			t.Errorf("budget %d: expected\n%s\ngot\n%s", tt.budget, expected, excerpt)
+		}
+		if len(excerpt) > tt.budget {
+			t.Errorf("budget %d: excerpt is %d bytes", tt.budget, len(excerpt))
+		}
+	}
+}
+
+func TestExcerptKeptLinesCutShort(t *testing.T) {
+	ce := NewContextExtractor(&config.NotesConfig{MaxExcerptLength: 120})
+	context := &ConversationContext{
+		Events: []ConversationEvent{
+			{Type: "user", Content: "Short prompt"},
+			{Type: "user", Content: strings.Repeat("日本語", 60), Timestamp: time.Now()},
+		},
+	}
+
+	excerpt := ce.CreateExcerpt(context)
+	if len(excerpt) > 120 || !utf8.ValidString(excerpt) {
+		t.Errorf("expected a valid excerpt of at most 120 bytes, got %d bytes: %q", len(excerpt), excerpt)
+	}
+	if !strings.HasPrefix(excerpt, "👤 User: Short prompt\n\n👤 User: 日本語") || !strings.HasSuffix(excerpt, "...") {
+		t.Errorf("expected the long prompt to be cut short, got %q", excerpt)
+	}
+}
+
+func TestExcerptSizer(t *testing.T) {
+	var lines []excerptLine
+	for i := 0; i < 12; i++ {
+		lines = append(lines, excerptLine{text: strings.Repeat("x", i*7%5+1)})
+	}
+
+	// Drop lines out of order, so runs of dropped lines grow on either side
+	// and join up
+	size := newExcerptSizer(lines)
+	kept := make([]bool, len(lines))
+	for i := range kept {
+		kept[i] = true
+	}
+	for _, drop := range []int{5, 3, 4, 0, 11, 9, 1, 10, 2, 6, 8, 7} {
+		size.drop(drop)
+		kept[drop] = false
+		if expected := len(strings.Join(excerptParts(lines, kept), "\n\n")); size.size() != expected {
+			t.Fatalf("after dropping line %d: expected size %d, got %d", drop, expected, size.size())
+		}
+	}
+}
+
+func TestEventLimits(t *testing.T) {
+	cfg := config.DefaultNotesConfig()
+	cfg.EventLimits["user"] = 10
+	ce := NewContextExtractor(cfg)
+
+	excerpt := ce.CreateExcerpt(&ConversationContext{
+		Events: []ConversationEvent{{Type: "user", Content: "ünïcödé prompt"}},
+	})
+	if excerpt != "👤 User: ünïcödé..." {
+		t.Errorf("expected the prompt cut to 10 characters, got %q", excerpt)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	for _, tt := range []struct {
+		text, runes, bytes string
+	}{
+		{"short", "short", "short"},
+		{"exactly ten", "exactly...", "exactly..."},
+		{"héllo wörld", "héllo w...", "héllo ..."},
+		{"日本語のテキスト", "日本語のテキスト", "日本..."},
+	} {
+		if got := truncateRunes(tt.text, 10); got != tt.runes {
+			t.Errorf("truncateRunes(%q) = %q, want %q", tt.text, got, tt.runes)
+		}
+		if got := truncateBytes(tt.text, 10); got != tt.bytes {
+			t.Errorf("truncateBytes(%q) = %q, want %q", tt.text, got, tt.bytes)
+		}
+	}
+}
+
+func TestExcerptEvents(t *testing.T) {
+	now := time.Now()
+	at := func(seconds int) time.Time { return now.Add(time.Duration(seconds) * time.Second) }
+
+	context := &ConversationContext{
+		Events: []ConversationEvent{
+			{Type: "user", Content: "Fix the panic", Timestamp: at(0)},
+			{Type: "tool", ToolName: "Glob", Content: "**/*.go", ToolUseID: "toolu_1", Timestamp: at(1)},
+			{Type: "tool_result", ToolName: "Glob", Content: strings.Repeat("cmd/main.go\n", 20), ToolUseID: "toolu_1", Timestamp: at(2)},
+			{Type: "tool", ToolName: "Bash", Content: "go test ./...", ToolUseID: "toolu_2", Timestamp: at(3)},
+			{Type: "tool", ToolName: "Bash", Content: "go vet ./...", ToolUseID: "toolu_3", Timestamp: at(4)},
+			{Type: "tool_result", ToolName: "Bash", Content: "panic: nil map", ToolUseID: "toolu_2", IsError: true, Timestamp: at(5)},
+			{Type: "tool_result", ToolName: "Bash", Content: "ok", ToolUseID: "toolu_3", Timestamp: at(6)},
+			{Type: "tool", ToolName: "Bash", Content: "make", Timestamp: at(7)},
+			{Type: "tool_result", ToolName: "Bash", Content: "done", Timestamp: at(8)},
+			{Type: "tool_result", ToolName: "Read", Content: "package main", ToolUseID: "toolu_0", Timestamp: at(9)},
+			{Type: "assistant", Content: "Fixed", Timestamp: at(10)},
+		},
+		Excluded: map[string]int{"user": 2},
+	}
+
+	describe := func(events []ExcerptEvent) string {
+		var lines []string
+		for _, event := range events {
+			if event.Elided > 0 {
+				lines = append(lines, fmt.Sprintf("elided %d", event.Elided))
+				continue
+			}
+			line := event.Type + " " + event.Content
+			if event.Result != "" {
+				line += " -> " + event.Result
+			}
+			if event.IsError {
+				line += " (error)"
+			}
+			lines = append(lines, line)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	t.Run("tool output joins its use", func(t *testing.T) {
+		ce := NewContextExtractor(nil)
+		expected := strings.Join([]string{
+			"user Fix the panic",
+			"tool **/*.go -> cmd/main.go\ncmd/main.go\ncmd/main.go\n[...]",
+			"tool go test ./... -> panic: nil map (error)",
+			"tool go vet ./... -> ok",
+			"tool make -> done",
+			"tool_result package main",
+			"assistant Fixed",
+		}, "\n")
+		if got := describe(ce.ExcerptEvents(context)); got != expected {
+			t.Errorf("expected\n%s\ngot\n%s", expected, got)
+		}
+	})
+
+	t.Run("elided like the excerpt", func(t *testing.T) {
+		ce := NewContextExtractor(&config.NotesConfig{MaxExcerptLength: 200})
+		expected := strings.Join([]string{
+			"user Fix the panic",
+			"elided 6",
+			"tool make",
+			"elided 2",
+			"assistant Fixed",
+		}, "\n")
+		if got := describe(ce.ExcerptEvents(context)); got != expected {
+			t.Errorf("expected\n%s\ngot\n%s", expected, got)
+		}
+
+		excerpt := ce.CreateExcerpt(context)
+		if !strings.Contains(excerpt, "👤 User: Fix the panic\n\n[6 events elided]\n\nTool (Bash): make\n\n[2 events elided]\n\n🤖 Claude: Fixed") {
+			t.Errorf("expected the excerpt to elide the same events, got %q", excerpt)
+		}
+	})
+}
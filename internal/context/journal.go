@@ -0,0 +1,102 @@
+package context
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/imjasonh/cnotes/internal/journal"
+)
+
+// ExtractContextFromJournal builds conversation context from hook data cnotes
+// recorded itself, for when the transcript is unavailable or incomplete
+func (ce *ContextExtractor) ExtractContextFromJournal(entries []journal.Entry, since time.Time) *ConversationContext {
+	context := &ConversationContext{
+		UserPrompts:      []string{},
+		ClaudeResponses:  []string{},
+		ToolInteractions: []ToolInteraction{},
+		Events:           []ConversationEvent{},
+	}
+
+	for _, entry := range entries {
+		if !since.IsZero() && entry.Timestamp.Before(since) {
+			continue
+		}
+
+		switch entry.Event {
+		case "UserPromptSubmit":
+			if entry.Prompt == "" {
+				continue
+			}
+			context.UserPrompts = append(context.UserPrompts, entry.Prompt)
+			context.Events = append(context.Events, ConversationEvent{
+				Timestamp: entry.Timestamp,
+				Type:      "user",
+				Content:   entry.Prompt,
+			})
+
+		case "PostToolUse":
+			var input map[string]interface{}
+			if err := json.Unmarshal(entry.ToolInput, &input); err != nil {
+				continue
+			}
+
+			interaction := ToolInteraction{
+				Tool:   entry.ToolName,
+				Input:  summarizeToolInput(entry.ToolName, input),
+				Output: toolResponseOutput(entry.ToolResponse),
+			}
+			if interaction.Input == "" {
+				continue
+			}
+
+			context.ToolInteractions = append(context.ToolInteractions, interaction)
+			context.Events = append(context.Events, ConversationEvent{
+				ID:        entry.ToolUseID,
+				Timestamp: entry.Timestamp,
+				Type:      "tool",
+				Content:   interaction.Input,
+				ToolName:  entry.ToolName,
+				ToolUseID: entry.ToolUseID,
+			})
+			if interaction.Output != "" && ce.includeToolOutput() {
+				context.Events = append(context.Events, ConversationEvent{
+					ID:        toolResultID(entry.ToolUseID),
+					Timestamp: entry.Timestamp,
+					Type:      "tool_result",
+					Content:   interaction.Output,
+					ToolName:  entry.ToolName,
+					ToolUseID: entry.ToolUseID,
+				})
+			}
+		}
+	}
+
+	for _, event := range context.Events {
+		if event.Timestamp.After(context.LastEventTime) {
+			context.LastEventTime = event.Timestamp
+		}
+	}
+
+	ce.filterRelevant(context)
+	return ce.filterSensitiveContent(context)
+}
+
+// toolResponseOutput extracts the textual output from a hook tool_response
+func toolResponseOutput(response json.RawMessage) string {
+	if len(response) == 0 {
+		return ""
+	}
+
+	var result struct {
+		Stdout string `json:"stdout"`
+		Output string `json:"output"`
+	}
+	if err := json.Unmarshal(response, &result); err != nil {
+		return ""
+	}
+
+	if result.Stdout != "" {
+		return result.Stdout
+	}
+	return result.Output
+}
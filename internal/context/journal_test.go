@@ -0,0 +1,55 @@
+package context
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/imjasonh/cnotes/internal/journal"
+)
+
+func TestExtractContextFromJournal(t *testing.T) {
+	ce := NewContextExtractor(nil)
+	now := time.Now()
+
+	entries := []journal.Entry{
+		{Timestamp: now.Add(-time.Hour), Event: "UserPromptSubmit", Prompt: "Old prompt"},
+		{Timestamp: now, Event: "SessionStart", Source: "resume"},
+		{Timestamp: now.Add(1 * time.Second), Event: "UserPromptSubmit", Prompt: "Commit the fix"},
+		{
+			Timestamp:    now.Add(2 * time.Second),
+			Event:        "PostToolUse",
+			ToolName:     "Bash",
+			ToolInput:    json.RawMessage(`{"command":"git commit -m fix"}`),
+			ToolResponse: json.RawMessage(`{"stdout":"[main abc1234] fix"}`),
+		},
+		{Timestamp: now.Add(3 * time.Second), Event: "Stop"},
+	}
+
+	context := ce.ExtractContextFromJournal(entries, now.Add(-time.Minute))
+
+	if len(context.UserPrompts) != 1 || context.UserPrompts[0] != "Commit the fix" {
+		t.Errorf("unexpected user prompts: %v", context.UserPrompts)
+	}
+
+	if len(context.ToolInteractions) != 1 {
+		t.Fatalf("expected 1 tool interaction, got %d", len(context.ToolInteractions))
+	}
+
+	if context.ToolInteractions[0].Input != "git commit -m fix" {
+		t.Errorf("unexpected tool input: %s", context.ToolInteractions[0].Input)
+	}
+
+	if context.ToolInteractions[0].Output != "[main abc1234] fix" {
+		t.Errorf("unexpected tool output: %s", context.ToolInteractions[0].Output)
+	}
+
+	// user, tool, tool_result
+	if len(context.Events) != 3 {
+		t.Errorf("expected 3 events, got %d", len(context.Events))
+	}
+
+	if context.LastEventTime.Unix() != now.Add(2*time.Second).Unix() {
+		t.Errorf("unexpected LastEventTime %v", context.LastEventTime)
+	}
+}
@@ -0,0 +1,107 @@
+package context
+
+import (
+	"sort"
+
+	"github.com/imjasonh/cnotes/internal/config"
+)
+
+// PrivacyLevel returns the configured privacy level, which decides how much
+// of the conversation the extracted context keeps
+func (ce *ContextExtractor) PrivacyLevel() string {
+	if ce.config == nil || ce.config.PrivacyLevel == "" {
+		return config.PrivacyStandard
+	}
+	return ce.config.PrivacyLevel
+}
+
+// includeToolOutput reports whether tool output belongs in the excerpt. At the
+// standard privacy level it is left out unless the config opts in, since
+// output often contains secrets.
+func (ce *ContextExtractor) includeToolOutput() bool {
+	switch ce.PrivacyLevel() {
+	case config.PrivacyMinimal:
+		return false
+	case config.PrivacyFull:
+		return true
+	}
+	return ce.config == nil || ce.config.IncludeToolOutput
+}
+
+// includeReasoning reports whether Claude's thinking belongs in the excerpt
+func (ce *ContextExtractor) includeReasoning() bool {
+	return ce.PrivacyLevel() == config.PrivacyFull
+}
+
+// isFileTool reports whether a tool's summarized input is a file path
+func isFileTool(toolName string) bool {
+	switch toolName {
+	case "Read", "Write", "Edit", "MultiEdit", "NotebookEdit":
+		return true
+	}
+	return false
+}
+
+// applyPrivacyLevel removes what the privacy level leaves out. The minimal
+// level keeps only the user's prompts and the paths of files tools read or
+// wrote, dropping Claude's responses, commands and what subagents were asked
+// and reported.
+func (ce *ContextExtractor) applyPrivacyLevel(context *ConversationContext) {
+	if ce.PrivacyLevel() != config.PrivacyMinimal {
+		return
+	}
+
+	context.ClaudeResponses = []string{}
+	for i, interaction := range context.ToolInteractions {
+		if !isFileTool(interaction.Tool) {
+			context.ToolInteractions[i].Input = ""
+		}
+		context.ToolInteractions[i].Output = ""
+	}
+	context.Events = minimalEvents(context.Events)
+
+	for i := range context.Subagents {
+		subagent := &context.Subagents[i]
+		subagent.Description = ""
+		subagent.Prompt = ""
+		subagent.Result = ""
+		subagent.Events = minimalEvents(subagent.Events)
+	}
+}
+
+// minimalEvents returns the user prompts and file tool uses among events
+func minimalEvents(events []ConversationEvent) []ConversationEvent {
+	kept := []ConversationEvent{}
+	for _, event := range events {
+		if event.Type == "user" || (event.Type == "tool" && isFileTool(event.ToolName)) {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
+
+// limitPrompts keeps the conversation from the configured maximum number of
+// most recent user prompts on, dropping everything before them
+func (ce *ContextExtractor) limitPrompts(context *ConversationContext) {
+	if ce.config == nil || ce.config.MaxPrompts <= 0 || len(context.UserPrompts) <= ce.config.MaxPrompts {
+		return
+	}
+	maxPrompts := ce.config.MaxPrompts
+	context.UserPrompts = context.UserPrompts[len(context.UserPrompts)-maxPrompts:]
+
+	sort.SliceStable(context.Events, func(i, j int) bool {
+		return context.Events[i].Timestamp.Before(context.Events[j].Timestamp)
+	})
+
+	prompts := 0
+	for i := len(context.Events) - 1; i >= 0; i-- {
+		if context.Events[i].Type != "user" {
+			continue
+		}
+		prompts++
+		if prompts == maxPrompts {
+			context.Events = context.Events[i:]
+			return
+		}
+	}
+}
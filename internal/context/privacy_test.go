@@ -0,0 +1,166 @@
+package context
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imjasonh/cnotes/internal/config"
+)
+
+func TestPrivacyLevels(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	entries := []map[string]interface{}{
+		{
+			"type":      "user",
+			"timestamp": now.Format(time.RFC3339),
+			"message":   map[string]interface{}{"role": "user", "content": "Fix the failing test"},
+		},
+		{
+			"type":      "assistant",
+			"timestamp": now.Add(time.Second).Format(time.RFC3339),
+			"message": map[string]interface{}{
+				"role": "assistant",
+				"content": []interface{}{
+					map[string]interface{}{"type": "thinking", "thinking": "The test probably reads a stale fixture"},
+					map[string]interface{}{"type": "text", "text": "Let me look at the test."},
+					map[string]interface{}{"type": "tool_use", "id": "toolu_1", "name": "Read", "input": map[string]interface{}{"file_path": "/repo/main_test.go"}},
+					map[string]interface{}{"type": "tool_use", "id": "toolu_2", "name": "Bash", "input": map[string]interface{}{"command": "go test ./..."}},
+				},
+			},
+		},
+		{
+			"type":      "user",
+			"timestamp": now.Add(2 * time.Second).Format(time.RFC3339),
+			"message": map[string]interface{}{
+				"role": "user",
+				"content": []interface{}{
+					map[string]interface{}{"type": "tool_result", "tool_use_id": "toolu_1", "content": "package main"},
+					map[string]interface{}{"type": "tool_result", "tool_use_id": "toolu_2", "content": "FAIL"},
+				},
+			},
+		},
+	}
+
+	var lines []string
+	for _, entry := range entries {
+		data, _ := json.Marshal(entry)
+		lines = append(lines, string(data))
+	}
+	content := strings.Join(lines, "\n")
+
+	extract := func(level string) (*ContextExtractor, *ConversationContext) {
+		cfg := config.DefaultNotesConfig()
+		cfg.PrivacyLevel = level
+		ce := NewContextExtractor(cfg)
+		context := ce.parseTranscriptContent(content, "", time.Time{})
+		context.Subagents = []SubagentThread{{
+			Description: "Find the fixture",
+			Prompt:      "Look for the fixture the test reads",
+			Result:      "It's in testdata/",
+			Events: []ConversationEvent{
+				{Type: "assistant", Content: "Searching"},
+				{Type: "tool", ToolName: "Edit", Content: "/repo/testdata/fixture.json"},
+			},
+		}}
+		return ce, ce.filterSensitiveContent(context)
+	}
+
+	eventTypes := func(events []ConversationEvent) string {
+		var types []string
+		for _, event := range events {
+			types = append(types, event.Type+":"+event.ToolName)
+		}
+		return strings.Join(types, " ")
+	}
+
+	t.Run("minimal", func(t *testing.T) {
+		ce, context := extract(config.PrivacyMinimal)
+
+		if got := eventTypes(context.Events); got != "user: tool:Read" {
+			t.Errorf("expected only the prompt and the file read, got %q", got)
+		}
+		if len(context.ClaudeResponses) != 0 {
+			t.Errorf("expected no responses, got %v", context.ClaudeResponses)
+		}
+		for _, interaction := range context.ToolInteractions {
+			if interaction.Output != "" || (interaction.Tool == "Bash" && interaction.Input != "") {
+				t.Errorf("expected only file paths in tool interactions, got %+v", interaction)
+			}
+		}
+
+		subagent := context.Subagents[0]
+		if subagent.Description != "" || subagent.Prompt != "" || subagent.Result != "" {
+			t.Errorf("expected the subagent's prompt and result to be dropped, got %+v", subagent)
+		}
+		if got := eventTypes(subagent.Events); got != "tool:Edit" {
+			t.Errorf("expected only the subagent's file edit, got %q", got)
+		}
+
+		excerpt := ce.CreateExcerpt(context)
+		if !strings.Contains(excerpt, "/repo/main_test.go") || strings.Contains(excerpt, "go test") {
+			t.Errorf("unexpected excerpt %q", excerpt)
+		}
+	})
+
+	t.Run("standard", func(t *testing.T) {
+		ce, context := extract(config.PrivacyStandard)
+
+		if got := eventTypes(context.Events); got != "user: assistant: tool:Read tool:Bash" {
+			t.Errorf("expected no reasoning or tool output, got %q", got)
+		}
+		if context.Subagents[0].Result == "" {
+			t.Error("expected the subagent's result to be kept")
+		}
+		if ce.PrivacyLevel() != config.PrivacyStandard {
+			t.Errorf("unexpected privacy level %q", ce.PrivacyLevel())
+		}
+	})
+
+	t.Run("full", func(t *testing.T) {
+		ce, context := extract(config.PrivacyFull)
+
+		got := eventTypes(context.Events)
+		expected := "user: thinking: assistant: tool:Read tool:Bash tool_result:Read tool_result:Bash"
+		if got != expected {
+			t.Errorf("expected reasoning and tool output, got %q", got)
+		}
+
+		excerpt := ce.CreateExcerpt(context)
+		if !strings.Contains(excerpt, "💭 Thinking: The test probably reads a stale fixture") {
+			t.Errorf("expected reasoning in excerpt, got %q", excerpt)
+		}
+	})
+}
+
+func TestLimitPrompts(t *testing.T) {
+	now := time.Now()
+	cfg := config.DefaultNotesConfig()
+	cfg.MaxPrompts = 2
+	ce := NewContextExtractor(cfg)
+
+	context := &ConversationContext{
+		UserPrompts: []string{"first", "second", "third"},
+		Events: []ConversationEvent{
+			{Type: "user", Content: "first", Timestamp: now},
+			{Type: "assistant", Content: "one", Timestamp: now.Add(time.Second)},
+			{Type: "user", Content: "second", Timestamp: now.Add(2 * time.Second)},
+			{Type: "assistant", Content: "two", Timestamp: now.Add(3 * time.Second)},
+			{Type: "user", Content: "third", Timestamp: now.Add(4 * time.Second)},
+		},
+	}
+	ce.limitPrompts(context)
+
+	if strings.Join(context.UserPrompts, " ") != "second third" {
+		t.Errorf("unexpected prompts %v", context.UserPrompts)
+	}
+	var contents []string
+	for _, event := range context.Events {
+		contents = append(contents, event.Content)
+	}
+	if got := strings.Join(contents, " "); got != "second two third" {
+		t.Errorf("expected the conversation from the second prompt on, got %q", got)
+	}
+}
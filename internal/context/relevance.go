@@ -0,0 +1,250 @@
+package context
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/imjasonh/cnotes/internal/config"
+)
+
+// relevance scores how closely an event relates to the committed files
+type relevance int
+
+const (
+	irrelevant relevance = iota
+	mentioned            // The event's text names a committed file
+	read                 // A tool read a committed file
+	edited               // A tool wrote to a committed file
+)
+
+// relevanceScorer scores events against the files a commit changed
+type relevanceScorer struct {
+	files    map[string]bool // Cleaned absolute paths
+	mentions *regexp.Regexp  // Paths ending in a committed file's name
+}
+
+// newRelevanceScorer returns a scorer for the given absolute paths
+func newRelevanceScorer(files []string) *relevanceScorer {
+	scorer := &relevanceScorer{files: make(map[string]bool)}
+
+	var names []string
+	for _, file := range files {
+		file = filepath.Clean(file)
+		scorer.files[file] = true
+		if name := regexp.QuoteMeta(filepath.Base(file)); !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		// A mention may give as much of the path as it likes, e.g. main.go,
+		// cmd/main.go or the absolute path
+		scorer.mentions = regexp.MustCompile(`(?:[\w.@-]*/)*(?:` + strings.Join(names, "|") + `)`)
+	}
+
+	return scorer
+}
+
+// score returns how an event relates to the committed files. A file tool's
+// input is a path, so it only counts if that path was committed; any other
+// event counts if its text mentions a committed file.
+func (s *relevanceScorer) score(event ConversationEvent) relevance {
+	if event.Type == "tool" && isFileTool(event.ToolName) {
+		if !s.files[filepath.Clean(event.Content)] {
+			return irrelevant
+		}
+		if event.ToolName == "Read" {
+			return read
+		}
+		return edited
+	}
+
+	if s.mention(event.Content) {
+		return mentioned
+	}
+	return irrelevant
+}
+
+// mention reports whether text names a committed file by a path that ends
+// the same way, so that other/main.go doesn't count for cmd/main.go
+func (s *relevanceScorer) mention(text string) bool {
+	if s.mentions == nil {
+		return false
+	}
+
+	for _, match := range s.mentions.FindAllStringIndex(text, -1) {
+		start, end := match[0], match[1]
+		if start > 0 && isPathByte(text[start-1]) {
+			continue // Part of a longer name, e.g. domain.go for main.go
+		}
+		if end < len(text) && (isPathByte(text[end]) && text[end] != '.') {
+			continue
+		}
+
+		path := text[start:end]
+		for strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") {
+			_, path, _ = strings.Cut(path, "/")
+		}
+		for file := range s.files {
+			if file == path || strings.HasSuffix(file, "/"+path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isPathByte reports whether a byte can be part of a file path
+func isPathByte(b byte) bool {
+	return b == '/' || b == '.' || b == '-' || b == '_' || b == '@' ||
+		('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || ('0' <= b && b <= '9')
+}
+
+// filterMode returns the configured filter
+func (ce *ContextExtractor) filterMode() string {
+	if ce.config == nil || ce.config.Filter == "" {
+		return config.FilterAll
+	}
+	return ce.config.Filter
+}
+
+// filterRelevant keeps, with the relevance filter, only the events that
+// read, edited or mentioned the committed files, the results of those tool
+// uses and the user prompts that led to them, and the subagents that touched
+// those files. What it leaves out is
+// counted in Excluded. If nothing relates to the committed files, e.g. they
+// were changed by a command, everything is kept rather than leaving the note
+// empty.
+func (ce *ContextExtractor) filterRelevant(context *ConversationContext) {
+	if ce.filterMode() != config.FilterRelevance || len(ce.commitFiles) == 0 {
+		return
+	}
+	scorer := newRelevanceScorer(ce.commitFiles)
+
+	sort.SliceStable(context.Events, func(i, j int) bool {
+		return context.Events[i].Timestamp.Before(context.Events[j].Timestamp)
+	})
+
+	keep := make([]bool, len(context.Events))
+	anyRelevant := false
+	prompt := -1
+	for i, event := range context.Events {
+		if event.Type == "user" {
+			prompt = i
+		}
+		if scorer.score(event) == irrelevant {
+			continue
+		}
+		anyRelevant = true
+		keep[i] = true
+		if prompt >= 0 {
+			keep[prompt] = true
+		}
+	}
+
+	// A kept tool use keeps its result, which rarely names the file it's about
+	keptTools := make(map[string]bool)
+	for i, event := range context.Events {
+		if keep[i] && event.Type == "tool" && event.ToolUseID != "" {
+			keptTools[event.ToolUseID] = true
+		}
+	}
+	for i, event := range context.Events {
+		if event.Type == "tool_result" && keptTools[event.ToolUseID] {
+			keep[i] = true
+		}
+	}
+
+	var subagents []SubagentThread
+	excludedSubagents := 0
+	for _, subagent := range context.Subagents {
+		if subagentRelevant(scorer, subagent) {
+			anyRelevant = true
+			subagents = append(subagents, subagent)
+		} else {
+			excludedSubagents++
+		}
+	}
+
+	if !anyRelevant {
+		return
+	}
+
+	excluded := make(map[string]int)
+	events := []ConversationEvent{}
+	context.UserPrompts = []string{}
+	context.ClaudeResponses = []string{}
+	for i, event := range context.Events {
+		if !keep[i] {
+			excluded[event.Type]++
+			continue
+		}
+		events = append(events, event)
+		switch event.Type {
+		case "user":
+			context.UserPrompts = append(context.UserPrompts, event.Content)
+		case "assistant":
+			context.ClaudeResponses = append(context.ClaudeResponses, event.Content)
+		}
+	}
+	context.Events = events
+	context.Subagents = subagents
+	if excludedSubagents > 0 {
+		excluded["subagent"] = excludedSubagents
+	}
+
+	if len(excluded) > 0 {
+		context.Excluded = excluded
+	}
+}
+
+// subagentRelevant reports whether a subagent was asked about, touched or
+// reported on the committed files
+func subagentRelevant(scorer *relevanceScorer, subagent SubagentThread) bool {
+	if scorer.mention(subagent.Prompt) || scorer.mention(subagent.Result) {
+		return true
+	}
+	for _, event := range subagent.Events {
+		if scorer.score(event) != irrelevant {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedKinds names what the relevance filter can leave out, in the order
+// the summary lists them
+var excludedKinds = []struct {
+	eventType        string
+	singular, plural string
+}{
+	{"user", "prompt", "prompts"},
+	{"assistant", "response", "responses"},
+	{"thinking", "thought", "thoughts"},
+	{"tool", "tool use", "tool uses"},
+	{"tool_result", "tool result", "tool results"},
+	{"subagent", "subagent", "subagents"},
+}
+
+// ExcludedSummary describes in one line what the relevance filter left out,
+// so that it doesn't disappear silently
+func ExcludedSummary(excluded map[string]int) string {
+	var parts []string
+	for _, kind := range excludedKinds {
+		count := excluded[kind.eventType]
+		if count == 0 {
+			continue
+		}
+		name := kind.plural
+		if count == 1 {
+			name = kind.singular
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", count, name))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "⏭️ Left out as unrelated to the committed files: " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,149 @@
+package context
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imjasonh/cnotes/internal/config"
+)
+
+func TestRelevanceScore(t *testing.T) {
+	scorer := newRelevanceScorer([]string{"/repo/cmd/main.go", "/repo/README.md"})
+
+	for _, tt := range []struct {
+		event    ConversationEvent
+		expected relevance
+	}{
+		{ConversationEvent{Type: "tool", ToolName: "Edit", Content: "/repo/cmd/main.go"}, edited},
+		{ConversationEvent{Type: "tool", ToolName: "Write", Content: "/repo/cmd/../README.md"}, edited},
+		{ConversationEvent{Type: "tool", ToolName: "Read", Content: "/repo/cmd/main.go"}, read},
+		{ConversationEvent{Type: "tool", ToolName: "Read", Content: "/repo/other/main.go"}, irrelevant},
+		{ConversationEvent{Type: "user", Content: "Why does main.go panic?"}, mentioned},
+		{ConversationEvent{Type: "assistant", Content: "The bug is in cmd/main.go."}, mentioned},
+		{ConversationEvent{Type: "tool", ToolName: "Bash", Content: "go vet ./cmd/main.go"}, mentioned},
+		{ConversationEvent{Type: "tool", ToolName: "Bash", Content: "cat ../repo/README.md"}, mentioned},
+		{ConversationEvent{Type: "assistant", Content: "Look at other/main.go"}, irrelevant},
+		{ConversationEvent{Type: "assistant", Content: "Look at domain.go"}, irrelevant},
+		{ConversationEvent{Type: "assistant", Content: "Look at main.gox"}, irrelevant},
+		{ConversationEvent{Type: "tool", ToolName: "Grep", Content: "func main"}, irrelevant},
+	} {
+		if got := scorer.score(tt.event); got != tt.expected {
+			t.Errorf("%s %q: expected %d, got %d", tt.event.ToolName, tt.event.Content, tt.expected, got)
+		}
+	}
+}
+
+func TestFilterRelevant(t *testing.T) {
+	now := time.Now()
+	newContext := func() *ConversationContext {
+		at := func(seconds int) time.Time { return now.Add(time.Duration(seconds) * time.Second) }
+		return &ConversationContext{
+			UserPrompts:     []string{"Explore the repo", "Fix the panic"},
+			ClaudeResponses: []string{"It has a cmd and a docs directory", "Fixed"},
+			Events: []ConversationEvent{
+				{Type: "user", Content: "Explore the repo", Timestamp: at(0)},
+				{Type: "tool", ToolName: "Read", Content: "/repo/docs/index.md", Timestamp: at(1)},
+				{Type: "assistant", Content: "It has a cmd and a docs directory", Timestamp: at(2)},
+				{Type: "user", Content: "Fix the panic", Timestamp: at(3)},
+				{Type: "tool", ToolName: "Bash", Content: "go test ./...", Timestamp: at(4)},
+				{Type: "tool", ToolName: "Edit", Content: "/repo/cmd/main.go", Timestamp: at(5)},
+				{Type: "assistant", Content: "Fixed", Timestamp: at(6)},
+			},
+			Subagents: []SubagentThread{
+				{Prompt: "Find where the panic comes from", Result: "cmd/main.go dereferences a nil config"},
+				{Prompt: "Summarize the docs", Result: "They describe installation"},
+			},
+		}
+	}
+
+	t.Run("relevance", func(t *testing.T) {
+		cfg := config.DefaultNotesConfig()
+		cfg.Filter = config.FilterRelevance
+		ce := NewContextExtractor(cfg)
+		ce.UseCommitFiles([]string{"/repo/cmd/main.go"})
+
+		context := newContext()
+		ce.filterRelevant(context)
+
+		var contents []string
+		for _, event := range context.Events {
+			contents = append(contents, event.Content)
+		}
+		if got := strings.Join(contents, ", "); got != "Fix the panic, /repo/cmd/main.go" {
+			t.Errorf("expected the edit and the prompt that led to it, got %q", got)
+		}
+		if strings.Join(context.UserPrompts, ", ") != "Fix the panic" || len(context.ClaudeResponses) != 0 {
+			t.Errorf("expected prompts and responses to match the events, got %v and %v", context.UserPrompts, context.ClaudeResponses)
+		}
+		if len(context.Subagents) != 1 || context.Subagents[0].Prompt != "Find where the panic comes from" {
+			t.Errorf("expected only the subagent that reported on main.go, got %+v", context.Subagents)
+		}
+
+		expected := map[string]int{"user": 1, "assistant": 2, "tool": 2, "subagent": 1}
+		for eventType, count := range expected {
+			if context.Excluded[eventType] != count {
+				t.Errorf("expected %d %s excluded, got %v", count, eventType, context.Excluded)
+			}
+		}
+
+		excerpt := ce.CreateExcerpt(context)
+		summary := "⏭️ Left out as unrelated to the committed files: 1 prompt, 2 responses, 2 tool uses, 1 subagent"
+		if !strings.HasPrefix(excerpt, summary) {
+			t.Errorf("expected the excerpt to start with the summary, got %q", excerpt)
+		}
+	})
+
+	t.Run("result of a relevant tool use", func(t *testing.T) {
+		cfg := config.DefaultNotesConfig()
+		cfg.Filter = config.FilterRelevance
+		ce := NewContextExtractor(cfg)
+		ce.UseCommitFiles([]string{"/repo/cmd/main.go"})
+
+		context := &ConversationContext{
+			Events: []ConversationEvent{
+				{Type: "user", Content: "Why does it panic?", Timestamp: now},
+				{Type: "tool", ToolName: "Read", ToolUseID: "read-1", Content: "/repo/cmd/main.go", Timestamp: now.Add(time.Second)},
+				{Type: "tool_result", ToolUseID: "read-1", Content: "func main() {\n\tcfg.Load()\n}", Timestamp: now.Add(2 * time.Second)},
+				{Type: "tool", ToolName: "Bash", ToolUseID: "bash-1", Content: "go version", Timestamp: now.Add(3 * time.Second)},
+				{Type: "tool_result", ToolUseID: "bash-1", Content: "go1.22", Timestamp: now.Add(4 * time.Second)},
+			},
+		}
+		ce.filterRelevant(context)
+
+		var kept []string
+		for _, event := range context.Events {
+			kept = append(kept, event.Type+":"+event.ToolUseID)
+		}
+		if got := strings.Join(kept, ", "); got != "user:, tool:read-1, tool_result:read-1" {
+			t.Errorf("expected the read and its output kept, got %q", got)
+		}
+	})
+
+	t.Run("nothing relevant", func(t *testing.T) {
+		cfg := config.DefaultNotesConfig()
+		cfg.Filter = config.FilterRelevance
+		ce := NewContextExtractor(cfg)
+		ce.UseCommitFiles([]string{"/repo/go.sum"})
+
+		context := newContext()
+		ce.filterRelevant(context)
+		if len(context.Events) != 7 || len(context.Subagents) != 2 || context.Excluded != nil {
+			t.Errorf("expected everything to be kept, got %+v", context)
+		}
+	})
+
+	t.Run("all", func(t *testing.T) {
+		ce := NewContextExtractor(config.DefaultNotesConfig())
+		ce.UseCommitFiles([]string{"/repo/cmd/main.go"})
+
+		context := newContext()
+		ce.filterRelevant(context)
+		if len(context.Events) != 7 || context.Excluded != nil {
+			t.Errorf("expected everything to be kept, got %+v", context)
+		}
+		if strings.Contains(ce.CreateExcerpt(context), "Left out") {
+			t.Error("expected no summary when nothing was left out")
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package context
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// SubagentThread is the conversation of a subagent that a Task tool use
+// started. Its events stay out of the main timeline.
+type SubagentThread struct {
+	ToolUseID   string              `json:"tool_use_id,omitempty"` // The Task tool use that started the subagent
+	AgentID     string              `json:"agent_id,omitempty"`
+	Type        string              `json:"type,omitempty"` // The Task's subagent_type
+	Description string              `json:"description,omitempty"`
+	Prompt      string              `json:"prompt,omitempty"`
+	Result      string              `json:"result,omitempty"` // What the subagent reported back
+	Events      []ConversationEvent `json:"events"`
+}
+
+// isTaskTool reports whether a tool starts a subagent
+func isTaskTool(toolName string) bool {
+	return toolName == "Task" || toolName == "Agent"
+}
+
+// startTask records a Task tool use, waiting for its subagent's conversation
+func (thread *transcriptThread) startTask(block ContentBlock) *SubagentThread {
+	task := &SubagentThread{ToolUseID: block.ID}
+	task.Type, _ = block.Input["subagent_type"].(string)
+	task.Description, _ = block.Input["description"].(string)
+	task.Prompt, _ = block.Input["prompt"].(string)
+	thread.tasks = append(thread.tasks, task)
+	return task
+}
+
+// finishTask records what a subagent reported back, and the agent that ran
+// it when Claude Code says so in the tool's structured result
+func finishTask(task *SubagentThread, block ContentBlock, toolUseResult json.RawMessage) {
+	if block.Content != nil {
+		task.Result = block.Content.String()
+	}
+
+	var result struct {
+		AgentID string `json:"agentId"`
+	}
+	if json.Unmarshal(toolUseResult, &result) == nil && result.AgentID != "" {
+		task.AgentID = result.AgentID
+	}
+}
+
+// linkSubagents turns sidechain threads into subagent threads, attaching each
+// to the Task tool use that started it: by agent ID where the transcript
+// records it, otherwise by the prompt the subagent was given. Sidechains
+// whose Task came before the cutoff are kept on their own.
+func linkSubagents(tasks []*SubagentThread, sidechains []*transcriptThread) []SubagentThread {
+	linked := make(map[*SubagentThread]bool)
+	var subagents []SubagentThread
+
+	for _, sidechain := range sidechains {
+		var prompt string
+		if len(sidechain.context.UserPrompts) > 0 {
+			prompt = sidechain.context.UserPrompts[0]
+		}
+
+		var task *SubagentThread
+		for _, candidate := range tasks {
+			if !linked[candidate] && sidechain.key.agentID != "" && candidate.AgentID == sidechain.key.agentID {
+				task = candidate
+				break
+			}
+		}
+		if task == nil {
+			for _, candidate := range tasks {
+				if !linked[candidate] && candidate.Prompt != "" && strings.TrimSpace(candidate.Prompt) == strings.TrimSpace(prompt) {
+					task = candidate
+					break
+				}
+			}
+		}
+
+		subagent := SubagentThread{AgentID: sidechain.key.agentID, Prompt: prompt}
+		if task != nil {
+			linked[task] = true
+			subagent = *task
+			if subagent.AgentID == "" {
+				subagent.AgentID = sidechain.key.agentID
+			}
+		}
+
+		// The prompt is shown on its own rather than as the first event
+		subagent.Events = []ConversationEvent{}
+		for _, event := range sidechain.context.Events {
+			if event.Type == "user" && strings.TrimSpace(event.Content) == strings.TrimSpace(prompt) {
+				continue
+			}
+			subagent.Events = append(subagent.Events, event)
+		}
+
+		subagents = append(subagents, subagent)
+	}
+
+	return subagents
+}
@@ -0,0 +1,141 @@
+package context
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubagentThreads(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	at := func(seconds int) string {
+		return now.Add(time.Duration(seconds) * time.Second).Format(time.RFC3339)
+	}
+	task := func(id, description, prompt string) map[string]interface{} {
+		return map[string]interface{}{
+			"type": "assistant", "sessionId": "s1", "timestamp": at(1),
+			"message": map[string]interface{}{"content": []map[string]interface{}{
+				{"type": "tool_use", "id": id, "name": "Task", "input": map[string]interface{}{
+					"description": description, "prompt": prompt, "subagent_type": "Explore",
+				}},
+			}},
+		}
+	}
+	taskResult := func(id, result, agentID string) map[string]interface{} {
+		entry := map[string]interface{}{
+			"type": "user", "sessionId": "s1", "timestamp": at(5),
+			"message": map[string]interface{}{"content": []map[string]interface{}{
+				{"type": "tool_result", "tool_use_id": id, "content": []map[string]interface{}{{"type": "text", "text": result}}},
+			}},
+		}
+		if agentID != "" {
+			entry["toolUseResult"] = map[string]interface{}{"status": "completed", "agentId": agentID}
+		}
+		return entry
+	}
+	sidechain := func(uuid, parent, agentID string, seconds int, message map[string]interface{}) map[string]interface{} {
+		entry := map[string]interface{}{
+			"type": "user", "sessionId": "s1", "timestamp": at(seconds),
+			"uuid": uuid, "isSidechain": true, "message": message,
+		}
+		if parent != "" {
+			entry["parentUuid"] = parent
+		}
+		if agentID != "" {
+			entry["agentId"] = agentID
+		}
+		if _, ok := message["model"]; ok {
+			entry["type"] = "assistant"
+		}
+		return entry
+	}
+	write := func(path string, entries ...map[string]interface{}) {
+		var lines []string
+		for _, entry := range entries {
+			data, _ := json.Marshal(entry)
+			lines = append(lines, string(data))
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reply := func(text string) map[string]interface{} {
+		return map[string]interface{}{"model": "claude-haiku-4-5", "content": []map[string]interface{}{{"type": "text", "text": text}}}
+	}
+
+	t.Run("inline sidechains linked by prompt", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "s1.jsonl")
+		write(path,
+			map[string]interface{}{"type": "user", "sessionId": "s1", "timestamp": at(0), "message": map[string]interface{}{"content": "Look around"}},
+			task("toolu_a", "Find tests", "Find the parser tests"),
+			task("toolu_b", "Find docs", "Find the parser docs"),
+			sidechain("a1", "", "", 2, map[string]interface{}{"content": "Find the parser tests"}),
+			sidechain("b1", "", "", 2, map[string]interface{}{"content": "Find the parser docs"}),
+			sidechain("a2", "a1", "", 3, reply("Tests are in parser_test.go")),
+			sidechain("b2", "b1", "", 3, reply("Docs are in README.md")),
+			taskResult("toolu_a", "parser_test.go", ""),
+			taskResult("toolu_b", "README.md", ""),
+		)
+
+		context, err := NewContextExtractor(nil).ExtractContextAfter(path, "s1", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(context.UserPrompts) != 1 || context.UserPrompts[0] != "Look around" {
+			t.Errorf("expected subagent prompts to stay out of the main timeline, got %v", context.UserPrompts)
+		}
+		if _, ok := context.Models["claude-haiku-4-5"]; !ok {
+			t.Errorf("expected subagent usage to count, got %v", context.Models)
+		}
+
+		if len(context.Subagents) != 2 {
+			t.Fatalf("expected 2 subagents, got %+v", context.Subagents)
+		}
+		for i, expected := range []struct{ toolUseID, result, reply string }{
+			{"toolu_a", "parser_test.go", "Tests are in parser_test.go"},
+			{"toolu_b", "README.md", "Docs are in README.md"},
+		} {
+			subagent := context.Subagents[i]
+			if subagent.ToolUseID != expected.toolUseID || subagent.Type != "Explore" || subagent.Result != expected.result {
+				t.Errorf("unexpected subagent %+v", subagent)
+			}
+			if len(subagent.Events) != 1 || subagent.Events[0].Content != expected.reply {
+				t.Errorf("expected only the subagent's reply as events, got %+v", subagent.Events)
+			}
+		}
+	})
+
+	t.Run("subagent transcripts linked by agent ID", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "s1.jsonl")
+		write(path,
+			task("toolu_a", "Find tests", "Find the parser tests"),
+			taskResult("toolu_a", "parser_test.go", "agent-1"),
+		)
+		write(filepath.Join(dir, "s1", "subagents", "agent-agent-1.jsonl"),
+			sidechain("a1", "", "agent-1", 2, map[string]interface{}{"content": "A differently worded prompt"}),
+			sidechain("a2", "a1", "agent-1", 3, reply("Tests are in parser_test.go")),
+		)
+
+		context, err := NewContextExtractor(nil).ExtractContextAfter(path, "s1", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(context.Subagents) != 1 {
+			t.Fatalf("expected 1 subagent, got %+v", context.Subagents)
+		}
+		subagent := context.Subagents[0]
+		if subagent.ToolUseID != "toolu_a" || subagent.AgentID != "agent-1" || len(subagent.Events) != 1 {
+			t.Errorf("unexpected subagent %+v", subagent)
+		}
+	})
+}
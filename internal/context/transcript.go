@@ -0,0 +1,292 @@
+package context
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TranscriptSchemaVersion is the Claude Code release whose transcript format
+// the structs below were last checked against. Transcripts from newer
+// releases may carry entries and fields cnotes doesn't know about yet.
+const TranscriptSchemaVersion = "2.0"
+
+// TranscriptRecord is a decoded transcript line: one of *UserEntry,
+// *AssistantEntry, *SystemEntry, *SummaryEntry, *AttachmentEntry,
+// *FileHistorySnapshotEntry, *QueueOperationEntry or *ToolResultEntry
+type TranscriptRecord interface {
+	EntryType() string
+}
+
+// EntryHeader holds the fields shared by the entries that make up a
+// conversation. Subagent conversations are recorded as sidechain entries in
+// the parent session's transcript.
+type EntryHeader struct {
+	Type              string `json:"type"`
+	UUID              string `json:"uuid,omitempty"`
+	ParentUUID        string `json:"parentUuid,omitempty"`
+	LogicalParentUUID string `json:"logicalParentUuid,omitempty"` // Across a compaction boundary
+	SessionID         string `json:"sessionId,omitempty"`
+	Timestamp         string `json:"timestamp,omitempty"`
+	IsSidechain       bool   `json:"isSidechain,omitempty"`
+	AgentID           string `json:"agentId,omitempty"` // Subagent that wrote a sidechain entry
+	UserType          string `json:"userType,omitempty"`
+	CWD               string `json:"cwd,omitempty"`
+	Version           string `json:"version,omitempty"` // Claude Code version that wrote the entry
+	GitBranch         string `json:"gitBranch,omitempty"`
+}
+
+// EntryType returns the entry's type field
+func (h *EntryHeader) EntryType() string {
+	return h.Type
+}
+
+// Header returns the shared fields themselves
+func (h *EntryHeader) Header() *EntryHeader {
+	return h
+}
+
+// Time returns the entry's timestamp, or the zero time if it is missing or malformed
+func (h *EntryHeader) Time() time.Time {
+	t, _ := time.Parse(time.RFC3339, h.Timestamp)
+	return t
+}
+
+// UserEntry is a user prompt, or the results of the previous assistant
+// message's tool uses
+type UserEntry struct {
+	EntryHeader
+	Message                   TranscriptMessage `json:"message"`
+	IsMeta                    bool              `json:"isMeta,omitempty"`
+	IsCompactSummary          bool              `json:"isCompactSummary,omitempty"`
+	IsVisibleInTranscriptOnly bool              `json:"isVisibleInTranscriptOnly,omitempty"`
+	ToolUseResult             json.RawMessage   `json:"toolUseResult,omitempty"` // Tool-specific structured result
+	ThinkingMetadata          json.RawMessage   `json:"thinkingMetadata,omitempty"`
+	Todos                     json.RawMessage   `json:"todos,omitempty"`
+}
+
+// AssistantEntry is one content block of a model response; Claude Code
+// writes a separate entry for each block of the same API message
+type AssistantEntry struct {
+	EntryHeader
+	Message           TranscriptMessage `json:"message"`
+	RequestID         string            `json:"requestId,omitempty"`
+	IsAPIErrorMessage bool              `json:"isApiErrorMessage,omitempty"`
+}
+
+// SystemEntry is a message from Claude Code itself, e.g. a compaction boundary
+type SystemEntry struct {
+	EntryHeader
+	Subtype         string          `json:"subtype,omitempty"`
+	Content         string          `json:"content,omitempty"`
+	Level           string          `json:"level,omitempty"`
+	IsMeta          bool            `json:"isMeta,omitempty"`
+	ToolUseID       string          `json:"toolUseID,omitempty"`
+	CompactMetadata json.RawMessage `json:"compactMetadata,omitempty"`
+}
+
+// SummaryEntry titles the conversation ending at LeafUUID
+type SummaryEntry struct {
+	Type     string `json:"type"`
+	Summary  string `json:"summary"`
+	LeafUUID string `json:"leafUuid,omitempty"`
+}
+
+// EntryType returns "summary"
+func (e *SummaryEntry) EntryType() string {
+	return e.Type
+}
+
+// AttachmentEntry is context Claude Code attached to the conversation, such
+// as a file the user mentioned
+type AttachmentEntry struct {
+	EntryHeader
+	Attachment json.RawMessage `json:"attachment,omitempty"`
+}
+
+// FileHistorySnapshotEntry records file backups Claude Code takes for checkpoints
+type FileHistorySnapshotEntry struct {
+	Type             string          `json:"type"`
+	MessageID        string          `json:"messageId,omitempty"`
+	Snapshot         json.RawMessage `json:"snapshot,omitempty"`
+	IsSnapshotUpdate bool            `json:"isSnapshotUpdate,omitempty"`
+}
+
+// EntryType returns "file-history-snapshot"
+func (e *FileHistorySnapshotEntry) EntryType() string {
+	return e.Type
+}
+
+// QueueOperationEntry records prompts queued while Claude was busy
+type QueueOperationEntry struct {
+	Type      string `json:"type"`
+	Operation string `json:"operation"`
+	SessionID string `json:"sessionId,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+// EntryType returns "queue-operation"
+func (e *QueueOperationEntry) EntryType() string {
+	return e.Type
+}
+
+// ToolResultEntry is tool output as older transcripts recorded it, in a
+// top-level entry rather than a tool_result block
+type ToolResultEntry struct {
+	EntryHeader
+	ToolName string          `json:"tool_name,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+}
+
+// TranscriptMessage is the API message carried by a user or assistant entry
+type TranscriptMessage struct {
+	ID           string         `json:"id,omitempty"`
+	Type         string         `json:"type,omitempty"`
+	Role         string         `json:"role,omitempty"`
+	Model        string         `json:"model,omitempty"`
+	Content      MessageContent `json:"content"`
+	StopReason   *string        `json:"stop_reason,omitempty"`
+	StopSequence *string        `json:"stop_sequence,omitempty"`
+	Usage        *MessageUsage  `json:"usage,omitempty"`
+}
+
+// MessageUsage is the token usage the API reported for a message
+type MessageUsage struct {
+	InputTokens              int             `json:"input_tokens"`
+	OutputTokens             int             `json:"output_tokens"`
+	CacheReadInputTokens     int             `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int             `json:"cache_creation_input_tokens"`
+	CacheCreation            json.RawMessage `json:"cache_creation,omitempty"` // Breakdown by cache lifetime
+	ServerToolUse            json.RawMessage `json:"server_tool_use,omitempty"`
+	ServiceTier              string          `json:"service_tier,omitempty"`
+}
+
+// MessageContent is message or tool result content, which the API allows to
+// be either a plain string or a list of content blocks
+type MessageContent struct {
+	Text   string
+	Blocks []ContentBlock
+}
+
+// UnmarshalJSON accepts both the string and the block list forms
+func (c *MessageContent) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &c.Text)
+	}
+	if string(data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(data, &c.Blocks)
+}
+
+// MarshalJSON writes the content back in the form it was read
+func (c MessageContent) MarshalJSON() ([]byte, error) {
+	if c.Blocks == nil {
+		return json.Marshal(c.Text)
+	}
+	return json.Marshal(c.Blocks)
+}
+
+// String joins the content's text, ignoring images and other non-text blocks
+func (c MessageContent) String() string {
+	if c.Blocks == nil {
+		return c.Text
+	}
+
+	var texts []string
+	for _, block := range c.Blocks {
+		if block.Text != "" {
+			texts = append(texts, block.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// ContentBlock is one block of message content. Which fields are set depends
+// on the block type.
+type ContentBlock struct {
+	Type      string          `json:"type"` // See knownBlockTypes
+	Text      string          `json:"text,omitempty"`
+	Citations json.RawMessage `json:"citations,omitempty"`
+
+	// thinking and redacted_thinking blocks
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"`
+
+	// tool_use and server_tool_use blocks
+	ID    string                 `json:"id,omitempty"`
+	Name  string                 `json:"name,omitempty"`
+	Input map[string]interface{} `json:"input,omitempty"`
+
+	// tool_result blocks, found in user messages
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   *MessageContent `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+
+	// image and document blocks
+	Source json.RawMessage `json:"source,omitempty"`
+}
+
+// knownBlockTypes are the content block types cnotes understands
+var knownBlockTypes = map[string]bool{
+	"text":                   true,
+	"thinking":               true,
+	"redacted_thinking":      true,
+	"tool_use":               true,
+	"tool_result":            true,
+	"server_tool_use":        true,
+	"web_search_tool_result": true,
+	"image":                  true,
+	"document":               true,
+}
+
+// newTranscriptRecord returns an empty record for an entry type, or nil if the
+// type is unknown
+func newTranscriptRecord(entryType string) TranscriptRecord {
+	switch entryType {
+	case "user":
+		return &UserEntry{}
+	case "assistant":
+		return &AssistantEntry{}
+	case "system":
+		return &SystemEntry{}
+	case "summary":
+		return &SummaryEntry{}
+	case "attachment":
+		return &AttachmentEntry{}
+	case "file-history-snapshot":
+		return &FileHistorySnapshotEntry{}
+	case "queue-operation":
+		return &QueueOperationEntry{}
+	case "tool_result":
+		return &ToolResultEntry{}
+	default:
+		return nil
+	}
+}
+
+// DecodeTranscriptEntry decodes one transcript line into its typed record.
+// Entries of an unknown type return a nil record and no error; pass a
+// DriftDetector to find out about them.
+func DecodeTranscriptEntry(line []byte) (TranscriptRecord, error) {
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(line, &envelope); err != nil {
+		return nil, err
+	}
+
+	record := newTranscriptRecord(envelope.Type)
+	if record == nil {
+		return nil, nil
+	}
+
+	if err := json.Unmarshal(line, record); err != nil {
+		return nil, fmt.Errorf("invalid %s entry: %w", envelope.Type, err)
+	}
+
+	return record, nil
+}
@@ -0,0 +1,116 @@
+package context
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestMessageContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		expected string
+		blocks   int
+	}{
+		{"string", `"hello"`, "hello", 0},
+		{"text blocks", `[{"type":"text","text":"one"},{"type":"image"},{"type":"text","text":"two"}]`, "one\ntwo", 3},
+		{"null", `null`, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var content MessageContent
+			if err := json.Unmarshal([]byte(tt.json), &content); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if content.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, content.String())
+			}
+
+			if len(content.Blocks) != tt.blocks {
+				t.Errorf("expected %d blocks, got %d", tt.blocks, len(content.Blocks))
+			}
+
+			// Content is written back in the form it was read
+			if tt.json != "null" {
+				data, err := json.Marshal(content)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				var roundTrip MessageContent
+				if err := json.Unmarshal(data, &roundTrip); err != nil || roundTrip.String() != tt.expected {
+					t.Errorf("round trip changed content: %s", data)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeTranscriptEntry(t *testing.T) {
+	t.Run("user tool result", func(t *testing.T) {
+		line := `{"type":"user","sessionId":"s1","timestamp":"2025-08-01T10:00:00.123Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","is_error":true,"content":"boom"}]}}`
+
+		record, err := DecodeTranscriptEntry([]byte(line))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		entry, ok := record.(*UserEntry)
+		if !ok {
+			t.Fatalf("expected *UserEntry, got %T", record)
+		}
+
+		if entry.Time().IsZero() {
+			t.Error("expected fractional-second timestamp to parse")
+		}
+
+		block := entry.Message.Content.Blocks[0]
+		if block.ToolUseID != "toolu_1" || !block.IsError || block.Content.String() != "boom" {
+			t.Errorf("unexpected tool result block: %+v", block)
+		}
+	})
+
+	t.Run("entry kinds", func(t *testing.T) {
+		tests := []struct {
+			line     string
+			expected string
+		}{
+			{`{"type":"assistant","message":{"content":[]}}`, "*context.AssistantEntry"},
+			{`{"type":"system","subtype":"compact_boundary","content":"Conversation compacted"}`, "*context.SystemEntry"},
+			{`{"type":"summary","summary":"Fix login","leafUuid":"u1"}`, "*context.SummaryEntry"},
+			{`{"type":"attachment","attachment":{"type":"file"}}`, "*context.AttachmentEntry"},
+			{`{"type":"file-history-snapshot","messageId":"m1","snapshot":{}}`, "*context.FileHistorySnapshotEntry"},
+			{`{"type":"queue-operation","operation":"enqueue","content":"next"}`, "*context.QueueOperationEntry"},
+			{`{"type":"tool_result","tool_name":"Bash","result":{"stdout":"ok"}}`, "*context.ToolResultEntry"},
+		}
+
+		for _, tt := range tests {
+			record, err := DecodeTranscriptEntry([]byte(tt.line))
+			if err != nil {
+				t.Fatalf("unexpected error for %s: %v", tt.line, err)
+			}
+			if got := fmt.Sprintf("%T", record); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		}
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		record, err := DecodeTranscriptEntry([]byte(`{"type":"hologram"}`))
+		if err != nil || record != nil {
+			t.Errorf("expected no record and no error, got %v, %v", record, err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := DecodeTranscriptEntry([]byte(`{"type":`)); err == nil {
+			t.Error("expected error for invalid JSON")
+		}
+
+		if _, err := DecodeTranscriptEntry([]byte(`{"type":"user","message":{"content":42}}`)); err == nil {
+			t.Error("expected error for content of the wrong shape")
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TranscriptCheckpoint records how far cnotes has read a transcript file
+type TranscriptCheckpoint struct {
+	Offset        int64                `json:"offset"`             // Bytes of complete lines already parsed
+	LastTimestamp time.Time            `json:"last_timestamp"`     // Latest entry timestamp before Offset
+	Sessions      map[string]time.Time `json:"sessions,omitempty"` // Latest entry timestamp before Offset of each session
+}
+
+// checkpointsPath returns the file holding every transcript's checkpoint
+func (j *Journal) checkpointsPath() string {
+	return filepath.Join(j.root, "transcripts.json")
+}
+
+// LoadCheckpoints returns the transcript checkpoints by transcript path. A
+// missing or unreadable checkpoint file yields no checkpoints, which only
+// means transcripts are read from the start.
+func (j *Journal) LoadCheckpoints() map[string]TranscriptCheckpoint {
+	checkpoints := make(map[string]TranscriptCheckpoint)
+
+	data, err := os.ReadFile(j.checkpointsPath())
+	if err != nil {
+		return checkpoints
+	}
+
+	if err := json.Unmarshal(data, &checkpoints); err != nil {
+		return make(map[string]TranscriptCheckpoint)
+	}
+
+	return checkpoints
+}
+
+// SaveCheckpoints replaces the stored transcript checkpoints
+func (j *Journal) SaveCheckpoints(checkpoints map[string]TranscriptCheckpoint) error {
+	if err := os.MkdirAll(j.root, 0755); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(checkpoints, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoints: %w", err)
+	}
+
+	// Write through a temporary file so a concurrent hook never reads half a file
+	tmp, err := os.CreateTemp(j.root, "transcripts-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), j.checkpointsPath()); err != nil {
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package journal
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCheckpoints(t *testing.T) {
+	j := New(t.TempDir())
+
+	if checkpoints := j.LoadCheckpoints(); len(checkpoints) != 0 {
+		t.Errorf("expected no checkpoints before any were saved, got %v", checkpoints)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	saved := map[string]TranscriptCheckpoint{
+		"/home/me/.claude/projects/repo/a.jsonl": {Offset: 1234, LastTimestamp: now},
+	}
+	if err := j.SaveCheckpoints(saved); err != nil {
+		t.Fatalf("failed to save checkpoints: %v", err)
+	}
+
+	loaded := j.LoadCheckpoints()
+	checkpoint := loaded["/home/me/.claude/projects/repo/a.jsonl"]
+	if checkpoint.Offset != 1234 || !checkpoint.LastTimestamp.Equal(now) {
+		t.Errorf("unexpected checkpoint after round trip: %+v", checkpoint)
+	}
+
+	// A corrupt file means starting over, not failing the hook
+	if err := os.WriteFile(j.checkpointsPath(), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if checkpoints := j.LoadCheckpoints(); len(checkpoints) != 0 {
+		t.Errorf("expected corrupt checkpoints to be ignored, got %v", checkpoints)
+	}
+}
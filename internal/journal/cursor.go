@@ -0,0 +1,98 @@
+package journal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Cursor marks how much of a session's conversation in a worktree has already
+// been attributed to a commit. The next commit gets the events after it.
+type Cursor struct {
+	SessionID string    `json:"session_id"`
+	Worktree  string    `json:"worktree"`              // Top-level directory of the worktree
+	ToolUseID string    `json:"tool_use_id,omitempty"` // Tool call that made the last attributed commit
+	Timestamp time.Time `json:"timestamp"`             // Time of the last attributed event
+	Commit    string    `json:"commit,omitempty"`      // Last commit the session made in the worktree
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// cursorPath returns the cursor file for a session in a worktree. Worktrees
+// share the git common dir, so the path is keyed by both.
+func (j *Journal) cursorPath(sessionID, worktree string) string {
+	sum := sha256.Sum256([]byte(worktree))
+	name := sanitizeFileName(sessionID) + "-" + hex.EncodeToString(sum[:6])
+	return filepath.Join(j.root, "cursors", name+".json")
+}
+
+// LoadCursor returns the cursor for a session in a worktree, or nil if the
+// session hasn't committed there yet
+func (j *Journal) LoadCursor(sessionID, worktree string) (*Cursor, error) {
+	data, err := os.ReadFile(j.cursorPath(sessionID, worktree))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cursor: %w", err)
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("failed to parse cursor: %w", err)
+	}
+
+	return &cursor, nil
+}
+
+// SaveCursor records how far a session's conversation has been attributed
+func (j *Journal) SaveCursor(cursor Cursor) error {
+	if cursor.SessionID == "" {
+		return fmt.Errorf("cursor has no session ID")
+	}
+	if cursor.UpdatedAt.IsZero() {
+		cursor.UpdatedAt = time.Now()
+	}
+
+	path := j.cursorPath(cursor.SessionID, cursor.Worktree)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cursor directory: %w", err)
+	}
+
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cursor: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cursor: %w", err)
+	}
+
+	return nil
+}
+
+// After returns the session's journal entries after a cursor, leaving out the
+// tool call the cursor points at. A nil cursor returns the whole session.
+func (j *Journal) After(sessionID string, cursor *Cursor) ([]Entry, error) {
+	if cursor == nil {
+		return j.Read(sessionID)
+	}
+
+	entries, err := j.Since(sessionID, cursor.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	var after []Entry
+	for _, entry := range entries {
+		if cursor.ToolUseID != "" && entry.ToolUseID == cursor.ToolUseID {
+			continue
+		}
+		after = append(after, entry)
+	}
+
+	return after, nil
+}
@@ -0,0 +1,64 @@
+package journal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCursors(t *testing.T) {
+	j := New(t.TempDir())
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	cursor, err := j.LoadCursor("session-1", "/src/repo")
+	if err != nil || cursor != nil {
+		t.Fatalf("expected no cursor before the first commit, got %v, %v", cursor, err)
+	}
+
+	if err := j.SaveCursor(Cursor{SessionID: "session-1", Worktree: "/src/repo", ToolUseID: "toolu_1", Timestamp: now}); err != nil {
+		t.Fatalf("failed to save cursor: %v", err)
+	}
+
+	t.Run("keyed by session and worktree", func(t *testing.T) {
+		cursor, err := j.LoadCursor("session-1", "/src/repo")
+		if err != nil {
+			t.Fatalf("failed to load cursor: %v", err)
+		}
+		if cursor == nil || cursor.ToolUseID != "toolu_1" || !cursor.Timestamp.Equal(now) || cursor.UpdatedAt.IsZero() {
+			t.Errorf("unexpected cursor %+v", cursor)
+		}
+
+		for _, other := range [][2]string{{"session-2", "/src/repo"}, {"session-1", "/src/repo-feature"}} {
+			if cursor, _ := j.LoadCursor(other[0], other[1]); cursor != nil {
+				t.Errorf("expected no cursor for %v, got %+v", other, cursor)
+			}
+		}
+	})
+
+	t.Run("entries after the cursor", func(t *testing.T) {
+		for _, entry := range []Entry{
+			{Timestamp: now.Add(-time.Second), Event: "UserPromptSubmit", SessionID: "session-1", Prompt: "Commit it"},
+			{Timestamp: now, Event: "PreToolUse", SessionID: "session-1", ToolUseID: "toolu_1"},
+			{Timestamp: now.Add(time.Second), Event: "PostToolUse", SessionID: "session-1", ToolUseID: "toolu_1"},
+			{Timestamp: now.Add(2 * time.Second), Event: "UserPromptSubmit", SessionID: "session-1", Prompt: "Now the docs"},
+		} {
+			if err := j.Append(entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		cursor, _ := j.LoadCursor("session-1", "/src/repo")
+		entries, err := j.After("session-1", cursor)
+		if err != nil {
+			t.Fatalf("failed to read entries: %v", err)
+		}
+
+		if len(entries) != 1 || entries[0].Prompt != "Now the docs" {
+			t.Errorf("expected only the prompt after the commit, got %+v", entries)
+		}
+
+		all, err := j.After("session-1", nil)
+		if err != nil || len(all) != 4 {
+			t.Errorf("expected the whole session without a cursor, got %d entries, %v", len(all), err)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Entry represents a single hook invocation recorded in a session journal
+type Entry struct {
+	Timestamp      time.Time       `json:"timestamp"`
+	Event          string          `json:"event"`
+	SessionID      string          `json:"session_id"`
+	ToolUseID      string          `json:"tool_use_id,omitempty"`
+	TranscriptPath string          `json:"transcript_path,omitempty"`
+	Prompt         string          `json:"prompt,omitempty"`  // UserPromptSubmit
+	Source         string          `json:"source,omitempty"`  // SessionStart: startup, resume, clear, compact
+	Trigger        string          `json:"trigger,omitempty"` // PreCompact: manual, auto
+	ToolName       string          `json:"tool_name,omitempty"`
+	ToolInput      json.RawMessage `json:"tool_input,omitempty"`
+	ToolResponse   json.RawMessage `json:"tool_response,omitempty"`
+	StopHookActive bool            `json:"stop_hook_active,omitempty"`
+}
+
+// Journal stores hook data per session under <git-common-dir>/cnotes
+type Journal struct {
+	root string
+}
+
+// New creates a journal rooted in the given git directory
+func New(gitDir string) *Journal {
+	return &Journal{
+		root: filepath.Join(gitDir, "cnotes"),
+	}
+}
+
+// sessionsDir returns the directory holding the per-session journal files
+func (j *Journal) sessionsDir() string {
+	return filepath.Join(j.root, "sessions")
+}
+
+// Path returns the journal file for a session
+func (j *Journal) Path(sessionID string) string {
+	return filepath.Join(j.sessionsDir(), sanitizeFileName(sessionID)+".jsonl")
+}
+
+// Append records an entry in its session's journal
+func (j *Journal) Append(entry Entry) error {
+	if entry.SessionID == "" {
+		return fmt.Errorf("journal entry has no session ID")
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	if err := os.MkdirAll(j.sessionsDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal entry: %w", err)
+	}
+
+	file, err := os.OpenFile(j.Path(entry.SessionID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal entry: %w", err)
+	}
+
+	return nil
+}
+
+// Read returns all entries recorded for a session in the order they were written
+func (j *Journal) Read(sessionID string) ([]Entry, error) {
+	return j.Since(sessionID, time.Time{})
+}
+
+// Since returns the entries for a session recorded after the given time
+func (j *Journal) Since(sessionID string, since time.Time) ([]Entry, error) {
+	file, err := os.Open(j.Path(sessionID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			var entry Entry
+			// Skip partially written lines
+			if jsonErr := json.Unmarshal(line, &entry); jsonErr == nil {
+				if since.IsZero() || entry.Timestamp.After(since) {
+					entries = append(entries, entry)
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read journal: %w", err)
+		}
+	}
+
+	return entries, nil
+}
+
+// sanitizeFileName makes a session or tool use ID safe to use as a file name
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
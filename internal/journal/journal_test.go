@@ -0,0 +1,127 @@
+package journal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendAndRead(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "cnotes-journal-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	j := New(tempDir)
+	now := time.Now()
+
+	entries := []Entry{
+		{Timestamp: now, Event: "SessionStart", SessionID: "session-1", Source: "startup"},
+		{Timestamp: now.Add(1 * time.Second), Event: "UserPromptSubmit", SessionID: "session-1", Prompt: "Fix the bug"},
+		{Timestamp: now.Add(2 * time.Second), Event: "UserPromptSubmit", SessionID: "session-2", Prompt: "Other session"},
+		{
+			Timestamp: now.Add(3 * time.Second),
+			Event:     "PostToolUse",
+			SessionID: "session-1",
+			ToolName:  "Bash",
+			ToolInput: json.RawMessage(`{"command":"git commit -m test"}`),
+		},
+	}
+
+	for _, entry := range entries {
+		if err := j.Append(entry); err != nil {
+			t.Fatalf("failed to append entry: %v", err)
+		}
+	}
+
+	t.Run("journal lives under cnotes directory", func(t *testing.T) {
+		expected := filepath.Join(tempDir, "cnotes", "sessions", "session-1.jsonl")
+		if _, err := os.Stat(expected); err != nil {
+			t.Errorf("expected journal at %s: %v", expected, err)
+		}
+	})
+
+	t.Run("read only returns the session's entries", func(t *testing.T) {
+		got, err := j.Read("session-1")
+		if err != nil {
+			t.Fatalf("failed to read journal: %v", err)
+		}
+
+		if len(got) != 3 {
+			t.Fatalf("expected 3 entries, got %d", len(got))
+		}
+
+		if got[1].Prompt != "Fix the bug" {
+			t.Errorf("unexpected prompt: %s", got[1].Prompt)
+		}
+
+		if got[2].ToolName != "Bash" || !strings.Contains(string(got[2].ToolInput), "git commit") {
+			t.Errorf("unexpected tool entry: %+v", got[2])
+		}
+	})
+
+	t.Run("since filters older entries", func(t *testing.T) {
+		got, err := j.Since("session-1", now.Add(1500*time.Millisecond))
+		if err != nil {
+			t.Fatalf("failed to read journal: %v", err)
+		}
+
+		if len(got) != 1 {
+			t.Fatalf("expected 1 entry, got %d", len(got))
+		}
+
+		if got[0].Event != "PostToolUse" {
+			t.Errorf("expected PostToolUse, got %s", got[0].Event)
+		}
+	})
+
+	t.Run("missing session", func(t *testing.T) {
+		got, err := j.Read("no-such-session")
+		if err != nil {
+			t.Fatalf("expected no error for missing journal, got: %v", err)
+		}
+
+		if len(got) != 0 {
+			t.Errorf("expected no entries, got %d", len(got))
+		}
+	})
+
+	t.Run("skip partially written lines", func(t *testing.T) {
+		file, err := os.OpenFile(j.Path("session-1"), os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatalf("failed to open journal: %v", err)
+		}
+		file.WriteString(`{"event":"Stop","sess`)
+		file.Close()
+
+		got, err := j.Read("session-1")
+		if err != nil {
+			t.Fatalf("failed to read journal: %v", err)
+		}
+
+		if len(got) != 3 {
+			t.Errorf("expected 3 entries, got %d", len(got))
+		}
+	})
+}
+
+func TestAppendRequiresSessionID(t *testing.T) {
+	j := New(t.TempDir())
+
+	if err := j.Append(Entry{Event: "Stop"}); err == nil {
+		t.Error("expected error for entry without session ID")
+	}
+}
+
+func TestPathSanitizesSessionID(t *testing.T) {
+	j := New("/repo/.git")
+
+	path := j.Path("../../etc/passwd")
+	if filepath.Dir(path) != filepath.Join("/repo/.git", "cnotes", "sessions") {
+		t.Errorf("session ID escaped the journal directory: %s", path)
+	}
+}
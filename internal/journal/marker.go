@@ -0,0 +1,81 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Marker records repository state captured by a PreToolUse hook so the
+// matching PostToolUse hook can tell what the tool call changed
+type Marker struct {
+	Timestamp   time.Time `json:"timestamp"`
+	SessionID   string    `json:"session_id"`
+	ToolUseID   string    `json:"tool_use_id,omitempty"`
+	Command     string    `json:"command,omitempty"`
+	Head        string    `json:"head,omitempty"` // Full object ID of HEAD, empty on an unborn branch
+	ReflogCount int       `json:"reflog_count"`   // Number of HEAD reflog entries
+}
+
+// markerPath returns the marker file for a tool call. Parallel tool calls in
+// the same session are told apart by their tool use ID when Claude provides one.
+func (j *Journal) markerPath(sessionID, toolUseID string) string {
+	name := sanitizeFileName(sessionID)
+	if toolUseID != "" {
+		name += "-" + sanitizeFileName(toolUseID)
+	}
+	return filepath.Join(j.root, "markers", name+".json")
+}
+
+// SaveMarker records a marker before a tool call runs
+func (j *Journal) SaveMarker(marker Marker) error {
+	if marker.SessionID == "" {
+		return fmt.Errorf("marker has no session ID")
+	}
+	if marker.Timestamp.IsZero() {
+		marker.Timestamp = time.Now()
+	}
+
+	path := j.markerPath(marker.SessionID, marker.ToolUseID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create marker directory: %w", err)
+	}
+
+	data, err := json.Marshal(marker)
+	if err != nil {
+		return fmt.Errorf("failed to marshal marker: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write marker: %w", err)
+	}
+
+	return nil
+}
+
+// TakeMarker returns and removes the marker recorded for a tool call.
+// It returns nil if no marker was recorded.
+func (j *Journal) TakeMarker(sessionID, toolUseID string) (*Marker, error) {
+	path := j.markerPath(sessionID, toolUseID)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read marker: %w", err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return nil, fmt.Errorf("failed to remove marker: %w", err)
+	}
+
+	var marker Marker
+	if err := json.Unmarshal(data, &marker); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal marker: %w", err)
+	}
+
+	return &marker, nil
+}
@@ -0,0 +1,66 @@
+package journal
+
+import (
+	"testing"
+)
+
+func TestSaveAndTakeMarker(t *testing.T) {
+	j := New(t.TempDir())
+	head := "1111111111111111111111111111111111111111"
+
+	if err := j.SaveMarker(Marker{SessionID: "session-1", ToolUseID: "toolu_1", Command: "git commit -q", Head: head}); err != nil {
+		t.Fatalf("failed to save marker: %v", err)
+	}
+	if err := j.SaveMarker(Marker{SessionID: "session-1", ToolUseID: "toolu_2", Command: "go test ./..."}); err != nil {
+		t.Fatalf("failed to save marker: %v", err)
+	}
+
+	t.Run("take returns the matching marker", func(t *testing.T) {
+		marker, err := j.TakeMarker("session-1", "toolu_1")
+		if err != nil {
+			t.Fatalf("failed to take marker: %v", err)
+		}
+
+		if marker == nil {
+			t.Fatal("expected marker, got nil")
+		}
+
+		if marker.Head != head {
+			t.Errorf("expected head %s, got %s", head, marker.Head)
+		}
+
+		if marker.Timestamp.IsZero() {
+			t.Error("expected timestamp to be set")
+		}
+	})
+
+	t.Run("take removes the marker", func(t *testing.T) {
+		marker, err := j.TakeMarker("session-1", "toolu_1")
+		if err != nil {
+			t.Fatalf("failed to take marker: %v", err)
+		}
+
+		if marker != nil {
+			t.Error("expected marker to be consumed")
+		}
+	})
+
+	t.Run("parallel tool calls keep separate markers", func(t *testing.T) {
+		marker, err := j.TakeMarker("session-1", "toolu_2")
+		if err != nil {
+			t.Fatalf("failed to take marker: %v", err)
+		}
+
+		if marker == nil || marker.Command != "go test ./..." {
+			t.Errorf("unexpected marker: %+v", marker)
+		}
+	})
+}
+
+func TestSaveMarkerRequiresSessionID(t *testing.T) {
+	j := New(t.TempDir())
+
+	if err := j.SaveMarker(Marker{Head: "abc"}); err == nil {
+		t.Error("expected error for marker without session ID")
+	}
+}
@@ -3,10 +3,10 @@ package notes
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 )
 
@@ -15,50 +15,24 @@ type NotesBackup struct {
 	BackupTime time.Time                   `json:"backup_time"`
 	NotesRef   string                      `json:"notes_ref"`
 	Notes      map[string]ConversationNote `json:"notes"` // commit_hash -> note
+	Skipped    []string                    `json:"-"`     // Commits whose notes aren't conversation notes, left out of the backup
 }
 
-// BackupAllNotes creates a backup of all notes in the specified ref
+// BackupAllNotes creates a backup of all notes in the specified ref. A note
+// written by a newer cnotes, or one git can't read, fails the backup; notes
+// that aren't conversation notes at all are left out and listed in Skipped.
 func (nm *NotesManager) BackupAllNotes(ctx context.Context) (*NotesBackup, error) {
-	// Get list of all commits with notes
-	output, err := nm.git.Execute(ctx, nm.workDir, "notes", "--ref", nm.notesRef, "list")
+	noted, skipped, err := nm.conversationNotes(ctx, nil)
 	if err != nil {
-		// No notes exist, return empty backup
-		return &NotesBackup{
-			BackupTime: time.Now(),
-			NotesRef:   nm.notesRef,
-			Notes:      make(map[string]ConversationNote),
-		}, nil
+		return nil, err
 	}
 
-	backup := &NotesBackup{
+	return &NotesBackup{
 		BackupTime: time.Now(),
 		NotesRef:   nm.notesRef,
-		Notes:      make(map[string]ConversationNote),
-	}
-
-	// Parse the output to get note SHA and commit SHA pairs
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-
-		// Format is: <note_sha> <commit_sha>
-		parts := strings.Fields(line)
-		if len(parts) != 2 {
-			continue
-		}
-
-		commitHash := parts[1]
-		note, err := nm.GetConversationNote(ctx, commitHash)
-		if err != nil || note == nil {
-			continue
-		}
-
-		backup.Notes[commitHash] = *note
-	}
-
-	return backup, nil
+		Notes:      noted,
+		Skipped:    skipped,
+	}, nil
 }
 
 // SaveBackupToFile saves a notes backup to a JSON file
@@ -86,15 +60,32 @@ func (nm *NotesManager) LoadBackupFromFile(filename string) (*NotesBackup, error
 		return nil, fmt.Errorf("failed to read backup file: %w", err)
 	}
 
-	var backup NotesBackup
-	if err := json.Unmarshal(data, &backup); err != nil {
+	// Notes are decoded one by one, since a backup can hold notes written
+	// with older schemas
+	var raw struct {
+		NotesBackup
+		Notes map[string]json.RawMessage `json:"notes"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal backup: %w", err)
 	}
 
+	backup := raw.NotesBackup
+	backup.Notes = make(map[string]ConversationNote, len(raw.Notes))
+	for commitHash, data := range raw.Notes {
+		note, _, err := decodeNote(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode backed up note for %s: %w", commitHash, err)
+		}
+		backup.Notes[commitHash] = note
+	}
+
 	return &backup, nil
 }
 
-// RestoreNotesFromBackup restores notes from a backup, trying to match them to current commits
+// RestoreNotesFromBackup restores notes from a backup, trying to match them to
+// current commits. A backed up note is merged into any note the commit has
+// since been given.
 func (nm *NotesManager) RestoreNotesFromBackup(ctx context.Context, backup *NotesBackup) error {
 	restored := 0
 	skipped := 0
@@ -102,23 +93,30 @@ func (nm *NotesManager) RestoreNotesFromBackup(ctx context.Context, backup *Note
 	for commitHash, note := range backup.Notes {
 		// Check if the commit still exists
 		_, err := nm.git.Execute(ctx, nm.workDir, "cat-file", "-e", commitHash)
-		if err != nil {
+		if errors.Is(err, ErrUnknownRevision) {
 			// Commit doesn't exist anymore, skip
 			skipped++
 			continue
 		}
+		if err != nil {
+			return fmt.Errorf("failed to look up commit %s: %w", commitHash, err)
+		}
 
-		// Check if note already exists
-		if nm.HasConversationNote(ctx, commitHash) {
-			// Note already exists, skip
+		// Merge the note into any the commit already has
+		written, err := nm.addConversationNote(ctx, commitHash, note)
+		if errors.Is(err, ErrNewerSchema) || errors.Is(err, errInvalidNote) {
+			// The commit has a note this cnotes can't merge into
 			skipped++
 			continue
 		}
-
-		// Restore the note
-		if err := nm.AddConversationNote(ctx, commitHash, note); err != nil {
+		if err != nil {
 			return fmt.Errorf("failed to restore note for commit %s: %w", commitHash, err)
 		}
+		if !written {
+			// The note already has everything in the backup
+			skipped++
+			continue
+		}
 
 		restored++
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -25,31 +26,25 @@ func TestBackupAllNotes(t *testing.T) {
 			nil,
 		)
 
-		// Mock getting note for commit1
-		note1 := ConversationNote{
+		// Mock reading the note blob for commit1
+		var note1 ConversationNote
+		note1.AddEntry(SessionEntry{
 			SessionID:           "session1",
 			Timestamp:           time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
 			ConversationExcerpt: "First conversation",
-		}
+		})
 		note1JSON, _ := json.Marshal(note1)
-		mockGit.SetResponse(
-			[]string{"notes", "--ref", "claude-conversations", "show", "commit1"},
-			note1JSON,
-			nil,
-		)
+		mockGit.SetObject("note-sha1", "blob", note1JSON)
 
-		// Mock getting note for commit2
-		note2 := ConversationNote{
+		// Mock reading the note blob for commit2
+		var note2 ConversationNote
+		note2.AddEntry(SessionEntry{
 			SessionID:           "session2",
 			Timestamp:           time.Date(2023, 1, 2, 12, 0, 0, 0, time.UTC),
 			ConversationExcerpt: "Second conversation",
-		}
+		})
 		note2JSON, _ := json.Marshal(note2)
-		mockGit.SetResponse(
-			[]string{"notes", "--ref", "claude-conversations", "show", "commit2"},
-			note2JSON,
-			nil,
-		)
+		mockGit.SetObject("note-sha2", "blob", note2JSON)
 
 		// Perform backup
 		backup, err := nm.BackupAllNotes(ctx)
@@ -82,8 +77,8 @@ func TestBackupAllNotes(t *testing.T) {
 		// Mock empty notes list
 		mockGit.SetResponse(
 			[]string{"notes", "--ref", "claude-conversations", "list"},
+			[]byte{},
 			nil,
-			errors.New("no notes found"),
 		)
 
 		backup, err := nm.BackupAllNotes(ctx)
@@ -96,6 +91,21 @@ func TestBackupAllNotes(t *testing.T) {
 		}
 	})
 
+	t.Run("with a git failure", func(t *testing.T) {
+		mockGit := NewMockGitExecutor()
+		nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+
+		mockGit.SetResponse(
+			[]string{"notes", "--ref", "claude-conversations", "list"},
+			nil,
+			&GitError{Args: []string{"notes"}, Stderr: "fatal: not a git repository", Err: ErrGitFailed, Cause: errors.New("exit status 128")},
+		)
+
+		if _, err := nm.BackupAllNotes(ctx); !errors.Is(err, ErrGitFailed) {
+			t.Errorf("expected the git failure, got %v", err)
+		}
+	})
+
 	t.Run("with malformed list output", func(t *testing.T) {
 		mockGit := NewMockGitExecutor()
 		nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
@@ -108,14 +118,10 @@ func TestBackupAllNotes(t *testing.T) {
 			nil,
 		)
 
-		// Mock getting note for commit1
+		// Mock reading the note blob for commit1
 		note1 := ConversationNote{SessionID: "session1"}
 		note1JSON, _ := json.Marshal(note1)
-		mockGit.SetResponse(
-			[]string{"notes", "--ref", "claude-conversations", "show", "commit1"},
-			note1JSON,
-			nil,
-		)
+		mockGit.SetObject("note-sha", "blob", note1JSON)
 
 		backup, err := nm.BackupAllNotes(ctx)
 		if err != nil {
@@ -145,9 +151,13 @@ func TestSaveAndLoadBackupFile(t *testing.T) {
 		NotesRef:   "claude-conversations",
 		Notes: map[string]ConversationNote{
 			"commit1": {
-				SessionID:           "session1",
-				Timestamp:           time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC),
-				ConversationExcerpt: "Test conversation",
+				SessionID: "session1",
+				Timestamp: time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC),
+				Entries: []SessionEntry{{
+					SessionID:           "session1",
+					Timestamp:           time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC),
+					ConversationExcerpt: "Test conversation",
+				}},
 			},
 		},
 	}
@@ -238,8 +248,8 @@ func TestRestoreNotesFromBackup(t *testing.T) {
 			BackupTime: time.Now(),
 			NotesRef:   "claude-conversations",
 			Notes: map[string]ConversationNote{
-				"commit1": {SessionID: "session1"},
-				"commit2": {SessionID: "session2"},
+				"commit1": {SchemaVersion: CurrentSchemaVersion, SessionID: "session1"},
+				"commit2": {SchemaVersion: CurrentSchemaVersion, SessionID: "session2"},
 			},
 		}
 
@@ -259,24 +269,24 @@ func TestRestoreNotesFromBackup(t *testing.T) {
 		mockGit.SetResponse(
 			[]string{"notes", "--ref", "claude-conversations", "show", "commit1"},
 			nil,
-			errors.New("no note"),
+			ErrNoNote,
 		)
 		mockGit.SetResponse(
 			[]string{"notes", "--ref", "claude-conversations", "show", "commit2"},
 			nil,
-			errors.New("no note"),
+			ErrNoNote,
 		)
 
 		// Mock successful note additions
 		note1JSON, _ := json.MarshalIndent(backup.Notes["commit1"], "", "  ")
-		mockGit.SetResponse(
-			[]string{"notes", "--ref", "claude-conversations", "add", "-m", string(note1JSON), "commit1"},
+		mockGit.SetInputResponse(
+			[]string{"notes", "--ref", "claude-conversations", "add", "-f", "-F", "-", "commit1"}, note1JSON,
 			[]byte{},
 			nil,
 		)
 		note2JSON, _ := json.MarshalIndent(backup.Notes["commit2"], "", "  ")
-		mockGit.SetResponse(
-			[]string{"notes", "--ref", "claude-conversations", "add", "-m", string(note2JSON), "commit2"},
+		mockGit.SetInputResponse(
+			[]string{"notes", "--ref", "claude-conversations", "add", "-f", "-F", "-", "commit2"}, note2JSON,
 			[]byte{},
 			nil,
 		)
@@ -300,8 +310,8 @@ func TestRestoreNotesFromBackup(t *testing.T) {
 
 		backup := &NotesBackup{
 			Notes: map[string]ConversationNote{
-				"missing": {SessionID: "session1"},
-				"exists":  {SessionID: "session2"},
+				"missing": {SchemaVersion: CurrentSchemaVersion, SessionID: "session1"},
+				"exists":  {SchemaVersion: CurrentSchemaVersion, SessionID: "session2"},
 			},
 		}
 
@@ -309,7 +319,7 @@ func TestRestoreNotesFromBackup(t *testing.T) {
 		mockGit.SetResponse(
 			[]string{"cat-file", "-e", "missing"},
 			nil,
-			errors.New("not found"),
+			ErrUnknownRevision,
 		)
 		mockGit.SetResponse(
 			[]string{"cat-file", "-e", "exists"},
@@ -321,13 +331,13 @@ func TestRestoreNotesFromBackup(t *testing.T) {
 		mockGit.SetResponse(
 			[]string{"notes", "--ref", "claude-conversations", "show", "exists"},
 			nil,
-			errors.New("no note"),
+			ErrNoNote,
 		)
 
 		// Mock successful note addition
 		noteJSON, _ := json.MarshalIndent(backup.Notes["exists"], "", "  ")
-		mockGit.SetResponse(
-			[]string{"notes", "--ref", "claude-conversations", "add", "-m", string(noteJSON), "exists"},
+		mockGit.SetInputResponse(
+			[]string{"notes", "--ref", "claude-conversations", "add", "-f", "-F", "-", "exists"}, noteJSON,
 			[]byte{},
 			nil,
 		)
@@ -339,7 +349,70 @@ func TestRestoreNotesFromBackup(t *testing.T) {
 		}
 	})
 
-	t.Run("skip existing notes", func(t *testing.T) {
+	t.Run("merge into existing notes", func(t *testing.T) {
+		mockGit := NewMockGitExecutor()
+		nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+
+		var backedUp ConversationNote
+		backedUp.AddEntry(SessionEntry{SessionID: "session1", Events: []Event{{ID: "e1", Role: RoleUser, Text: "Backed up"}}})
+		backup := &NotesBackup{
+			Notes: map[string]ConversationNote{"commit1": backedUp},
+		}
+
+		mockGit.SetResponse([]string{"cat-file", "-e", "commit1"}, []byte{}, nil)
+
+		// The commit was given a note by another session since the backup
+		var existing ConversationNote
+		existing.AddEntry(SessionEntry{SessionID: "session2", Events: []Event{{ID: "e2", Role: RoleUser, Text: "Since"}}})
+		existingJSON, _ := nm.marshalNote(existing)
+		mockGit.SetResponse([]string{"notes", "--ref", "claude-conversations", "show", "commit1"}, existingJSON, nil)
+
+		// Both sessions' entries are written back
+		merged := existing
+		merged.Merge(backedUp)
+		if len(merged.Entries) != 2 {
+			t.Fatalf("expected both sessions' entries, got %+v", merged.Entries)
+		}
+		mergedJSON, _ := nm.marshalNote(merged)
+		add := []string{"notes", "--ref", "claude-conversations", "add", "-f", "-F", "-", "commit1"}
+		mockGit.SetInputResponse(add, mergedJSON, []byte{}, nil)
+
+		if err := nm.RestoreNotesFromBackup(ctx, backup); err != nil {
+			t.Fatalf("failed to restore notes: %v", err)
+		}
+
+		executed := mockGit.GetExecutedCommands()
+		if last := executed[len(executed)-1]; fmt.Sprint(last.args) != fmt.Sprint(add) || string(last.input) != string(mergedJSON) {
+			t.Errorf("expected the merged note written, got %v with %s", last.args, last.input)
+		}
+	})
+
+	t.Run("skip notes already restored", func(t *testing.T) {
+		mockGit := NewMockGitExecutor()
+		nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+
+		var note ConversationNote
+		note.AddEntry(SessionEntry{SessionID: "session1", Events: []Event{{ID: "e1", Role: RoleUser, Text: "Backed up"}}})
+		backup := &NotesBackup{
+			Notes: map[string]ConversationNote{"commit1": note},
+		}
+
+		mockGit.SetResponse([]string{"cat-file", "-e", "commit1"}, []byte{}, nil)
+		noteJSON, _ := nm.marshalNote(note)
+		mockGit.SetResponse([]string{"notes", "--ref", "claude-conversations", "show", "commit1"}, noteJSON, nil)
+
+		if err := nm.RestoreNotesFromBackup(ctx, backup); err != nil {
+			t.Fatalf("failed to restore notes: %v", err)
+		}
+
+		for _, cmd := range mockGit.GetExecutedCommands() {
+			if len(cmd.args) > 3 && cmd.args[3] == "add" {
+				t.Error("should not have written a note that already has everything in the backup")
+			}
+		}
+	})
+
+	t.Run("stop on a git failure", func(t *testing.T) {
 		mockGit := NewMockGitExecutor()
 		nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
 
@@ -349,34 +422,14 @@ func TestRestoreNotesFromBackup(t *testing.T) {
 			},
 		}
 
-		// Mock commit exists
 		mockGit.SetResponse(
 			[]string{"cat-file", "-e", "commit1"},
-			[]byte{},
-			nil,
-		)
-
-		// Mock note already exists
-		existingNote := ConversationNote{SessionID: "existing"}
-		existingJSON, _ := json.Marshal(existingNote)
-		mockGit.SetResponse(
-			[]string{"notes", "--ref", "claude-conversations", "show", "commit1"},
-			existingJSON,
 			nil,
+			&GitError{Args: []string{"cat-file"}, Stderr: "fatal: not a git repository", Err: ErrGitFailed, Cause: errors.New("exit status 128")},
 		)
 
-		// Restore (should skip since note exists)
-		err := nm.RestoreNotesFromBackup(ctx, backup)
-		if err != nil {
-			t.Fatalf("failed to restore notes: %v", err)
-		}
-
-		// Verify no add command was executed
-		executed := mockGit.GetExecutedCommands()
-		for _, cmd := range executed {
-			if len(cmd.args) > 0 && cmd.args[0] == "add" {
-				t.Error("should not have attempted to add note that already exists")
-			}
+		if err := nm.RestoreNotesFromBackup(ctx, backup); !errors.Is(err, ErrGitFailed) {
+			t.Errorf("expected the git failure, got %v", err)
 		}
 	})
 }
@@ -401,14 +454,10 @@ func TestCreateRebaseBackup(t *testing.T) {
 		nil,
 	)
 
-	// Mock getting note
+	// Mock reading the note blob
 	note := ConversationNote{SessionID: "session1"}
 	noteJSON, _ := json.Marshal(note)
-	mockGit.SetResponse(
-		[]string{"notes", "--ref", "claude-conversations", "show", "commit1"},
-		noteJSON,
-		nil,
-	)
+	mockGit.SetObject("note-sha", "blob", noteJSON)
 
 	// Create rebase backup
 	filename, err := nm.CreateRebaseBackup(ctx)
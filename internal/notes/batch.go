@@ -0,0 +1,220 @@
+package notes
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ObjectReader reads objects from a repository one after another, without a
+// git process for each
+type ObjectReader interface {
+	// ReadObject returns the type and contents of the object a revision
+	// names, or ErrUnknownRevision if there is none
+	ReadObject(rev string) (objectType string, data []byte, err error)
+	Close() error
+}
+
+// ReadObjects starts a git cat-file --batch process to read objects through
+func (e *RealGitExecutor) ReadObjects(ctx context.Context, dir string) (ObjectReader, error) {
+	args := []string{"cat-file", "--batch"}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, &GitError{Args: args, Err: ErrGitFailed, Cause: err}
+	}
+
+	return &batchReader{cmd: cmd, args: args, stdin: stdin, stdout: bufio.NewReader(stdout), stderr: &stderr}, nil
+}
+
+// batchReader reads objects through a git cat-file --batch process
+type batchReader struct {
+	cmd    *exec.Cmd
+	args   []string
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *strings.Builder // Only safe to read once the process has exited
+	exited bool
+	err    error // How the process exited
+}
+
+func (r *batchReader) ReadObject(rev string) (string, []byte, error) {
+	if strings.Contains(rev, "\n") {
+		return "", nil, fmt.Errorf("%w: %q", ErrUnknownRevision, rev)
+	}
+	if _, err := io.WriteString(r.stdin, rev+"\n"); err != nil {
+		return "", nil, r.failed(err)
+	}
+
+	// Format is: <object> SP <type> SP <size> LF <contents> LF, or
+	// <rev> SP missing LF
+	header, err := r.stdout.ReadString('\n')
+	if err != nil {
+		return "", nil, r.failed(err)
+	}
+	fields := strings.Fields(header)
+	if len(fields) == 2 && (fields[1] == "missing" || fields[1] == "ambiguous") {
+		return "", nil, fmt.Errorf("%w: %s is %s", ErrUnknownRevision, rev, fields[1])
+	}
+	if len(fields) != 3 {
+		return "", nil, r.failed(fmt.Errorf("unexpected cat-file output %q", header))
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return "", nil, r.failed(fmt.Errorf("unexpected cat-file output %q", header))
+	}
+
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(r.stdout, data); err != nil {
+		return "", nil, r.failed(err)
+	}
+	return fields[1], data[:size], nil
+}
+
+// failed stops the process, reporting it failing with whatever it wrote to
+// stderr
+func (r *batchReader) failed(err error) error {
+	if !r.exited {
+		// It may be stuck writing output no one will read
+		r.cmd.Process.Kill()
+	}
+	r.wait()
+	return &GitError{Args: r.args, Stderr: strings.TrimSpace(r.stderr.String()), Err: ErrGitFailed, Cause: err}
+}
+
+// wait ends the process, once, and returns how it exited
+func (r *batchReader) wait() error {
+	if !r.exited {
+		r.stdin.Close()
+		r.err = r.cmd.Wait()
+		r.exited = true
+	}
+	return r.err
+}
+
+func (r *batchReader) Close() error {
+	if err := r.wait(); err != nil {
+		return &GitError{Args: r.args, Stderr: strings.TrimSpace(r.stderr.String()), Err: ErrGitFailed, Cause: err}
+	}
+	return nil
+}
+
+// listNotes returns the note blob of each annotated commit, by commit
+func (nm *NotesManager) listNotes(ctx context.Context) (map[string]string, error) {
+	// Without a notes ref, git lists nothing rather than failing
+	output, err := nm.git.Execute(ctx, nm.workDir, "notes", "--ref", nm.notesRef, "list")
+	if err != nil {
+		return nil, err
+	}
+
+	blobs := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		// Format is: <note_sha> <commit_sha>
+		parts := strings.Fields(line)
+		if len(parts) != 2 {
+			continue
+		}
+		blobs[parts[1]] = parts[0]
+	}
+	return blobs, nil
+}
+
+// GetConversationNotes retrieves the conversation notes of the given commits,
+// or of every annotated commit if commits is nil, reading them all through
+// one git process. Commits without a note, and notes that aren't
+// conversation notes, are left out.
+func (nm *NotesManager) GetConversationNotes(ctx context.Context, commits []string) (map[string]ConversationNote, error) {
+	noted, _, err := nm.conversationNotes(ctx, commits)
+	return noted, err
+}
+
+// conversationNotes reads notes like GetConversationNotes, also returning the
+// commits whose notes were left out for not being conversation notes
+func (nm *NotesManager) conversationNotes(ctx context.Context, commits []string) (map[string]ConversationNote, []string, error) {
+	blobs, err := nm.listNotes(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if commits != nil {
+		wanted := make(map[string]string)
+		for _, commit := range commits {
+			if blob, ok := blobs[commit]; ok {
+				wanted[commit] = blob
+			}
+		}
+		blobs = wanted
+	}
+
+	noted := make(map[string]ConversationNote)
+	if len(blobs) == 0 {
+		return noted, nil, nil
+	}
+
+	reader, err := nm.git.ReadObjects(ctx, nm.workDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var skipped []string
+	for commit, blob := range blobs {
+		_, data, err := reader.ReadObject(blob)
+		if err != nil {
+			reader.Close()
+			return nil, nil, fmt.Errorf("failed to read note for %s: %w", commit, err)
+		}
+
+		note, _, err := decodeNote(data)
+		if errors.Is(err, errInvalidNote) {
+			skipped = append(skipped, commit)
+			continue
+		}
+		if err != nil {
+			reader.Close()
+			return nil, nil, fmt.Errorf("failed to decode note for %s: %w", commit, err)
+		}
+		noted[commit] = note
+	}
+	sort.Strings(skipped)
+
+	return noted, skipped, reader.Close()
+}
+
+// parseCommit returns the author and subject of a raw commit object
+func parseCommit(hash string, data []byte) CommitInfo {
+	info := CommitInfo{Hash: hash}
+	header, message, _ := strings.Cut(string(data), "\n\n")
+	for _, line := range strings.Split(header, "\n") {
+		if author, ok := strings.CutPrefix(line, "author "); ok {
+			// Format is: <name> SP <email> SP <time> SP <zone>
+			if end := strings.LastIndex(author, ">"); end >= 0 {
+				author = author[:end+1]
+			}
+			info.Author = author
+		}
+	}
+
+	// Like git's %s, the subject is the first paragraph on one line
+	paragraph, _, _ := strings.Cut(strings.TrimLeft(message, "\n"), "\n\n")
+	lines := strings.Split(strings.TrimSpace(paragraph), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	info.Subject = strings.Join(lines, " ")
+	return info
+}
@@ -0,0 +1,130 @@
+package notes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestParseCommit(t *testing.T) {
+	data := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+		"author Ada Lovelace <ada@example.com> 1700000000 +0100\n" +
+		"committer Ada Lovelace <ada@example.com> 1700000000 +0100\n" +
+		"gpgsig -----BEGIN PGP SIGNATURE-----\n" +
+		" \n" +
+		" -----END PGP SIGNATURE-----\n" +
+		"\n" +
+		"Fix the panic\n" +
+		"in main\n" +
+		"\n" +
+		"The config was nil.\n"
+
+	info := parseCommit("abc123", []byte(data))
+	if info.Hash != "abc123" || info.Author != "Ada Lovelace <ada@example.com>" || info.Subject != "Fix the panic in main" {
+		t.Errorf("unexpected commit info: %+v", info)
+	}
+}
+
+func TestReadObjects(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		output, err := (&RealGitExecutor{}).Execute(ctx, dir, args...)
+		if err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	git("init", "-q")
+	git("config", "user.name", "Test")
+	git("config", "user.email", "test@example.com")
+	var commits []string
+	for i := range 20 {
+		git("commit", "-q", "--allow-empty", "-m", fmt.Sprintf("Commit %d\n\nBody of commit %d", i, i))
+		commit := git("rev-parse", "HEAD")
+		commits = append(commits, commit)
+		if i%2 == 0 {
+			note, _ := json.Marshal(ConversationNote{SchemaVersion: CurrentSchemaVersion, SessionID: fmt.Sprintf("session-%d", i)})
+			git("notes", "--ref", "claude-conversations", "add", "-m", string(note), commit)
+		}
+	}
+	git("commit", "-q", "--allow-empty", "-m", "Not a conversation note")
+	git("notes", "--ref", "claude-conversations", "add", "-m", "just text", "HEAD")
+
+	nm := NewNotesManager(dir)
+
+	t.Run("every note", func(t *testing.T) {
+		noted, err := nm.GetConversationNotes(ctx, nil)
+		if err != nil {
+			t.Fatalf("failed to read notes: %v", err)
+		}
+		if len(noted) != 10 {
+			t.Errorf("expected 10 conversation notes, got %d", len(noted))
+		}
+		if noted[commits[4]].SessionID != "session-4" {
+			t.Errorf("expected commit 4's note, got %+v", noted[commits[4]])
+		}
+	})
+
+	t.Run("backup", func(t *testing.T) {
+		backup, err := nm.BackupAllNotes(ctx)
+		if err != nil {
+			t.Fatalf("failed to back up notes: %v", err)
+		}
+		if len(backup.Notes) != 10 || fmt.Sprint(backup.Skipped) != fmt.Sprint([]string{git("rev-parse", "HEAD")}) {
+			t.Errorf("expected the note that isn't a conversation note reported, got %d notes, skipped %v", len(backup.Notes), backup.Skipped)
+		}
+	})
+
+	t.Run("some commits", func(t *testing.T) {
+		noted, err := nm.GetConversationNotes(ctx, commits[:3])
+		if err != nil {
+			t.Fatalf("failed to read notes: %v", err)
+		}
+		if len(noted) != 2 || noted[commits[2]].SessionID != "session-2" {
+			t.Errorf("expected the notes of commits 0 and 2, got %+v", noted)
+		}
+	})
+
+	t.Run("commit subjects", func(t *testing.T) {
+		infos, err := nm.CommitInfos(ctx, append(commits, strings.Repeat("0", 40)))
+		if err != nil {
+			t.Fatalf("failed to read commits: %v", err)
+		}
+		if len(infos) != len(commits) {
+			t.Errorf("expected %d commits, got %d", len(commits), len(infos))
+		}
+		info := infos[commits[7]]
+		if info.Subject != git("log", "-1", "--format=%s", commits[7]) || info.Author != "Test <test@example.com>" {
+			t.Errorf("unexpected commit info: %+v", info)
+		}
+	})
+
+	t.Run("missing object", func(t *testing.T) {
+		reader, err := nm.git.ReadObjects(ctx, dir)
+		if err != nil {
+			t.Fatalf("failed to start reading objects: %v", err)
+		}
+		if _, _, err := reader.ReadObject("nosuch"); !errors.Is(err, ErrUnknownRevision) {
+			t.Errorf("expected ErrUnknownRevision, got %v", err)
+		}
+		// The reader keeps going after a missing object
+		objectType, data, err := reader.ReadObject(commits[0])
+		if err != nil || objectType != "commit" || !strings.Contains(string(data), "Commit 0") {
+			t.Errorf("expected commit 0, got %s %q, %v", objectType, data, err)
+		}
+		if err := reader.Close(); err != nil {
+			t.Errorf("failed to close reader: %v", err)
+		}
+	})
+}
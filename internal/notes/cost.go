@@ -0,0 +1,161 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CommitInfo is the commit metadata a cost report is grouped by
+type CommitInfo struct {
+	Hash    string
+	Author  string // "Name <email>"
+	Subject string
+}
+
+// CostLine is one row of a cost report: the usage summed over a commit,
+// session or author
+type CostLine struct {
+	Key     string
+	Label   string // Commit subject, for commit rows
+	Commits int
+	Usage   ModelUsage
+}
+
+// CostReport sums token usage and estimated cost per commit, per session and
+// per author, most expensive first
+type CostReport struct {
+	Commits  []CostLine
+	Sessions []CostLine
+	Authors  []CostLine
+	Total    ModelUsage
+}
+
+// RevList returns the commits in a revision range such as main..feature
+func (nm *NotesManager) RevList(ctx context.Context, revRange string) ([]string, error) {
+	output, err := nm.git.Execute(ctx, nm.workDir, "rev-list", revRange)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits in %s: %w", revRange, err)
+	}
+
+	return strings.Fields(string(output)), nil
+}
+
+// CommitInfos looks up the author and subject of the given commits, reading
+// them all through one git process. Commits that no longer exist, such as
+// ones a note outlived, are left out.
+func (nm *NotesManager) CommitInfos(ctx context.Context, commits []string) (map[string]CommitInfo, error) {
+	infos := make(map[string]CommitInfo)
+	if len(commits) == 0 {
+		return infos, nil
+	}
+
+	reader, err := nm.git.ReadObjects(ctx, nm.workDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, commit := range commits {
+		objectType, data, err := reader.ReadObject(commit)
+		if errors.Is(err, ErrUnknownRevision) {
+			continue
+		}
+		if err != nil {
+			reader.Close()
+			return nil, fmt.Errorf("failed to read commit %s: %w", commit, err)
+		}
+		if objectType != "commit" {
+			reader.Close()
+			return nil, fmt.Errorf("%s is a %s, not a commit", commit, objectType)
+		}
+		infos[commit] = parseCommit(commit, data)
+	}
+
+	return infos, reader.Close()
+}
+
+// BuildCostReport sums the usage recorded in each commit's note. Usage from
+// each entry and amendment is attributed to the session that made it.
+func BuildCostReport(noted map[string]ConversationNote, infos map[string]CommitInfo) CostReport {
+	var report CostReport
+	sessions := make(map[string]*CostLine)
+	authors := make(map[string]*CostLine)
+
+	addTo := func(lines map[string]*CostLine, key string, usage ModelUsage, counted map[string]bool) {
+		line, ok := lines[key]
+		if !ok {
+			line = &CostLine{Key: key}
+			lines[key] = line
+		}
+		line.Usage.Add(usage)
+		if !counted[key] {
+			counted[key] = true
+			line.Commits++
+		}
+	}
+
+	for commit, note := range noted {
+		total := note.TotalUsage()
+		info := infos[commit]
+
+		report.Commits = append(report.Commits, CostLine{Key: commit, Label: info.Subject, Commits: 1, Usage: total})
+		report.Total.Add(total)
+
+		author := info.Author
+		if author == "" {
+			author = "unknown"
+		}
+		addTo(authors, author, total, map[string]bool{})
+
+		// Each entry's and amendment's usage belongs to the session that made it
+		counted := make(map[string]bool)
+		for _, entry := range note.Entries {
+			addTo(sessions, entry.SessionID, entry.TotalUsage(), counted)
+		}
+		for _, amendment := range note.Amendments {
+			addTo(sessions, amendment.SessionID, amendment.TotalUsage(), counted)
+		}
+	}
+
+	report.Commits = sortCostLines(report.Commits)
+	report.Sessions = sortCostLines(collectCostLines(sessions))
+	report.Authors = sortCostLines(collectCostLines(authors))
+
+	return report
+}
+
+// subtract removes another usage's messages, tokens and cost from u
+func (u *ModelUsage) subtract(other ModelUsage) {
+	u.Add(ModelUsage{
+		Messages:            -other.Messages,
+		InputTokens:         -other.InputTokens,
+		OutputTokens:        -other.OutputTokens,
+		CacheReadTokens:     -other.CacheReadTokens,
+		CacheCreationTokens: -other.CacheCreationTokens,
+		EstimatedCostUSD:    -other.EstimatedCostUSD,
+	})
+}
+
+func collectCostLines(lines map[string]*CostLine) []CostLine {
+	var collected []CostLine
+	for _, line := range lines {
+		collected = append(collected, *line)
+	}
+	return collected
+}
+
+// sortCostLines orders lines by estimated cost, then tokens, most expensive first
+func sortCostLines(lines []CostLine) []CostLine {
+	sort.Slice(lines, func(i, j int) bool {
+		a, b := lines[i].Usage, lines[j].Usage
+		if a.EstimatedCostUSD != b.EstimatedCostUSD {
+			return a.EstimatedCostUSD > b.EstimatedCostUSD
+		}
+		if a.TotalTokens() != b.TotalTokens() {
+			return a.TotalTokens() > b.TotalTokens()
+		}
+		return lines[i].Key < lines[j].Key
+	})
+	return lines
+}
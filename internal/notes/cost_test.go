@@ -0,0 +1,91 @@
+package notes
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCommitInfos(t *testing.T) {
+	ctx := context.Background()
+	mockGit := NewMockGitExecutor()
+	nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+
+	mockGit.SetObject("aaa", "commit", []byte("tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n"+
+		"author Alice <alice@example.com> 1700000000 +0000\n"+
+		"committer Alice <alice@example.com> 1700000000 +0000\n\n"+
+		"Fix bug\n"))
+	mockGit.SetObject("bbb", "commit", []byte("tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n"+
+		"parent aaa\n"+
+		"author Bob <bob@example.com> 1700000000 +0000\n"+
+		"committer Alice <alice@example.com> 1700000000 +0000\n\n"+
+		"Add feature:\nwith colon\n\nThe body.\n"))
+
+	infos, err := nm.CommitInfos(ctx, []string{"aaa", "bbb"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if infos["aaa"].Author != "Alice <alice@example.com>" || infos["bbb"].Subject != "Add feature: with colon" {
+		t.Errorf("unexpected commit infos: %v", infos)
+	}
+
+	infos, err = nm.CommitInfos(ctx, []string{"aaa", "ccc"})
+	if _, ok := infos["ccc"]; err != nil || ok || len(infos) != 1 {
+		t.Errorf("expected a missing commit to be left out, got %v, %v", infos, err)
+	}
+
+	infos, err = nm.CommitInfos(ctx, nil)
+	if err != nil || len(infos) != 0 {
+		t.Errorf("expected no lookups without commits, got %v, %v", infos, err)
+	}
+}
+
+func TestBuildCostReport(t *testing.T) {
+	sonnet := func(messages int, cost float64) []ModelUsage {
+		return []ModelUsage{{Model: "claude-sonnet-4", Messages: messages, InputTokens: 100 * messages, OutputTokens: 10 * messages, EstimatedCostUSD: cost}}
+	}
+
+	var aaa, bbb, ccc ConversationNote
+	aaa.AddEntry(SessionEntry{SessionID: "session-1", Models: sonnet(2, 2)})
+	aaa.AddAmendment(Amendment{SessionEntry: SessionEntry{SessionID: "session-2", Models: sonnet(1, 1)}})
+	bbb.AddEntry(SessionEntry{SessionID: "session-2", Models: []ModelUsage{{Model: "claude-opus-4", Messages: 2, InputTokens: 200, EstimatedCostUSD: 5}}})
+	bbb.AddEntry(SessionEntry{SessionID: "session-3", Models: []ModelUsage{{Model: "claude-opus-4", Messages: 1, InputTokens: 50, EstimatedCostUSD: 0.5}}})
+	ccc.AddEntry(SessionEntry{SessionID: "session-1"})
+	noted := map[string]ConversationNote{"aaa": aaa, "bbb": bbb, "ccc": ccc}
+	infos := map[string]CommitInfo{
+		"aaa": {Hash: "aaa", Author: "Alice <alice@example.com>", Subject: "Fix bug"},
+		"bbb": {Hash: "bbb", Author: "Alice <alice@example.com>", Subject: "Add feature"},
+		"ccc": {Hash: "ccc", Author: "Bob <bob@example.com>", Subject: "Docs"},
+	}
+
+	report := BuildCostReport(noted, infos)
+
+	if len(report.Commits) != 3 || report.Commits[0].Key != "bbb" || report.Commits[1].Label != "Fix bug" {
+		t.Errorf("expected commits by cost, got %+v", report.Commits)
+	}
+
+	if report.Total.EstimatedCostUSD != 8.5 || report.Total.TotalTokens() != 580 {
+		t.Errorf("unexpected total: %+v", report.Total)
+	}
+
+	sessions := make(map[string]CostLine)
+	for _, line := range report.Sessions {
+		sessions[line.Key] = line
+	}
+
+	// session-1 wrote aaa and ccc; session-2 amended aaa and wrote bbb,
+	// which session-3 contributed to too
+	if got := sessions["session-1"]; got.Commits != 2 || got.Usage.EstimatedCostUSD != 2 || got.Usage.InputTokens != 200 {
+		t.Errorf("unexpected session-1 line: %+v", got)
+	}
+	if got := sessions["session-2"]; got.Commits != 2 || got.Usage.EstimatedCostUSD != 6 || got.Usage.InputTokens != 300 {
+		t.Errorf("unexpected session-2 line: %+v", got)
+	}
+	if got := sessions["session-3"]; got.Commits != 1 || got.Usage.EstimatedCostUSD != 0.5 {
+		t.Errorf("unexpected session-3 line: %+v", got)
+	}
+
+	if len(report.Authors) != 2 || report.Authors[0].Key != "Alice <alice@example.com>" || report.Authors[0].Commits != 2 {
+		t.Errorf("unexpected authors: %+v", report.Authors)
+	}
+}
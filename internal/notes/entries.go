@@ -0,0 +1,298 @@
+package notes
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// AddEntry adds a session's conversation to the note. An entry for a session
+// the note already has is merged into that session's entry, leaving out the
+// events it already records, so the same conversation added twice, by a
+// retried hook or a restore, is only recorded once. It reports whether the
+// note changed.
+func (n *ConversationNote) AddEntry(entry SessionEntry) bool {
+	entry.copyConversation()
+
+	for i := range n.Entries {
+		if n.Entries[i].SessionID != entry.SessionID {
+			continue
+		}
+		if !n.Entries[i].merge(entry) {
+			return false
+		}
+		n.summarize()
+		return true
+	}
+
+	n.Entries = append(n.Entries, entry)
+	n.summarize()
+	return true
+}
+
+// Merge adds another note's entries and amendments to the note, skipping what
+// it already records, and reports whether the note changed
+func (n *ConversationNote) Merge(other ConversationNote) bool {
+	changed := false
+	for _, entry := range other.Entries {
+		if n.AddEntry(entry) {
+			changed = true
+		}
+	}
+
+	for _, amendment := range other.Amendments {
+		if !n.hasAmendment(amendment) {
+			n.Amendments = append(n.Amendments, amendment)
+			changed = true
+		}
+	}
+
+	if changed {
+		n.AddRedactions(other.Redactions)
+		n.summarize()
+	}
+	return changed
+}
+
+// hasAmendment reports whether the note records an amend by the same session
+// of the same commit
+func (n *ConversationNote) hasAmendment(amendment Amendment) bool {
+	for _, existing := range n.Amendments {
+		if existing.AmendedCommit == amendment.AmendedCommit && existing.SessionID == amendment.SessionID {
+			return true
+		}
+	}
+	return false
+}
+
+// summarize brings the note's summary fields up to date with its entries and
+// amendments
+func (n *ConversationNote) summarize() {
+	if len(n.Entries) > 0 {
+		n.SessionID = n.Entries[0].SessionID
+		n.Timestamp = n.Entries[0].Timestamp
+		n.PrivacyLevel = ""
+	}
+
+	var models [][]ModelUsage
+	add := func(entry SessionEntry) {
+		sessions := entry.Sessions
+		if len(sessions) == 0 && entry.SessionID != "" {
+			sessions = []string{entry.SessionID}
+		}
+		n.Sessions = mergeStrings(n.Sessions, sessions)
+		n.ToolsUsed = mergeStrings(n.ToolsUsed, entry.ToolsUsed)
+		models = append(models, entry.Models)
+		if entry.LastEventTime.After(n.LastEventTime) {
+			n.LastEventTime = entry.LastEventTime
+		}
+	}
+	for _, entry := range n.Entries {
+		add(entry)
+		if !entry.Timestamp.IsZero() && (n.Timestamp.IsZero() || entry.Timestamp.Before(n.Timestamp)) {
+			n.Timestamp = entry.Timestamp
+		}
+		n.PrivacyLevel = strictestPrivacyLevel(n.PrivacyLevel, entry.PrivacyLevel)
+	}
+	for _, amendment := range n.Amendments {
+		add(amendment.SessionEntry)
+	}
+
+	if merged := MergeModelUsage(models...); len(merged) > 0 {
+		n.Models = merged
+		n.ClaudeVersion = PrimaryModel(merged)
+	}
+}
+
+// merge adds the conversation of another entry for the same session, and
+// reports whether it had anything the entry didn't. When the two overlap,
+// such as when the session's conversation was extracted twice, the usage and
+// exclusions of the larger are kept; otherwise they're summed.
+func (e *SessionEntry) merge(other SessionEntry) bool {
+	added := newEvents(e.Events, other.Events)
+	overlap := len(added) < countEvents(other.Events)
+	switch {
+	case len(other.Events) > 0 && len(added) == 0:
+		return false
+	case len(other.Events) == 0 && strings.Contains(e.ConversationExcerpt, other.ConversationExcerpt):
+		return false
+	}
+
+	// Events can only stand in for the excerpts if both entries have them
+	if len(e.Events) == 0 && e.ConversationExcerpt != "" || len(other.Events) == 0 && other.ConversationExcerpt != "" {
+		e.Events = nil
+	} else {
+		if !overlap {
+			// The other entry's markers of elided events only make sense
+			// among its own events
+			added = other.Events
+		}
+		e.Events = mergeEvents(e.Events, added)
+	}
+	e.ConversationExcerpt = joinNonEmpty("\n\n", e.ConversationExcerpt, other.ConversationExcerpt)
+	if e.CommitContext != other.CommitContext {
+		e.CommitContext = joinNonEmpty("\n", e.CommitContext, other.CommitContext)
+	}
+
+	if overlap {
+		e.Models = largerModelUsage(e.Models, other.Models)
+		e.Excluded = largerCounts(e.Excluded, other.Excluded)
+	} else {
+		e.Models = MergeModelUsage(e.Models, other.Models)
+		e.Excluded = addCounts(e.Excluded, other.Excluded)
+	}
+
+	e.Sessions = mergeStrings(e.Sessions, other.Sessions)
+	e.ToolsUsed = mergeStrings(e.ToolsUsed, other.ToolsUsed)
+	e.Subagents = mergeSubagents(e.Subagents, other.Subagents)
+	e.PrivacyLevel = strictestPrivacyLevel(e.PrivacyLevel, other.PrivacyLevel)
+	if !other.Timestamp.IsZero() && other.Timestamp.Before(e.Timestamp) {
+		e.Timestamp = other.Timestamp
+	}
+	if other.LastEventTime.After(e.LastEventTime) {
+		e.LastEventTime = other.LastEventTime
+	}
+	return true
+}
+
+// eventKey identifies an event by its ID or, for events recorded without one,
+// by what it says and when
+func eventKey(event Event) string {
+	if event.ID != "" {
+		return event.ID
+	}
+	return strings.Join([]string{event.Role, event.Timestamp.Format(time.RFC3339Nano), event.Tool, event.Input, event.Text}, "\x00")
+}
+
+// newEvents returns the events of more, bar markers of elided events, that
+// aren't in events
+func newEvents(events, more []Event) []Event {
+	known := make(map[string]bool, len(events))
+	for _, event := range events {
+		known[eventKey(event)] = true
+	}
+
+	var added []Event
+	for _, event := range more {
+		if event.Role != RoleElided && !known[eventKey(event)] {
+			added = append(added, event)
+		}
+	}
+	return added
+}
+
+// countEvents counts the events that aren't markers of elided events
+func countEvents(events []Event) int {
+	count := 0
+	for _, event := range events {
+		if event.Role != RoleElided {
+			count++
+		}
+	}
+	return count
+}
+
+// mergeEvents interleaves two conversations by time, keeping each one's
+// order and each marker of elided events next to the events around it
+func mergeEvents(events, more []Event) []Event {
+	type timed struct {
+		event Event
+		at    time.Time
+	}
+	var merged []timed
+	for _, list := range [][]Event{events, more} {
+		// Markers go with the event before them, or the first event if
+		// they lead the conversation
+		var at time.Time
+		for _, event := range list {
+			if event.Role != RoleElided {
+				at = event.Timestamp
+				break
+			}
+		}
+		for _, event := range list {
+			if event.Role != RoleElided && event.Timestamp.After(at) {
+				at = event.Timestamp
+			}
+			merged = append(merged, timed{event, at})
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].at.Before(merged[j].at)
+	})
+
+	result := make([]Event, len(merged))
+	for i, item := range merged {
+		result[i] = item.event
+	}
+	return result
+}
+
+// mergeSubagents adds the subagents not yet in a list to it, telling them
+// apart by the Task tool use that started them
+func mergeSubagents(subagents, more []Subagent) []Subagent {
+	key := func(subagent Subagent) string {
+		if subagent.ToolUseID != "" {
+			return subagent.ToolUseID
+		}
+		return subagent.AgentID + "\x00" + subagent.Description + "\x00" + subagent.Prompt
+	}
+
+	known := make(map[string]bool, len(subagents))
+	for _, subagent := range subagents {
+		known[key(subagent)] = true
+	}
+	for _, subagent := range more {
+		if !known[key(subagent)] {
+			known[key(subagent)] = true
+			subagents = append(subagents, subagent)
+		}
+	}
+	return subagents
+}
+
+// largerModelUsage returns, for each model, whichever usage recorded more
+// messages
+func largerModelUsage(usage, other []ModelUsage) []ModelUsage {
+	byModel := make(map[string]ModelUsage)
+	for _, list := range [][]ModelUsage{usage, other} {
+		for _, u := range list {
+			if existing, ok := byModel[u.Model]; !ok || u.Messages > existing.Messages {
+				byModel[u.Model] = u
+			}
+		}
+	}
+
+	var larger []ModelUsage
+	for _, u := range byModel {
+		larger = append(larger, u)
+	}
+	return SortModelUsage(larger)
+}
+
+// largerCounts returns, for each key, the larger of two sets of counts,
+// without changing either
+func largerCounts(counts, other map[string]int) map[string]int {
+	if len(other) == 0 {
+		return counts
+	}
+	larger := make(map[string]int, len(counts)+len(other))
+	for key, count := range counts {
+		larger[key] = count
+	}
+	for key, count := range other {
+		larger[key] = max(larger[key], count)
+	}
+	return larger
+}
+
+// joinNonEmpty joins the strings that aren't empty
+func joinNonEmpty(sep string, parts ...string) string {
+	var nonEmpty []string
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, sep)
+}
@@ -0,0 +1,139 @@
+package notes
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAddEntry(t *testing.T) {
+	base := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+	texts := func(events []Event) string {
+		var parts []string
+		for _, event := range events {
+			if event.Role == RoleElided {
+				parts = append(parts, "elided")
+				continue
+			}
+			parts = append(parts, event.Text)
+		}
+		return strings.Join(parts, ",")
+	}
+	sonnet := func(messages int) []ModelUsage {
+		return []ModelUsage{{Model: "claude-sonnet-4", Messages: messages, InputTokens: 100 * messages}}
+	}
+
+	first := SessionEntry{
+		SessionID: "session-1",
+		Timestamp: at(10),
+		Events: []Event{
+			{ID: "a", Role: RoleUser, Text: "a", Timestamp: at(1)},
+			{ID: "b", Role: RoleAssistant, Text: "b", Timestamp: at(2)},
+		},
+		ToolsUsed: []string{"Bash"},
+		Models:    sonnet(2),
+		Subagents: []Subagent{{ToolUseID: "toolu_1"}},
+	}
+
+	t.Run("another session", func(t *testing.T) {
+		var note ConversationNote
+		note.AddEntry(first)
+		if !note.AddEntry(SessionEntry{SessionID: "session-2", Timestamp: at(5), ToolsUsed: []string{"Edit"}, Models: sonnet(1)}) {
+			t.Fatal("expected another session's entry to change the note")
+		}
+
+		if len(note.Entries) != 2 || note.Entries[1].SessionID != "session-2" {
+			t.Fatalf("expected an entry for each session, got %+v", note.Entries)
+		}
+		if note.SessionID != "session-1" || !note.Timestamp.Equal(at(5)) || strings.Join(note.Sessions, ",") != "session-1,session-2" {
+			t.Errorf("unexpected summary: %+v", note)
+		}
+		if strings.Join(note.ToolsUsed, ",") != "Bash,Edit" || note.TotalUsage().Messages != 3 || note.ClaudeVersion != "claude-sonnet-4" {
+			t.Errorf("expected the summary to cover both entries, got %+v", note)
+		}
+	})
+
+	t.Run("the same conversation again", func(t *testing.T) {
+		var note ConversationNote
+		note.AddEntry(first)
+		if note.AddEntry(first) {
+			t.Error("expected an entry the note already has to change nothing")
+		}
+		if len(note.Entries) != 1 || texts(note.Entries[0].Events) != "a,b" || note.TotalUsage().Messages != 2 {
+			t.Errorf("expected the note unchanged, got %+v", note)
+		}
+	})
+
+	t.Run("overlapping conversation", func(t *testing.T) {
+		var note ConversationNote
+		note.AddEntry(first)
+		note.AddEntry(SessionEntry{
+			SessionID: "session-1",
+			Events: []Event{
+				{Role: RoleElided, Elided: 3},
+				{ID: "b", Role: RoleAssistant, Text: "b", Timestamp: at(2)},
+				{ID: "c", Role: RoleUser, Text: "c", Timestamp: at(3)},
+			},
+			Models:    sonnet(3),
+			Subagents: []Subagent{{ToolUseID: "toolu_1"}, {ToolUseID: "toolu_2"}},
+		})
+
+		entry := note.Entries[0]
+		if texts(entry.Events) != "a,b,c" {
+			t.Errorf("expected each event once, without the other extraction's markers, got %s", texts(entry.Events))
+		}
+		// The later extraction covers the earlier one, so its usage does too
+		if usage := entry.TotalUsage(); usage.Messages != 3 {
+			t.Errorf("expected the larger usage, got %+v", usage)
+		}
+		if len(entry.Subagents) != 2 {
+			t.Errorf("expected each subagent once, got %+v", entry.Subagents)
+		}
+	})
+
+	t.Run("later conversation", func(t *testing.T) {
+		var note ConversationNote
+		note.AddEntry(first)
+		note.AddEntry(SessionEntry{
+			SessionID: "session-1",
+			Timestamp: at(20),
+			Events: []Event{
+				{Role: RoleElided, Elided: 2},
+				{ID: "d", Role: RoleUser, Text: "d", Timestamp: at(15)},
+			},
+			Models: sonnet(1),
+		})
+
+		entry := note.Entries[0]
+		if texts(entry.Events) != "a,b,elided,d" {
+			t.Errorf("expected the conversations joined in order, got %s", texts(entry.Events))
+		}
+		if usage := entry.TotalUsage(); usage.Messages != 3 {
+			t.Errorf("expected the usage summed, got %+v", usage)
+		}
+		if !entry.Timestamp.Equal(at(10)) {
+			t.Errorf("expected the earliest timestamp, got %v", entry.Timestamp)
+		}
+	})
+
+	t.Run("events without IDs", func(t *testing.T) {
+		prompt := Event{Role: RoleUser, Text: "From the journal", Timestamp: at(1)}
+		var note ConversationNote
+		note.AddEntry(SessionEntry{SessionID: "session-1", Events: []Event{prompt}})
+		if note.AddEntry(SessionEntry{SessionID: "session-1", Events: []Event{prompt}}) {
+			t.Error("expected the same event without an ID to be recognised")
+		}
+	})
+
+	t.Run("caller's entry left alone", func(t *testing.T) {
+		entry := first
+		entry.Events = append([]Event(nil), first.Events...)
+		var note ConversationNote
+		note.AddEntry(entry)
+		note.Entries[0].Events[0].Text = "changed"
+		if entry.Events[0].Text != "a" {
+			t.Error("expected the note to copy the entry's events")
+		}
+	})
+}
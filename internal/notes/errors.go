@@ -0,0 +1,94 @@
+package notes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// Errors a GitExecutor reports for a failed git command. A missing note or
+// revision is an answer, while anything else, such as a corrupt repository or
+// git not being installed, means the question went unanswered.
+var (
+	ErrNoNote          = errors.New("no note found")
+	ErrUnknownRevision = errors.New("unknown revision")
+	ErrGitFailed       = errors.New("git failed")
+)
+
+// GitError is a git command that failed
+type GitError struct {
+	Args   []string
+	Stderr string // What git wrote to stderr, if it ran
+	Err    error  // ErrNoNote, ErrUnknownRevision or ErrGitFailed
+	Cause  error  // Why the command failed, such as its exit status
+}
+
+func (e *GitError) Error() string {
+	detail := e.Stderr
+	if detail == "" {
+		detail = e.Cause.Error()
+	}
+	return fmt.Sprintf("git %s: %s", strings.Join(e.Args, " "), detail)
+}
+
+func (e *GitError) Unwrap() []error {
+	return []error{e.Err, e.Cause}
+}
+
+// runGit runs a git command and returns its output, or a *GitError telling
+// what kind of failure it was
+func runGit(cmd *exec.Cmd, args []string) ([]byte, error) {
+	// git's messages are told apart by their text, so they mustn't be translated
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err == nil {
+		return output, nil
+	}
+
+	gitErr := &GitError{Args: args, Stderr: strings.TrimSpace(stderr.String()), Err: ErrGitFailed, Cause: err}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		gitErr.Err = classifyGitFailure(args, exitErr.ExitCode(), gitErr.Stderr)
+	}
+	return nil, gitErr
+}
+
+// classifyGitFailure tells from a failed git command's exit code and stderr
+// whether it failed because a note or revision doesn't exist
+func classifyGitFailure(args []string, exitCode int, stderr string) error {
+	if strings.Contains(stderr, "no note found for object") {
+		return ErrNoNote
+	}
+	for _, message := range []string{
+		"unknown revision",
+		"bad revision",
+		"bad object",
+		"failed to resolve",
+		"not a valid object name",
+		"needed a single revision",
+		"invalid object name",
+	} {
+		if strings.Contains(strings.ToLower(stderr), message) {
+			return ErrUnknownRevision
+		}
+	}
+
+	// Checking whether an object exists fails quietly if it doesn't
+	if exitCode == 1 && stderr == "" && len(args) > 0 {
+		switch {
+		case args[0] == "cat-file" && slices.Contains(args, "-e"),
+			args[0] == "rev-parse" && slices.Contains(args, "--verify"),
+			// Commits with no history in common have no merge base to name
+			args[0] == "merge-base":
+			return ErrUnknownRevision
+		}
+	}
+
+	return ErrGitFailed
+}
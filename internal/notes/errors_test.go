@@ -0,0 +1,91 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestClassifyGitFailure(t *testing.T) {
+	for _, tt := range []struct {
+		args     []string
+		exitCode int
+		stderr   string
+		expected error
+	}{
+		{[]string{"notes", "show", "abc123"}, 1, "error: no note found for object abc123.", ErrNoNote},
+		{[]string{"notes", "show", "nosuch"}, 128, "fatal: failed to resolve 'nosuch' as a valid ref.", ErrUnknownRevision},
+		{[]string{"rev-list", "nosuch..HEAD"}, 128, "fatal: ambiguous argument 'nosuch..HEAD': unknown revision or path not in the working tree.", ErrUnknownRevision},
+		{[]string{"cat-file", "-e", "nosuch"}, 128, "fatal: Not a valid object name nosuch", ErrUnknownRevision},
+		{[]string{"cat-file", "-e", "abc123"}, 1, "", ErrUnknownRevision},
+		{[]string{"rev-parse", "--verify", "--quiet", "HEAD^{commit}"}, 1, "", ErrUnknownRevision},
+		{[]string{"merge-base", "abc123", "def456"}, 1, "", ErrUnknownRevision},
+		{[]string{"config", "--get", "notes.rewriteRef"}, 1, "", ErrGitFailed},
+		{[]string{"notes", "list"}, 128, "fatal: not a git repository (or any of the parent directories): .git", ErrGitFailed},
+	} {
+		if got := classifyGitFailure(tt.args, tt.exitCode, tt.stderr); got != tt.expected {
+			t.Errorf("git %v exiting %d with %q: expected %v, got %v", tt.args, tt.exitCode, tt.stderr, tt.expected, got)
+		}
+	}
+}
+
+func TestRealGitExecutorErrors(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	git := &RealGitExecutor{}
+	if _, err := git.Execute(ctx, dir, "init", "-q"); err != nil {
+		t.Fatalf("git init failed: %v", err)
+	}
+	for _, args := range [][]string{
+		{"config", "user.name", "Test"},
+		{"config", "user.email", "test@example.com"},
+		{"commit", "-q", "--allow-empty", "-m", "first"},
+	} {
+		if _, err := git.Execute(ctx, dir, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+
+	nm := NewNotesManager(dir)
+
+	t.Run("no note", func(t *testing.T) {
+		if _, err := git.Execute(ctx, dir, "notes", "show", "HEAD"); !errors.Is(err, ErrNoNote) {
+			t.Errorf("expected ErrNoNote, got %v", err)
+		}
+		note, err := nm.GetConversationNote(ctx, "HEAD")
+		if note != nil || err != nil {
+			t.Errorf("expected no note and no error, got %+v, %v", note, err)
+		}
+	})
+
+	t.Run("unknown revision", func(t *testing.T) {
+		if _, err := nm.GetConversationNote(ctx, "nosuch"); !errors.Is(err, ErrUnknownRevision) {
+			t.Errorf("expected ErrUnknownRevision, got %v", err)
+		}
+		if _, err := nm.ResolveCommit(ctx, "nosuch"); !errors.Is(err, ErrUnknownRevision) {
+			t.Errorf("expected ErrUnknownRevision, got %v", err)
+		}
+	})
+
+	t.Run("not a repository", func(t *testing.T) {
+		nm := NewNotesManager(t.TempDir())
+		_, err := nm.GetConversationNote(ctx, "HEAD")
+		if !errors.Is(err, ErrGitFailed) {
+			t.Fatalf("expected ErrGitFailed, got %v", err)
+		}
+
+		var gitErr *GitError
+		if !errors.As(err, &gitErr) || !strings.Contains(gitErr.Stderr, "not a git repository") {
+			t.Errorf("expected the error to carry git's stderr, got %v", err)
+		}
+		if _, err := nm.BackupAllNotes(ctx); !errors.Is(err, ErrGitFailed) {
+			t.Errorf("expected listing notes to fail, got %v", err)
+		}
+	})
+}
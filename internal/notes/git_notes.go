@@ -1,38 +1,292 @@
 package notes
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/imjasonh/cnotes/internal/redact"
 )
 
-// GitExecutor defines the interface for executing git commands
+// GitExecutor defines the interface for executing git commands. A failed
+// command's error matches ErrNoNote, ErrUnknownRevision or ErrGitFailed under
+// errors.Is.
 type GitExecutor interface {
 	Execute(ctx context.Context, dir string, args ...string) ([]byte, error)
+	// ExecuteWithInput runs a command with input on stdin, for writing
+	// notes and objects too large to pass as arguments
+	ExecuteWithInput(ctx context.Context, dir string, input []byte, args ...string) ([]byte, error)
+	// ReadObjects starts reading objects through one process, for reading
+	// many, such as every note, without a process each
+	ReadObjects(ctx context.Context, dir string) (ObjectReader, error)
 }
 
-// ConversationNote represents the structured data we store in git notes
+// ConversationNote represents the structured data we store in git notes: the
+// conversation each session contributed to a commit, one entry per session,
+// under a summary of them all
 type ConversationNote struct {
-	SessionID           string    `json:"session_id"`
-	Timestamp           time.Time `json:"timestamp"`
-	ConversationExcerpt string    `json:"conversation_excerpt"`
-	ToolsUsed           []string  `json:"tools_used"`
-	CommitContext       string    `json:"commit_context"`
-	ClaudeVersion       string    `json:"claude_version"`
-	LastEventTime       time.Time `json:"last_event_time,omitempty"` // Track last processed event to avoid duplicates
+	SchemaVersion int            `json:"schema_version"`            // See CurrentSchemaVersion
+	SessionID     string         `json:"session_id"`                // The first session to contribute
+	Sessions      []string       `json:"sessions,omitempty"`        // Sessions whose conversation the note carries, under the attribution policy
+	Timestamp     time.Time      `json:"timestamp"`                 // When the first entry was recorded
+	ToolsUsed     []string       `json:"tools_used"`                // Tools used in any entry or amendment
+	ClaudeVersion string         `json:"claude_version"`            // Most used model; see Models for all of them
+	Models        []ModelUsage   `json:"models,omitempty"`          // Models that appeared in the conversation, most used first, including amendments
+	LastEventTime time.Time      `json:"last_event_time,omitempty"` // Track last processed event to avoid duplicates
+	PrivacyLevel  string         `json:"privacy_level,omitempty"`   // The strictest of the entries' privacy levels
+	Entries       []SessionEntry `json:"entries"`                   // The conversation of each session, in the order they contributed
+	Amendments    []Amendment    `json:"amendments,omitempty"`      // Conversation from later git commit --amend runs
+	Redactions    map[string]int `json:"redactions,omitempty"`      // How many secrets each detection rule redacted from the note
+}
+
+// SessionEntry records the conversation one session contributed to a commit.
+// The note's summary fields are kept up to date from its entries by AddEntry.
+type SessionEntry struct {
+	SessionID           string         `json:"session_id"`
+	Sessions            []string       `json:"sessions,omitempty"` // Sessions whose conversation the entry carries, under the attribution policy
+	Timestamp           time.Time      `json:"timestamp"`
+	ConversationExcerpt string         `json:"conversation_excerpt"` // Rendered Events, for older readers; see Events
+	Events              []Event        `json:"events,omitempty"`     // The conversation, rendered when the note is shown
+	Excluded            map[string]int `json:"excluded,omitempty"`   // Events the relevance filter left out, by type
+	ToolsUsed           []string       `json:"tools_used"`
+	CommitContext       string         `json:"commit_context"`
+	Models              []ModelUsage   `json:"models,omitempty"`
+	LastEventTime       time.Time      `json:"last_event_time,omitempty"`
+	PrivacyLevel        string         `json:"privacy_level,omitempty"` // How much of the conversation was captured: minimal, standard or full
+	Subagents           []Subagent     `json:"subagents,omitempty"`     // Conversations of the subagents the session started
+}
+
+// Subagent records the conversation of a subagent a Task tool use started
+type Subagent struct {
+	ToolUseID           string  `json:"tool_use_id,omitempty"` // The Task tool use that started it
+	AgentID             string  `json:"agent_id,omitempty"`
+	Type                string  `json:"type,omitempty"`
+	Description         string  `json:"description,omitempty"`
+	Prompt              string  `json:"prompt,omitempty"`
+	ConversationExcerpt string  `json:"conversation_excerpt,omitempty"`
+	Events              []Event `json:"events,omitempty"`
+	Result              string  `json:"result,omitempty"` // What the subagent reported back
+}
+
+// Amendment records the conversation that led to amending a commit
+type Amendment struct {
+	AmendedCommit string `json:"amended_commit"` // The commit as it was before the amend
+	SessionEntry
+}
+
+// Event is one step of a conversation. Notes store events rather than only a
+// rendered excerpt so that they can be shown however the reader likes.
+type Event struct {
+	ID        string    `json:"id,omitempty"` // Tells the event apart from others when entries are merged
+	Role      string    `json:"role"`         // user, assistant, thinking, tool, or elided for events left out to keep the note short
+	Timestamp time.Time `json:"timestamp,omitempty"`
+	Text      string    `json:"text,omitempty"`     // What the user or Claude said
+	Tool      string    `json:"tool,omitempty"`     // For tool uses, the tool's name
+	Input     string    `json:"input,omitempty"`    // For tool uses, the gist of the input, e.g. a file path or command
+	Result    string    `json:"result,omitempty"`   // For tool uses, the output, if the privacy level keeps it
+	IsError   bool      `json:"is_error,omitempty"` // For tool uses, whether the tool failed
+	Elided    int       `json:"elided,omitempty"`   // For elided events, how many were left out
+}
+
+// Event roles
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleThinking  = "thinking"
+	RoleTool      = "tool"
+	RoleElided    = "elided"
+)
+
+// ModelUsage records what a model contributed to a conversation: its
+// assistant messages, the tokens they used and their estimated cost
+type ModelUsage struct {
+	Model               string  `json:"model"`
+	Messages            int     `json:"messages"`
+	InputTokens         int     `json:"input_tokens,omitempty"`
+	OutputTokens        int     `json:"output_tokens,omitempty"`
+	CacheReadTokens     int     `json:"cache_read_tokens,omitempty"`
+	CacheCreationTokens int     `json:"cache_creation_tokens,omitempty"`
+	EstimatedCostUSD    float64 `json:"estimated_cost_usd,omitempty"` // From the pricing configured when the note was written
+}
+
+// Add sums another model's messages, tokens and cost into u
+func (u *ModelUsage) Add(other ModelUsage) {
+	u.Messages += other.Messages
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheReadTokens += other.CacheReadTokens
+	u.CacheCreationTokens += other.CacheCreationTokens
+	u.EstimatedCostUSD += other.EstimatedCostUSD
+}
+
+// TotalTokens returns the number of tokens of every kind
+func (u ModelUsage) TotalTokens() int {
+	return u.InputTokens + u.OutputTokens + u.CacheReadTokens + u.CacheCreationTokens
+}
+
+// SortModelUsage orders model usage most used first
+func SortModelUsage(usage []ModelUsage) []ModelUsage {
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Messages != usage[j].Messages {
+			return usage[i].Messages > usage[j].Messages
+		}
+		return usage[i].Model < usage[j].Model
+	})
+
+	return usage
+}
+
+// MergeModelUsage combines model usage lists, summing messages and tokens per model
+func MergeModelUsage(lists ...[]ModelUsage) []ModelUsage {
+	byModel := make(map[string]*ModelUsage)
+	var merged []ModelUsage
+	for _, list := range lists {
+		for _, usage := range list {
+			if total, ok := byModel[usage.Model]; ok {
+				total.Add(usage)
+				continue
+			}
+			byModel[usage.Model] = &ModelUsage{Model: usage.Model}
+			byModel[usage.Model].Add(usage)
+		}
+	}
+
+	for _, usage := range byModel {
+		merged = append(merged, *usage)
+	}
+	return SortModelUsage(merged)
+}
+
+// TotalUsage sums the usage of every model in the note, including amendments
+func (n *ConversationNote) TotalUsage() ModelUsage {
+	var total ModelUsage
+	for _, usage := range n.Models {
+		total.Add(usage)
+	}
+	return total
+}
+
+// TotalUsage sums the usage of every model in the entry
+func (e *SessionEntry) TotalUsage() ModelUsage {
+	var total ModelUsage
+	for _, usage := range e.Models {
+		total.Add(usage)
+	}
+	return total
+}
+
+// PrimaryModel returns the most used model, or "" if none were recorded
+func PrimaryModel(usage []ModelUsage) string {
+	if len(usage) == 0 {
+		return ""
+	}
+	return usage[0].Model
+}
+
+// AddAmendment appends an amendment to the note's history, keeping the
+// original conversation and advancing the last processed event
+func (n *ConversationNote) AddAmendment(amendment Amendment) {
+	n.Amendments = append(n.Amendments, amendment)
+	n.summarize()
+}
+
+// Redact passes every piece of conversation text in the note through redact:
+// excerpts, commit contexts and subagent prompts and results, including those
+// of amendments
+func (n *ConversationNote) Redact(redact func(string) string) {
+	n.eachText(func(_ string, text *string) {
+		*text = redact(*text)
+	})
+}
+
+// eachText calls fn with every piece of conversation text in the note, named
+// by its JSON field path, such as amendments[0].conversation_excerpt
+func (n *ConversationNote) eachText(fn func(field string, text *string)) {
+	for i := range n.Entries {
+		n.Entries[i].eachText(fmt.Sprintf("entries[%d].", i), fn)
+	}
+	for i := range n.Amendments {
+		n.Amendments[i].eachText(fmt.Sprintf("amendments[%d].", i), fn)
+	}
+}
+
+// eachText calls fn with every piece of conversation text in the entry, its
+// field path starting with prefix
+func (e *SessionEntry) eachText(prefix string, fn func(field string, text *string)) {
+	fn(prefix+"conversation_excerpt", &e.ConversationExcerpt)
+	eachEventText(prefix, e.Events, fn)
+	fn(prefix+"commit_context", &e.CommitContext)
+	eachSubagentText(prefix, e.Subagents, fn)
+}
+
+// AddRedactions records how many secrets each rule redacted from the note
+func (n *ConversationNote) AddRedactions(counts map[string]int) {
+	n.Redactions = addCounts(n.Redactions, counts)
+}
+
+// addCounts returns the sum of two sets of counts, without changing either
+func addCounts(counts, more map[string]int) map[string]int {
+	if len(more) == 0 {
+		return counts
+	}
+	sum := make(map[string]int, len(counts)+len(more))
+	for key, count := range counts {
+		sum[key] = count
+	}
+	for key, count := range more {
+		sum[key] += count
+	}
+	return sum
+}
+
+// eachSubagentText calls fn with the text of each subagent
+func eachSubagentText(prefix string, subagents []Subagent, fn func(field string, text *string)) {
+	for i := range subagents {
+		subagent := &subagents[i]
+		field := fmt.Sprintf("%ssubagents[%d].", prefix, i)
+		fn(field+"description", &subagent.Description)
+		fn(field+"prompt", &subagent.Prompt)
+		fn(field+"conversation_excerpt", &subagent.ConversationExcerpt)
+		eachEventText(field, subagent.Events, fn)
+		fn(field+"result", &subagent.Result)
+	}
+}
+
+// eachEventText calls fn with the text, input and result of each event
+func eachEventText(prefix string, events []Event, fn func(field string, text *string)) {
+	for i := range events {
+		event := &events[i]
+		field := fmt.Sprintf("%sevents[%d].", prefix, i)
+		fn(field+"text", &event.Text)
+		fn(field+"input", &event.Input)
+		fn(field+"result", &event.Result)
+	}
 }
 
 // RealGitExecutor is the default implementation that runs actual git commands
 type RealGitExecutor struct{}
 
-// Execute runs a git command and returns its output
+// Execute runs a git command and returns its output, or a *GitError
 func (e *RealGitExecutor) Execute(ctx context.Context, dir string, args ...string) ([]byte, error) {
 	cmd := exec.CommandContext(ctx, "git", args...)
 	cmd.Dir = dir
-	return cmd.Output()
+	return runGit(cmd, args)
+}
+
+// ExecuteWithInput runs a git command with input on stdin and returns its
+// output, or a *GitError
+func (e *RealGitExecutor) ExecuteWithInput(ctx context.Context, dir string, input []byte, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(input)
+	return runGit(cmd, args)
 }
 
 // NotesManager handles git notes operations for Claude conversations
@@ -40,6 +294,7 @@ type NotesManager struct {
 	notesRef string
 	workDir  string
 	git      GitExecutor
+	redactor *redact.Redactor
 }
 
 // NewNotesManager creates a new notes manager
@@ -48,6 +303,7 @@ func NewNotesManager(workDir string) *NotesManager {
 		notesRef: "claude-conversations",
 		workDir:  workDir,
 		git:      &RealGitExecutor{},
+		redactor: redact.FromConfig(nil),
 	}
 }
 
@@ -57,6 +313,7 @@ func NewNotesManagerWithExecutor(workDir string, git GitExecutor) *NotesManager
 		notesRef: "claude-conversations",
 		workDir:  workDir,
 		git:      git,
+		redactor: redact.FromConfig(nil),
 	}
 }
 
@@ -67,16 +324,93 @@ func (nm *NotesManager) SetNotesRef(ref string) {
 	}
 }
 
-// AddConversationNote adds a conversation note to a specific commit
+// SetRedactor replaces the redactor every note passes through before it is
+// written. By default only the built-in secret patterns are redacted.
+func (nm *NotesManager) SetRedactor(redactor *redact.Redactor) {
+	if redactor != nil {
+		nm.redactor = redactor
+	}
+}
+
+// marshalNote redacts a note and marshals it for storage. Every write goes
+// through here, so no conversation text reaches git unredacted.
+func (nm *NotesManager) marshalNote(note ConversationNote) ([]byte, error) {
+	// Don't modify the caller's entries and amendments
+	note.Entries = append([]SessionEntry(nil), note.Entries...)
+	for i := range note.Entries {
+		note.Entries[i].copyConversation()
+	}
+	note.Amendments = append([]Amendment(nil), note.Amendments...)
+	for i := range note.Amendments {
+		note.Amendments[i].copyConversation()
+	}
+	counts := make(map[string]int)
+	note.Redact(func(text string) string {
+		redacted, findings := nm.redactor.RedactFindings(text)
+		redact.Count(counts, findings)
+		return redacted
+	})
+	note.AddRedactions(counts)
+	note.SchemaVersion = CurrentSchemaVersion
+
+	data, err := json.MarshalIndent(note, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal note: %w", err)
+	}
+	return data, nil
+}
+
+// copyConversation copies the entry's events and subagents, so that changing
+// them leaves the entry it was copied from alone
+func (e *SessionEntry) copyConversation() {
+	e.Events = append([]Event(nil), e.Events...)
+	e.Subagents = append([]Subagent(nil), e.Subagents...)
+	for i := range e.Subagents {
+		e.Subagents[i].Events = append([]Event(nil), e.Subagents[i].Events...)
+	}
+}
+
+// AddConversationNote adds a conversation note to a specific commit. If the
+// commit already has one, such as from another session that made the commit
+// too, the two are merged rather than one replacing the other.
 func (nm *NotesManager) AddConversationNote(ctx context.Context, commitHash string, note ConversationNote) error {
-	// Marshal the note to JSON
-	noteData, err := json.MarshalIndent(note, "", "  ")
+	_, err := nm.addConversationNote(ctx, commitHash, note)
+	return err
+}
+
+// addConversationNote adds or merges a note like AddConversationNote, and
+// reports whether it wrote anything
+func (nm *NotesManager) addConversationNote(ctx context.Context, commitHash string, note ConversationNote) (bool, error) {
+	existing, err := nm.GetConversationNote(ctx, commitHash)
 	if err != nil {
-		return fmt.Errorf("failed to marshal note: %w", err)
+		// Including a note this cnotes can't read, which mustn't be written over
+		return false, fmt.Errorf("failed to read existing note: %w", err)
+	}
+	if existing != nil {
+		if !existing.Merge(note) {
+			// It already records everything in the note
+			return false, nil
+		}
+		note = *existing
 	}
 
-	// Use git notes add command with custom ref
-	_, err = nm.git.Execute(ctx, nm.workDir, "notes", "--ref", nm.notesRef, "add", "-m", string(noteData), commitHash)
+	noteData, err := nm.marshalNote(note)
+	if err != nil {
+		return false, err
+	}
+
+	if err := nm.writeNote(ctx, commitHash, noteData); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// writeNote writes a marshalled note to a commit, replacing any note it has.
+// The note goes to git on stdin, as one with a long conversation can be larger
+// than a single command-line argument may be.
+func (nm *NotesManager) writeNote(ctx context.Context, commitHash string, noteData []byte) error {
+	_, err := nm.git.ExecuteWithInput(ctx, nm.workDir, noteData, "notes", "--ref", nm.notesRef, "add", "-f", "-F", "-", commitHash)
 	if err != nil {
 		return fmt.Errorf("failed to add git note: %w", err)
 	}
@@ -84,53 +418,176 @@ func (nm *NotesManager) AddConversationNote(ctx context.Context, commitHash stri
 	return nil
 }
 
+// MoveConversationNote writes a note to a new commit, replacing any note it
+// already has, and removes the note from the old commit
+func (nm *NotesManager) MoveConversationNote(ctx context.Context, fromCommit, toCommit string, note ConversationNote) error {
+	noteData, err := nm.marshalNote(note)
+	if err != nil {
+		return err
+	}
+
+	if err := nm.writeNote(ctx, toCommit, noteData); err != nil {
+		return err
+	}
+
+	if fromCommit == "" || fromCommit == toCommit {
+		return nil
+	}
+
+	_, err = nm.git.Execute(ctx, nm.workDir, "notes", "--ref", nm.notesRef, "remove", "--ignore-missing", fromCommit)
+	if err != nil {
+		return fmt.Errorf("failed to remove git note: %w", err)
+	}
+
+	return nil
+}
+
 // GetConversationNote retrieves a conversation note for a specific commit
 func (nm *NotesManager) GetConversationNote(ctx context.Context, commitHash string) (*ConversationNote, error) {
 	output, err := nm.git.Execute(ctx, nm.workDir, "notes", "--ref", nm.notesRef, "show", commitHash)
-	if err != nil {
-		// Note might not exist, which is normal
+	if errors.Is(err, ErrNoNote) {
+		// Most commits have no note
 		return nil, nil
 	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read note: %w", err)
+	}
 
-	var note ConversationNote
-	if err := json.Unmarshal(output, &note); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal note: %w", err)
+	note, _, err := decodeNote(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode note for %s: %w", commitHash, err)
 	}
 
 	return &note, nil
 }
 
 // HasConversationNote checks if a commit has a conversation note
-func (nm *NotesManager) HasConversationNote(ctx context.Context, commitHash string) bool {
-	note, _ := nm.GetConversationNote(ctx, commitHash)
-	return note != nil
+func (nm *NotesManager) HasConversationNote(ctx context.Context, commitHash string) (bool, error) {
+	note, err := nm.GetConversationNote(ctx, commitHash)
+	if errors.Is(err, ErrNewerSchema) || errors.Is(err, errInvalidNote) {
+		// A note this cnotes can't read is still a note, not to be written over
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return note != nil, nil
+}
+
+// GitCommonDir returns the absolute path of the git directory shared by all worktrees
+func (nm *NotesManager) GitCommonDir(ctx context.Context) (string, error) {
+	output, err := nm.git.Execute(ctx, nm.workDir, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return "", fmt.Errorf("failed to find git directory: %w", err)
+	}
+
+	dir := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(nm.workDir, dir)
+	}
+
+	return filepath.Abs(dir)
+}
+
+// WorktreeRoot returns the top-level directory of the current worktree
+func (nm *NotesManager) WorktreeRoot(ctx context.Context) (string, error) {
+	output, err := nm.git.Execute(ctx, nm.workDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", fmt.Errorf("failed to find worktree: %w", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
 }
 
-// ExtractCommitHashFromOutput attempts to extract commit hash from git command output
+// CommitFiles returns the paths, relative to the top of the worktree, that a
+// commit changed. A merge commit's paths are those changed against any parent.
+func (nm *NotesManager) CommitFiles(ctx context.Context, commitHash string) ([]string, error) {
+	output, err := nm.git.Execute(ctx, nm.workDir, "diff-tree", "--no-commit-id", "--name-only", "-r", "-m", "--root", commitHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files changed by %s: %w", commitHash, err)
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !containsString(files, line) {
+			files = append(files, line)
+		}
+	}
+
+	return files, nil
+}
+
+// ResolveCommit resolves a revision to the full object ID of the commit it names
+func (nm *NotesManager) ResolveCommit(ctx context.Context, rev string) (string, error) {
+	output, err := nm.git.Execute(ctx, nm.workDir, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", rev, err)
+	}
+
+	hash := strings.TrimSpace(string(output))
+	if !isHexHash(hash) {
+		return "", fmt.Errorf("unexpected rev-parse output for %s: %q", rev, hash)
+	}
+
+	return hash, nil
+}
+
+// ErrNoNewCommit is returned by IdentifyCommit when HEAD didn't move, such as
+// when a hook rejected the commit or there was nothing to commit
+var ErrNoNewCommit = errors.New("no new commit")
+
+// IdentifyCommit determines which commit a tool call created. headBefore is the
+// full HEAD object ID recorded before the call, or empty if unknown. If HEAD
+// moved, the new HEAD is the commit, and if it didn't there is none. Only if
+// HEAD is unknown either way is the porcelain output of git commit parsed as
+// a fallback. The result is always a full object ID.
+func (nm *NotesManager) IdentifyCommit(ctx context.Context, headBefore, output string) (string, error) {
+	if headBefore != "" {
+		headAfter, err := nm.ResolveCommit(ctx, "HEAD")
+		if err == nil {
+			if headAfter == headBefore {
+				// Any hash in the output, e.g. from a git log run after the
+				// commit, names an older commit
+				return "", ErrNoNewCommit
+			}
+			return headAfter, nil
+		}
+	}
+
+	abbrev := ExtractCommitHashFromOutput(output)
+	if abbrev == "" {
+		return "", fmt.Errorf("could not identify commit: HEAD did not move and no hash in output")
+	}
+
+	return nm.ResolveCommit(ctx, abbrev)
+}
+
+// ExtractCommitHashFromOutput attempts to extract commit hash from git command output.
+// The result may be abbreviated; use ResolveCommit to get the full object ID.
 func ExtractCommitHashFromOutput(output string) string {
 	lines := strings.Split(output, "\n")
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 
-		// Look for commit hash patterns
-		if strings.HasPrefix(line, "[") && strings.Contains(line, "]") {
-			// Format: [main abc1234] commit message
-			parts := strings.Split(line, "]")
-			if len(parts) > 0 {
-				beforeBracket := strings.TrimSpace(strings.TrimPrefix(parts[0], "["))
-				hashParts := strings.Split(beforeBracket, " ")
-				if len(hashParts) > 1 {
-					// Return the hash part (second element)
-					return hashParts[1]
+		// Look for the git commit summary line. The hash is always the last
+		// word in the brackets:
+		//   [main abc1234] commit message
+		//   [detached HEAD abc1234] commit message
+		//   [main (root-commit) abc1234] commit message
+		if strings.HasPrefix(line, "[") {
+			if end := strings.Index(line, "]"); end > 0 {
+				fields := strings.Fields(line[1:end])
+				if len(fields) > 1 && isHexHash(fields[len(fields)-1]) {
+					return fields[len(fields)-1]
 				}
 			}
 		}
 
 		// Alternative format: commit abc1234567890abcdef
 		if strings.HasPrefix(line, "commit ") {
-			parts := strings.Split(line, " ")
-			if len(parts) >= 2 {
-				return parts[1]
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && isHexHash(fields[1]) {
+				return fields[1]
 			}
 		}
 	}
@@ -138,6 +595,19 @@ func ExtractCommitHashFromOutput(output string) string {
 	return ""
 }
 
+// isHexHash reports whether s looks like a full or abbreviated object ID
+func isHexHash(s string) bool {
+	if len(s) < 4 || len(s) > 64 {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
 // IsGitCommitCommand checks if a bash command contains a git commit
 func IsGitCommitCommand(command string) bool {
 	command = strings.TrimSpace(command)
@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os/exec"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/imjasonh/cnotes/internal/redact"
 )
 
 // MockGitExecutor is a mock implementation of GitExecutor for testing
@@ -16,6 +19,13 @@ type MockGitExecutor struct {
 	responses map[string]mockResponse
 	// Record of executed commands
 	executed []executedCommand
+	// Objects ReadObjects reads, by revision
+	objects map[string]mockObject
+}
+
+type mockObject struct {
+	objectType string
+	data       []byte
 }
 
 type mockResponse struct {
@@ -24,14 +34,16 @@ type mockResponse struct {
 }
 
 type executedCommand struct {
-	dir  string
-	args []string
+	dir   string
+	args  []string
+	input []byte // What ExecuteWithInput passed on stdin
 }
 
 func NewMockGitExecutor() *MockGitExecutor {
 	return &MockGitExecutor{
 		responses: make(map[string]mockResponse),
 		executed:  []executedCommand{},
+		objects:   make(map[string]mockObject),
 	}
 }
 
@@ -54,6 +66,51 @@ func (m *MockGitExecutor) SetResponse(args []string, output []byte, err error) {
 	m.responses[key] = mockResponse{output: output, err: err}
 }
 
+func (m *MockGitExecutor) ExecuteWithInput(ctx context.Context, dir string, input []byte, args ...string) ([]byte, error) {
+	m.executed = append(m.executed, executedCommand{dir: dir, args: args, input: input})
+
+	// A response for the input too takes precedence over one for any input
+	if resp, ok := m.responses[fmt.Sprintf("%v <- %s", args, input)]; ok {
+		return resp.output, resp.err
+	}
+	if resp, ok := m.responses[fmt.Sprintf("%v", args)]; ok {
+		return resp.output, resp.err
+	}
+
+	return nil, fmt.Errorf("command not found: %v with input %q", args, input)
+}
+
+// SetInputResponse sets the response to a command given exactly this input
+func (m *MockGitExecutor) SetInputResponse(args []string, input []byte, output []byte, err error) {
+	key := fmt.Sprintf("%v <- %s", args, input)
+	m.responses[key] = mockResponse{output: output, err: err}
+}
+
+func (m *MockGitExecutor) ReadObjects(ctx context.Context, dir string) (ObjectReader, error) {
+	m.executed = append(m.executed, executedCommand{dir: dir, args: []string{"cat-file", "--batch"}})
+	return mockObjectReader{m}, nil
+}
+
+func (m *MockGitExecutor) SetObject(rev, objectType string, data []byte) {
+	m.objects[rev] = mockObject{objectType: objectType, data: data}
+}
+
+type mockObjectReader struct {
+	m *MockGitExecutor
+}
+
+func (r mockObjectReader) ReadObject(rev string) (string, []byte, error) {
+	object, ok := r.m.objects[rev]
+	if !ok {
+		return "", nil, ErrUnknownRevision
+	}
+	return object.objectType, object.data, nil
+}
+
+func (r mockObjectReader) Close() error {
+	return nil
+}
+
 func (m *MockGitExecutor) GetExecutedCommands() []executedCommand {
 	return m.executed
 }
@@ -110,20 +167,24 @@ func TestAddConversationNote(t *testing.T) {
 	mockGit := NewMockGitExecutor()
 	nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
 
-	testNote := ConversationNote{
+	var testNote ConversationNote
+	testNote.AddEntry(SessionEntry{
 		SessionID:           "test-session-123",
 		Timestamp:           time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
 		ConversationExcerpt: "User: Test this\nAssistant: Testing...",
 		ToolsUsed:           []string{"Bash", "Read"},
 		CommitContext:       "Fixed bug in feature X",
-		ClaudeVersion:       "claude-3.5-sonnet-latest",
 		LastEventTime:       time.Date(2023, 1, 1, 12, 30, 0, 0, time.UTC),
-	}
+	})
+	testNote.SchemaVersion = CurrentSchemaVersion
+
+	// The commit has no note yet
+	mockGit.SetResponse([]string{"notes", "--ref", "claude-conversations", "show", "abc123"}, nil, ErrNoNote)
 
 	// Set up mock response for successful add
 	expectedJSON, _ := json.MarshalIndent(testNote, "", "  ")
-	mockGit.SetResponse(
-		[]string{"notes", "--ref", "claude-conversations", "add", "-m", string(expectedJSON), "abc123"},
+	mockGit.SetInputResponse(
+		[]string{"notes", "--ref", "claude-conversations", "add", "-f", "-F", "-", "abc123"}, expectedJSON,
 		[]byte{},
 		nil,
 	)
@@ -134,22 +195,95 @@ func TestAddConversationNote(t *testing.T) {
 		t.Fatalf("failed to add conversation note: %v", err)
 	}
 
-	// Verify the command was executed
+	// Verify the commands were executed
 	executed := mockGit.GetExecutedCommands()
-	if len(executed) != 1 {
-		t.Fatalf("expected 1 command, got %d", len(executed))
+	if len(executed) != 2 {
+		t.Fatalf("expected 2 commands, got %d", len(executed))
 	}
 
-	if executed[0].dir != "/test/dir" {
-		t.Errorf("expected dir /test/dir, got %s", executed[0].dir)
+	if executed[1].dir != "/test/dir" {
+		t.Errorf("expected dir /test/dir, got %s", executed[1].dir)
 	}
 
-	// Should be: notes, --ref, claude-conversations, add, -m, <json>, abc123
-	if len(executed[0].args) != 7 {
-		t.Errorf("expected 7 args, got %d: %v", len(executed[0].args), executed[0].args)
+	// Should be: notes, --ref, claude-conversations, add, -f, -F, -, abc123,
+	// with the note on stdin
+	if len(executed[1].args) != 8 {
+		t.Errorf("expected 8 args, got %d: %v", len(executed[1].args), executed[1].args)
+	}
+	if string(executed[1].input) != string(expectedJSON) {
+		t.Errorf("expected the note on stdin, got %s", executed[1].input)
 	}
 }
 
+func TestAddConversationNoteMerges(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Another session already gave the commit a note
+	var existing ConversationNote
+	existing.AddEntry(SessionEntry{
+		SessionID: "session-1",
+		Timestamp: at,
+		Events:    []Event{{ID: "a", Role: RoleUser, Text: "Fix the bug", Timestamp: at}},
+		ToolsUsed: []string{"Bash"},
+	})
+
+	var second ConversationNote
+	second.AddEntry(SessionEntry{
+		SessionID: "session-2",
+		Timestamp: at.Add(time.Hour),
+		Events:    []Event{{ID: "b", Role: RoleUser, Text: "Update the docs", Timestamp: at.Add(time.Hour)}},
+		ToolsUsed: []string{"Bash", "Edit"},
+	})
+
+	t.Run("another session", func(t *testing.T) {
+		mockGit := NewMockGitExecutor()
+		nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+		existingJSON, _ := nm.marshalNote(existing)
+		mockGit.SetResponse([]string{"notes", "--ref", "claude-conversations", "show", "abc123"}, existingJSON, nil)
+
+		merged := existing
+		merged.Entries = append([]SessionEntry(nil), existing.Entries...)
+		merged.Merge(second)
+		mergedJSON, _ := nm.marshalNote(merged)
+		mockGit.SetInputResponse([]string{"notes", "--ref", "claude-conversations", "add", "-f", "-F", "-", "abc123"}, mergedJSON, nil, nil)
+
+		if err := nm.AddConversationNote(ctx, "abc123", second); err != nil {
+			t.Fatalf("failed to add conversation note: %v", err)
+		}
+		if len(merged.Entries) != 2 || merged.SessionID != "session-1" || strings.Join(merged.Sessions, ",") != "session-1,session-2" {
+			t.Errorf("expected an entry for each session, got %+v", merged)
+		}
+		if strings.Join(merged.ToolsUsed, ",") != "Bash,Edit" || !merged.Timestamp.Equal(at) {
+			t.Errorf("expected the summary to cover both entries, got %+v", merged)
+		}
+	})
+
+	t.Run("already recorded", func(t *testing.T) {
+		mockGit := NewMockGitExecutor()
+		nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+		existingJSON, _ := nm.marshalNote(existing)
+		mockGit.SetResponse([]string{"notes", "--ref", "claude-conversations", "show", "abc123"}, existingJSON, nil)
+
+		if err := nm.AddConversationNote(ctx, "abc123", existing); err != nil {
+			t.Fatalf("failed to add conversation note: %v", err)
+		}
+		if executed := mockGit.GetExecutedCommands(); len(executed) != 1 {
+			t.Errorf("expected nothing written, got %v", executed)
+		}
+	})
+
+	t.Run("newer note", func(t *testing.T) {
+		mockGit := NewMockGitExecutor()
+		nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+		mockGit.SetResponse([]string{"notes", "--ref", "claude-conversations", "show", "abc123"}, []byte(`{"schema_version": 99}`), nil)
+
+		if err := nm.AddConversationNote(ctx, "abc123", second); !errors.Is(err, ErrNewerSchema) {
+			t.Errorf("expected ErrNewerSchema, got %v", err)
+		}
+	})
+}
+
 func TestAddConversationNoteError(t *testing.T) {
 	ctx := context.Background()
 	mockGit := NewMockGitExecutor()
@@ -159,8 +293,9 @@ func TestAddConversationNoteError(t *testing.T) {
 		SessionID: "test-session",
 		Timestamp: time.Now(),
 	}
+	mockGit.SetResponse([]string{"notes", "--ref", "claude-conversations", "show", "abc123"}, nil, ErrNoNote)
 
-	// We don't set up a specific response, so it will use the default error
+	// We don't set up a response for the add, so it will use the default error
 	// This simulates a git command failure
 
 	// Try to add the note
@@ -180,14 +315,15 @@ func TestGetConversationNote(t *testing.T) {
 	nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
 
 	// Create expected note
-	expectedNote := ConversationNote{
+	var expectedNote ConversationNote
+	expectedNote.AddEntry(SessionEntry{
 		SessionID:           "test-session-123",
 		Timestamp:           time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
 		ConversationExcerpt: "User: Test this\nAssistant: Testing...",
 		ToolsUsed:           []string{"Bash", "Read"},
 		CommitContext:       "Fixed bug in feature X",
-		ClaudeVersion:       "claude-3.5-sonnet-latest",
-	}
+	})
+	expectedNote.SchemaVersion = CurrentSchemaVersion
 
 	noteJSON, _ := json.Marshal(expectedNote)
 
@@ -213,8 +349,8 @@ func TestGetConversationNote(t *testing.T) {
 		t.Errorf("expected SessionID %s, got %s", expectedNote.SessionID, note.SessionID)
 	}
 
-	if note.ConversationExcerpt != expectedNote.ConversationExcerpt {
-		t.Errorf("expected ConversationExcerpt %s, got %s", expectedNote.ConversationExcerpt, note.ConversationExcerpt)
+	if len(note.Entries) != 1 || note.Entries[0].ConversationExcerpt != expectedNote.Entries[0].ConversationExcerpt {
+		t.Errorf("expected ConversationExcerpt %s, got %+v", expectedNote.Entries[0].ConversationExcerpt, note.Entries)
 	}
 }
 
@@ -227,7 +363,7 @@ func TestGetConversationNoteNotFound(t *testing.T) {
 	mockGit.SetResponse(
 		[]string{"notes", "--ref", "claude-conversations", "show", "nonexistent"},
 		nil,
-		errors.New("no note found"),
+		ErrNoNote,
 	)
 
 	// Try to get non-existent note
@@ -277,19 +413,252 @@ func TestHasConversationNote(t *testing.T) {
 		nil,
 	)
 
-	if !nm.HasConversationNote(ctx, "abc123") {
-		t.Error("expected to have note")
+	if exists, err := nm.HasConversationNote(ctx, "abc123"); err != nil || !exists {
+		t.Errorf("expected to have note, got %t, %v", exists, err)
 	}
 
 	// Test with non-existent note
 	mockGit.SetResponse(
 		[]string{"notes", "--ref", "claude-conversations", "show", "def456"},
 		nil,
-		errors.New("no note"),
+		ErrNoNote,
+	)
+
+	if exists, err := nm.HasConversationNote(ctx, "def456"); err != nil || exists {
+		t.Errorf("expected not to have note, got %t, %v", exists, err)
+	}
+
+	// Test with git failing
+	mockGit.SetResponse(
+		[]string{"notes", "--ref", "claude-conversations", "show", "ghi789"},
+		nil,
+		&GitError{Stderr: "fatal: bad object", Err: ErrGitFailed, Cause: errors.New("exit status 128")},
 	)
 
-	if nm.HasConversationNote(ctx, "def456") {
-		t.Error("expected not to have note")
+	if _, err := nm.HasConversationNote(ctx, "ghi789"); !errors.Is(err, ErrGitFailed) {
+		t.Errorf("expected the git failure, got %v", err)
+	}
+}
+
+func TestAddAmendment(t *testing.T) {
+	original := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	var note ConversationNote
+	note.AddEntry(SessionEntry{
+		SessionID:           "session-1",
+		Sessions:            []string{"session-1"},
+		ConversationExcerpt: "User: Fix the bug",
+		ToolsUsed:           []string{"Bash", "Edit"},
+		LastEventTime:       original,
+	})
+
+	note.AddAmendment(Amendment{
+		AmendedCommit: "abc123",
+		SessionEntry: SessionEntry{
+			SessionID:           "session-1",
+			Sessions:            []string{"session-1", "session-2"},
+			ConversationExcerpt: "User: Also update the docs",
+			ToolsUsed:           []string{"Bash", "Write"},
+			LastEventTime:       original.Add(time.Hour),
+		},
+	})
+
+	if note.Entries[0].ConversationExcerpt != "User: Fix the bug" {
+		t.Errorf("original excerpt should be kept, got %q", note.Entries[0].ConversationExcerpt)
+	}
+
+	if len(note.Amendments) != 1 || note.Amendments[0].AmendedCommit != "abc123" {
+		t.Fatalf("unexpected amendments: %+v", note.Amendments)
+	}
+
+	if strings.Join(note.ToolsUsed, ",") != "Bash,Edit,Write" {
+		t.Errorf("expected merged tools, got %v", note.ToolsUsed)
+	}
+
+	if strings.Join(note.Sessions, ",") != "session-1,session-2" {
+		t.Errorf("expected merged sessions, got %v", note.Sessions)
+	}
+
+	if !note.LastEventTime.Equal(original.Add(time.Hour)) {
+		t.Errorf("expected LastEventTime to advance, got %v", note.LastEventTime)
+	}
+}
+
+func TestAddLargeConversationNote(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	git := &RealGitExecutor{}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.name", "Test"},
+		{"config", "user.email", "test@example.com"},
+		{"commit", "-q", "--allow-empty", "-m", "first"},
+	} {
+		if _, err := git.Execute(ctx, dir, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+
+	// Larger than Linux allows a single command-line argument to be (128 KiB)
+	excerpt := strings.Repeat("A long conversation. ", 10000)
+	var note ConversationNote
+	note.AddEntry(SessionEntry{SessionID: "session-1", ConversationExcerpt: excerpt})
+
+	nm := NewNotesManager(dir)
+	if err := nm.AddConversationNote(ctx, "HEAD", note); err != nil {
+		t.Fatalf("failed to add a large note: %v", err)
+	}
+	stored, err := nm.GetConversationNote(ctx, "HEAD")
+	if err != nil || stored == nil {
+		t.Fatalf("failed to read the note back: %v", err)
+	}
+	if stored.Entries[0].ConversationExcerpt != excerpt {
+		t.Errorf("expected the whole conversation stored, got %d bytes of %d", len(stored.Entries[0].ConversationExcerpt), len(excerpt))
+	}
+}
+
+func TestMoveConversationNote(t *testing.T) {
+	ctx := context.Background()
+	mockGit := NewMockGitExecutor()
+	nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+
+	note := ConversationNote{SchemaVersion: CurrentSchemaVersion, SessionID: "session-1"}
+	noteJSON, _ := json.MarshalIndent(note, "", "  ")
+	mockGit.SetInputResponse([]string{"notes", "--ref", "claude-conversations", "add", "-f", "-F", "-", "new123"}, noteJSON, nil, nil)
+	mockGit.SetResponse([]string{"notes", "--ref", "claude-conversations", "remove", "--ignore-missing", "old123"}, nil, nil)
+
+	if err := nm.MoveConversationNote(ctx, "old123", "new123", note); err != nil {
+		t.Fatalf("failed to move note: %v", err)
+	}
+
+	executed := mockGit.GetExecutedCommands()
+	if len(executed) != 2 {
+		t.Fatalf("expected 2 commands, got %d", len(executed))
+	}
+
+	if executed[1].args[3] != "remove" {
+		t.Errorf("expected old note to be removed, got %v", executed[1].args)
+	}
+}
+
+func TestModelUsage(t *testing.T) {
+	usage := SortModelUsage([]ModelUsage{
+		{Model: "claude-3-5-haiku-20241022", Messages: 2, InputTokens: 100, EstimatedCostUSD: 0.25},
+		{Model: "claude-opus-4-1-20250805", Messages: 5, OutputTokens: 300, EstimatedCostUSD: 1.5},
+	})
+
+	if len(usage) != 2 || usage[0].Model != "claude-opus-4-1-20250805" {
+		t.Fatalf("expected most used model first, got %v", usage)
+	}
+
+	if PrimaryModel(usage) != "claude-opus-4-1-20250805" {
+		t.Errorf("unexpected primary model %s", PrimaryModel(usage))
+	}
+
+	if PrimaryModel(nil) != "" {
+		t.Error("expected no primary model without usage")
+	}
+
+	merged := MergeModelUsage(usage, []ModelUsage{{Model: "claude-3-5-haiku-20241022", Messages: 4, InputTokens: 50, CacheReadTokens: 1000, EstimatedCostUSD: 0.5}})
+	if merged[0].Model != "claude-3-5-haiku-20241022" || merged[0].Messages != 6 {
+		t.Errorf("unexpected merged usage: %v", merged)
+	}
+
+	if merged[0].InputTokens != 150 || merged[0].CacheReadTokens != 1000 || merged[0].EstimatedCostUSD != 0.75 {
+		t.Errorf("expected tokens and cost to be summed, got %+v", merged[0])
+	}
+
+	note := ConversationNote{Models: merged}
+	total := note.TotalUsage()
+	if total.Messages != 11 || total.TotalTokens() != 1450 || total.EstimatedCostUSD != 2.25 {
+		t.Errorf("unexpected total usage: %+v", total)
+	}
+}
+
+func TestGitCommonDir(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("relative git dir", func(t *testing.T) {
+		mockGit := NewMockGitExecutor()
+		nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+		mockGit.SetResponse([]string{"rev-parse", "--git-common-dir"}, []byte(".git\n"), nil)
+
+		dir, err := nm.GitCommonDir(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if dir != "/test/dir/.git" {
+			t.Errorf("expected /test/dir/.git, got %s", dir)
+		}
+	})
+
+	t.Run("absolute git dir", func(t *testing.T) {
+		mockGit := NewMockGitExecutor()
+		nm := NewNotesManagerWithExecutor("/test/dir/worktree", mockGit)
+		mockGit.SetResponse([]string{"rev-parse", "--git-common-dir"}, []byte("/test/dir/.git\n"), nil)
+
+		dir, err := nm.GitCommonDir(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if dir != "/test/dir/.git" {
+			t.Errorf("expected /test/dir/.git, got %s", dir)
+		}
+	})
+
+	t.Run("not a repository", func(t *testing.T) {
+		mockGit := NewMockGitExecutor()
+		nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+
+		if _, err := nm.GitCommonDir(ctx); err == nil {
+			t.Error("expected error outside a repository")
+		}
+	})
+}
+
+func TestWorktreeRoot(t *testing.T) {
+	ctx := context.Background()
+	mockGit := NewMockGitExecutor()
+	nm := NewNotesManagerWithExecutor("/test/dir/sub", mockGit)
+
+	if _, err := nm.WorktreeRoot(ctx); err == nil {
+		t.Error("expected error outside a repository")
+	}
+
+	mockGit.SetResponse([]string{"rev-parse", "--show-toplevel"}, []byte("/test/dir\n"), nil)
+	root, err := nm.WorktreeRoot(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if root != "/test/dir" {
+		t.Errorf("expected /test/dir, got %s", root)
+	}
+}
+
+func TestCommitFiles(t *testing.T) {
+	ctx := context.Background()
+	mockGit := NewMockGitExecutor()
+	nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+
+	// A merge lists files once per parent they differ from
+	mockGit.SetResponse([]string{"diff-tree", "--no-commit-id", "--name-only", "-r", "-m", "--root", "abc123"},
+		[]byte("README.md\nmain.go\n\nmain.go\n"), nil)
+
+	files, err := nm.CommitFiles(ctx, "abc123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(files, ",") != "README.md,main.go" {
+		t.Errorf("unexpected files %v", files)
+	}
+
+	if _, err := nm.CommitFiles(ctx, "def456"); err == nil {
+		t.Error("expected error for an unknown commit")
 	}
 }
 
@@ -316,8 +685,28 @@ func TestExtractCommitHashFromOutput(t *testing.T) {
 		},
 		{
 			name:     "Multiple lines with commit",
-			output:   "Some output\n[main xyz789] Commit message\nMore output",
-			expected: "xyz789",
+			output:   "Some output\n[main 789abcd] Commit message\nMore output",
+			expected: "789abcd",
+		},
+		{
+			name:     "Detached HEAD",
+			output:   "[detached HEAD abc1234] Commit message",
+			expected: "abc1234",
+		},
+		{
+			name:     "Root commit",
+			output:   "[main (root-commit) abc1234] Initial commit",
+			expected: "abc1234",
+		},
+		{
+			name:     "Bracketed non-commit line",
+			output:   "[WARNING] something happened\n[main def5678] Real commit",
+			expected: "def5678",
+		},
+		{
+			name:     "Empty output",
+			output:   "",
+			expected: "",
 		},
 		{
 			name:     "No commit hash",
@@ -341,6 +730,124 @@ func TestExtractCommitHashFromOutput(t *testing.T) {
 	}
 }
 
+func TestResolveCommit(t *testing.T) {
+	ctx := context.Background()
+	fullHash := "abc1234567890abcdef1234567890abcdef12345"
+
+	t.Run("resolves to full object ID", func(t *testing.T) {
+		mockGit := NewMockGitExecutor()
+		nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+		mockGit.SetResponse([]string{"rev-parse", "--verify", "--quiet", "abc1234^{commit}"}, []byte(fullHash+"\n"), nil)
+
+		hash, err := nm.ResolveCommit(ctx, "abc1234")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if hash != fullHash {
+			t.Errorf("expected %s, got %s", fullHash, hash)
+		}
+	})
+
+	t.Run("unknown revision", func(t *testing.T) {
+		mockGit := NewMockGitExecutor()
+		nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+
+		if _, err := nm.ResolveCommit(ctx, "nonexistent"); err == nil {
+			t.Error("expected error for unknown revision")
+		}
+	})
+}
+
+func TestIdentifyCommit(t *testing.T) {
+	ctx := context.Background()
+	before := "1111111111111111111111111111111111111111"
+	after := "2222222222222222222222222222222222222222"
+
+	tests := []struct {
+		name       string
+		headBefore string
+		headAfter  string
+		output     string
+		abbrevs    map[string]string
+		expected   string
+		wantErr    bool
+	}{
+		{
+			name:       "HEAD moved with quiet commit",
+			headBefore: before,
+			headAfter:  after,
+			output:     "",
+			expected:   after,
+		},
+		{
+			name:       "HEAD moved on detached HEAD",
+			headBefore: before,
+			headAfter:  after,
+			output:     "[detached HEAD 2222222] message",
+			expected:   after,
+		},
+		{
+			name:      "No marker falls back to output",
+			headAfter: after,
+			output:    "[main (root-commit) 2222222] message",
+			abbrevs:   map[string]string{"2222222": after},
+			expected:  after,
+		},
+		{
+			name:       "HEAD did not move and no output",
+			headBefore: before,
+			headAfter:  before,
+			output:     "nothing to commit, working tree clean",
+			wantErr:    true,
+		},
+		{
+			name:       "HEAD did not move and output names a commit",
+			headBefore: before,
+			headAfter:  before,
+			output:     "commit 2222222222222222222222222222222222222222\nAuthor: Test <test@example.com>",
+			abbrevs:    map[string]string{after: after},
+			wantErr:    true,
+		},
+		{
+			name:    "No marker and no output",
+			output:  "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGit := NewMockGitExecutor()
+			nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+			if tt.headAfter != "" {
+				mockGit.SetResponse([]string{"rev-parse", "--verify", "--quiet", "HEAD^{commit}"}, []byte(tt.headAfter+"\n"), nil)
+			}
+			for abbrev, full := range tt.abbrevs {
+				mockGit.SetResponse([]string{"rev-parse", "--verify", "--quiet", abbrev + "^{commit}"}, []byte(full+"\n"), nil)
+			}
+
+			hash, err := nm.IdentifyCommit(ctx, tt.headBefore, tt.output)
+			if tt.wantErr {
+				if tt.headBefore != "" && tt.headAfter == tt.headBefore && !errors.Is(err, ErrNoNewCommit) {
+					t.Errorf("expected ErrNoNewCommit, got %v", err)
+				}
+				if err == nil {
+					t.Errorf("expected error, got %s", hash)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if hash != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, hash)
+			}
+		})
+	}
+}
+
 func TestIsGitCommitCommand(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -403,3 +910,69 @@ func TestIsGitCommitCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestNoteRedaction(t *testing.T) {
+	ctx := context.Background()
+	secrets := []string{"hunter2", "s3cr3t", "tok-123", "pw-amend", "sub-secret"}
+
+	note := ConversationNote{
+		SessionID: "session-1",
+		Entries: []SessionEntry{{
+			SessionID:           "session-1",
+			ConversationExcerpt: "👤 User: my password is \"hunter2\"",
+			Events:              []Event{{Role: RoleUser, Text: "my password is \"hunter2\""}},
+			CommitContext:       `Git command: git commit -m "rotate password: s3cr3t"`,
+			Subagents: []Subagent{{
+				Prompt:              "Use token=tok-123",
+				ConversationExcerpt: "Tool (Bash): export PASSWORD=sub-secret",
+				Events:              []Event{{Role: RoleTool, Tool: "Bash", Input: "export PASSWORD=sub-secret"}},
+			}},
+		}},
+		Amendments: []Amendment{{SessionEntry: SessionEntry{
+			ConversationExcerpt: "👤 User: the password: pw-amend",
+			Events:              []Event{{Role: RoleUser, Text: "the password: pw-amend"}},
+			Subagents:           []Subagent{{Result: "password: sub-secret"}},
+		}}},
+	}
+
+	for name, write := range map[string]func(nm *NotesManager) error{
+		"add":  func(nm *NotesManager) error { return nm.AddConversationNote(ctx, "abc123", note) },
+		"move": func(nm *NotesManager) error { return nm.MoveConversationNote(ctx, "", "abc123", note) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockGit := NewMockGitExecutor()
+			nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+			nm.SetRedactor(redact.New(redact.KeywordRules([]string{"password", "token"}), nil))
+			mockGit.SetResponse([]string{"notes", "--ref", "claude-conversations", "show", "abc123"}, nil, ErrNoNote)
+
+			// The mock fails the command, but records what would have been written
+			_ = write(nm)
+
+			executed := mockGit.GetExecutedCommands()
+			if len(executed) == 0 || executed[len(executed)-1].args[3] != "add" {
+				t.Fatal("expected the note to be written")
+			}
+			last := executed[len(executed)-1]
+			written := strings.Join(last.args, " ") + "\n" + string(last.input)
+			for _, secret := range secrets {
+				if strings.Contains(written, secret) {
+					t.Errorf("secret %q reached git: %s", secret, written)
+				}
+			}
+			if !strings.Contains(written, redact.Placeholder) {
+				t.Errorf("expected redaction placeholders, got %s", written)
+			}
+			if !strings.Contains(written, `"keyword:password": 8`) || !strings.Contains(written, `"keyword:token": 1`) {
+				t.Errorf("expected the rules that fired to be recorded, got %s", written)
+			}
+
+			// The caller's note is left alone
+			entry := note.Entries[0]
+			if entry.Subagents[0].Prompt != "Use token=tok-123" || note.Amendments[0].Subagents[0].Result != "password: sub-secret" ||
+				entry.Events[0].Text != "my password is \"hunter2\"" || note.Amendments[0].Events[0].Text != "the password: pw-amend" ||
+				entry.Subagents[0].Events[0].Input != "export PASSWORD=sub-secret" {
+				t.Error("redaction modified the caller's note")
+			}
+		})
+	}
+}
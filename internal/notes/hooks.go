@@ -0,0 +1,164 @@
+package notes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hookMarker tags the lines cnotes adds to git hook scripts so they can be
+// found again on uninstall without touching anything else in the script
+const hookMarker = "# added by cnotes"
+
+// PostRewriteHookPath returns the path of the repository's post-rewrite hook,
+// honouring core.hooksPath
+func (nm *NotesManager) PostRewriteHookPath(ctx context.Context) (string, error) {
+	output, err := nm.git.Execute(ctx, nm.workDir, "rev-parse", "--git-path", "hooks/post-rewrite")
+	if err != nil {
+		return "", fmt.Errorf("failed to find git hooks directory: %w", err)
+	}
+
+	path := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(nm.workDir, path)
+	}
+
+	return path, nil
+}
+
+// InstallPostRewriteHook makes git's post-rewrite hook call
+// "cnotes git-hook post-rewrite". An existing hook script is kept and the
+// call is appended to it, unless the call wouldn't run there, in which case
+// the hook is left alone and the error says what to add to it by hand.
+func (nm *NotesManager) InstallPostRewriteHook(ctx context.Context, binaryPath string) error {
+	path, err := nm.PostRewriteHookPath(ctx)
+	if err != nil {
+		return err
+	}
+
+	line := fmt.Sprintf("%q git-hook post-rewrite \"$@\" %s", binaryPath, hookMarker)
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read post-rewrite hook: %w", err)
+	}
+
+	var lines []string
+	if len(existing) == 0 {
+		lines = []string{"#!/bin/sh", line}
+	} else {
+		lines = removeHookLines(strings.Split(strings.TrimRight(string(existing), "\n"), "\n"))
+		if reason := unappendableReason(lines); reason != "" {
+			return fmt.Errorf("the post-rewrite hook %s %s, so cnotes can't add itself to it; have it run %s git-hook post-rewrite, passing on its arguments and stdin", path, reason, binaryPath)
+		}
+		lines = append(lines, line)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0755); err != nil {
+		return fmt.Errorf("failed to write post-rewrite hook: %w", err)
+	}
+
+	return nil
+}
+
+// UninstallPostRewriteHook removes the cnotes call from git's post-rewrite
+// hook, deleting the script if nothing else is left in it
+func (nm *NotesManager) UninstallPostRewriteHook(ctx context.Context) error {
+	path, err := nm.PostRewriteHookPath(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read post-rewrite hook: %w", err)
+	}
+
+	lines := removeHookLines(strings.Split(strings.TrimRight(string(existing), "\n"), "\n"))
+	if len(lines) == 0 || (len(lines) == 1 && strings.HasPrefix(lines[0], "#!")) {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove post-rewrite hook: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0755); err != nil {
+		return fmt.Errorf("failed to write post-rewrite hook: %w", err)
+	}
+
+	return nil
+}
+
+// unappendableReason tells why a shell line appended to a hook script
+// wouldn't run: the script isn't a shell script, or it execs or exits before
+// reaching the end. It returns "" if the line would run.
+func unappendableReason(lines []string) string {
+	// Without a shebang, git runs the hook with sh
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "#!") {
+		fields := strings.Fields(strings.TrimPrefix(lines[0], "#!"))
+		var interpreter string
+		for i, field := range fields {
+			// For #!/usr/bin/env bash, the interpreter is env's argument
+			if i == 0 && filepath.Base(field) == "env" || strings.HasPrefix(field, "-") {
+				continue
+			}
+			interpreter = filepath.Base(field)
+			break
+		}
+		switch interpreter {
+		case "sh", "bash", "dash", "ash", "ksh", "zsh":
+		default:
+			return fmt.Sprintf("is run by %s rather than a shell", interpreter)
+		}
+	}
+
+	// Only commands outside of any block, such as a final exec, are sure to
+	// end the script
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		switch {
+		case fields[0] == "exit":
+			return "calls exit"
+		case fields[0] == "exec" && !onlyRedirections(fields[1:]):
+			// exec with only redirections, like exec 1>&2, carries on
+			return "calls exec"
+		}
+	}
+
+	return ""
+}
+
+// onlyRedirections reports whether shell words are all redirections, such as
+// >&2 or 2>/dev/null
+func onlyRedirections(words []string) bool {
+	for _, word := range words {
+		redirect := strings.TrimLeft(word, "0123456789")
+		if !strings.HasPrefix(redirect, "<") && !strings.HasPrefix(redirect, ">") {
+			return false
+		}
+	}
+	return true
+}
+
+// removeHookLines drops the lines cnotes added to a hook script
+func removeHookLines(lines []string) []string {
+	var kept []string
+	for _, line := range lines {
+		if !strings.HasSuffix(line, hookMarker) {
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,125 @@
+package notes
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPostRewriteHook(t *testing.T) {
+	ctx := context.Background()
+	tempDir := t.TempDir()
+	hookPath := filepath.Join(tempDir, ".git", "hooks", "post-rewrite")
+
+	mockGit := NewMockGitExecutor()
+	nm := NewNotesManagerWithExecutor(tempDir, mockGit)
+	mockGit.SetResponse([]string{"rev-parse", "--git-path", "hooks/post-rewrite"}, []byte(".git/hooks/post-rewrite\n"), nil)
+
+	t.Run("install creates hook", func(t *testing.T) {
+		if err := nm.InstallPostRewriteHook(ctx, "/usr/bin/cnotes"); err != nil {
+			t.Fatalf("failed to install hook: %v", err)
+		}
+
+		data, err := os.ReadFile(hookPath)
+		if err != nil {
+			t.Fatalf("hook was not created: %v", err)
+		}
+
+		if !strings.HasPrefix(string(data), "#!/bin/sh\n") {
+			t.Errorf("expected shebang, got %q", data)
+		}
+
+		if !strings.Contains(string(data), `"/usr/bin/cnotes" git-hook post-rewrite "$@"`) {
+			t.Errorf("expected cnotes call, got %q", data)
+		}
+
+		info, _ := os.Stat(hookPath)
+		if info.Mode()&0111 == 0 {
+			t.Error("hook should be executable")
+		}
+	})
+
+	t.Run("reinstall does not duplicate", func(t *testing.T) {
+		if err := nm.InstallPostRewriteHook(ctx, "/new/path/cnotes"); err != nil {
+			t.Fatalf("failed to install hook: %v", err)
+		}
+
+		data, _ := os.ReadFile(hookPath)
+		if strings.Count(string(data), "git-hook post-rewrite") != 1 {
+			t.Errorf("expected a single cnotes call, got %q", data)
+		}
+	})
+
+	t.Run("uninstall removes hook created by cnotes", func(t *testing.T) {
+		if err := nm.UninstallPostRewriteHook(ctx); err != nil {
+			t.Fatalf("failed to uninstall hook: %v", err)
+		}
+
+		if _, err := os.Stat(hookPath); !os.IsNotExist(err) {
+			t.Error("expected hook to be removed")
+		}
+	})
+
+	t.Run("existing hook is preserved", func(t *testing.T) {
+		existing := "#!/bin/bash\necho rewritten\n"
+		if err := os.WriteFile(hookPath, []byte(existing), 0755); err != nil {
+			t.Fatalf("failed to write hook: %v", err)
+		}
+
+		if err := nm.InstallPostRewriteHook(ctx, "/usr/bin/cnotes"); err != nil {
+			t.Fatalf("failed to install hook: %v", err)
+		}
+
+		data, _ := os.ReadFile(hookPath)
+		if !strings.HasPrefix(string(data), existing) {
+			t.Errorf("existing hook was modified: %q", data)
+		}
+
+		if err := nm.UninstallPostRewriteHook(ctx); err != nil {
+			t.Fatalf("failed to uninstall hook: %v", err)
+		}
+
+		data, _ = os.ReadFile(hookPath)
+		if string(data) != existing {
+			t.Errorf("expected original hook after uninstall, got %q", data)
+		}
+	})
+
+	t.Run("hook the call wouldn't run in", func(t *testing.T) {
+		for name, existing := range map[string]string{
+			"python":       "#!/usr/bin/env python3\nprint('rewritten')\n",
+			"node":         "#!/usr/local/bin/node\nconsole.log('rewritten')\n",
+			"exec":         "#!/bin/sh\nexec /usr/local/bin/other-hook \"$@\"\n",
+			"exit":         "#!/bin/sh\necho rewritten\nexit 0\n",
+			"exec and env": "#!/usr/bin/env bash\nexec other-hook\n",
+		} {
+			if err := os.WriteFile(hookPath, []byte(existing), 0755); err != nil {
+				t.Fatalf("failed to write hook: %v", err)
+			}
+
+			err := nm.InstallPostRewriteHook(ctx, "/usr/bin/cnotes")
+			if err == nil || !strings.Contains(err.Error(), "/usr/bin/cnotes git-hook post-rewrite") {
+				t.Errorf("%s: expected an error saying what to add by hand, got %v", name, err)
+			}
+			if data, _ := os.ReadFile(hookPath); string(data) != existing {
+				t.Errorf("%s: expected the hook left alone, got %q", name, data)
+			}
+		}
+	})
+
+	t.Run("hook with exec redirecting output", func(t *testing.T) {
+		existing := "#!/usr/bin/env bash\nexec 1>&2\nif [ -n \"$SKIP_HOOKS\" ]; then\n\texit 0\nfi\necho rewritten\n"
+		if err := os.WriteFile(hookPath, []byte(existing), 0755); err != nil {
+			t.Fatalf("failed to write hook: %v", err)
+		}
+
+		if err := nm.InstallPostRewriteHook(ctx, "/usr/bin/cnotes"); err != nil {
+			t.Fatalf("failed to install hook: %v", err)
+		}
+		if data, _ := os.ReadFile(hookPath); !strings.Contains(string(data), "git-hook post-rewrite") {
+			t.Errorf("expected the cnotes call appended, got %q", data)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ReflogEntry represents a single entry in the HEAD reflog
+type ReflogEntry struct {
+	Hash     string `json:"hash"`               // Full object ID HEAD pointed to after the update
+	Subject  string `json:"subject"`            // Reflog message, e.g. "commit: Fix bug" or "cherry-pick: Add feature"
+	Previous string `json:"previous,omitempty"` // Where HEAD pointed before the update, e.g. the pre-amend commit
+}
+
+// Action returns the operation that recorded the entry, e.g. "commit (amend)"
+func (e ReflogEntry) Action() string {
+	action, _, _ := strings.Cut(e.Subject, ": ")
+	return action
+}
+
+// Message returns the reflog message without its action prefix
+func (e ReflogEntry) Message() string {
+	_, message, _ := strings.Cut(e.Subject, ": ")
+	return message
+}
+
+// CreatesCommit reports whether the entry records a newly created commit, as
+// opposed to HEAD moving to an existing one (checkout, reset, fast-forward).
+// Rebases are left to the post-rewrite hook, which carries existing notes over.
+func (e ReflogEntry) CreatesCommit() bool {
+	action := e.Action()
+	switch {
+	case action == "commit" || strings.HasPrefix(action, "commit "):
+		// commit, commit (amend), commit (merge), commit (initial)
+		return true
+	case action == "cherry-pick" || action == "revert" || action == "am":
+		return true
+	case strings.HasPrefix(action, "merge ") || action == "pull" || strings.HasPrefix(action, "pull "):
+		// Only true merges create a commit; fast-forwards just move HEAD
+		return !strings.HasPrefix(action, "pull --rebase") && !strings.HasPrefix(e.Message(), "Fast-forward")
+	default:
+		return false
+	}
+}
+
+// IsAmend reports whether the entry records a git commit --amend
+func (e ReflogEntry) IsAmend() bool {
+	return e.Action() == "commit (amend)"
+}
+
+// HeadReflogCount returns the number of entries in the HEAD reflog. An unborn
+// branch has no reflog and returns zero.
+func (nm *NotesManager) HeadReflogCount(ctx context.Context) (int, error) {
+	if _, err := nm.ResolveCommit(ctx, "HEAD"); errors.Is(err, ErrUnknownRevision) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	output, err := nm.git.Execute(ctx, nm.workDir, "rev-list", "--walk-reflogs", "--count", "HEAD")
+	if err != nil {
+		return 0, fmt.Errorf("failed to count reflog entries: %w", err)
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected reflog count %q: %w", output, err)
+	}
+
+	return count, nil
+}
+
+// HeadReflog returns the n most recent HEAD reflog entries, newest first
+func (nm *NotesManager) HeadReflog(ctx context.Context, n int) ([]ReflogEntry, error) {
+	output, err := nm.git.Execute(ctx, nm.workDir, "reflog", "show", "--format=%H%x09%gs", "-n", strconv.Itoa(n), "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reflog: %w", err)
+	}
+
+	var entries []ReflogEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		hash, subject, ok := strings.Cut(line, "\t")
+		if !ok || !isHexHash(hash) {
+			continue
+		}
+		entries = append(entries, ReflogEntry{Hash: hash, Subject: subject})
+	}
+
+	// Entries are newest first, so each entry's previous value is the next one
+	for i := 0; i+1 < len(entries); i++ {
+		entries[i].Previous = entries[i+1].Hash
+	}
+
+	return entries, nil
+}
+
+// CommitsCreatedSince returns the commits created by reflog entries added after
+// the HEAD reflog had the given number of entries, oldest first
+func (nm *NotesManager) CommitsCreatedSince(ctx context.Context, reflogCount int) ([]ReflogEntry, error) {
+	count, err := nm.HeadReflogCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	added := count - reflogCount
+	if added <= 0 {
+		return nil, nil
+	}
+
+	// Read one extra entry so the oldest new entry knows where HEAD was before it
+	entries, err := nm.HeadReflog(ctx, added+1)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []ReflogEntry
+	seen := make(map[string]bool)
+	for i := min(added, len(entries)) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if !entry.CreatesCommit() || seen[entry.Hash] {
+			continue
+		}
+		seen[entry.Hash] = true
+		commits = append(commits, entry)
+	}
+
+	return commits, nil
+}
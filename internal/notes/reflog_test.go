@@ -0,0 +1,138 @@
+package notes
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReflogEntryCreatesCommit(t *testing.T) {
+	tests := []struct {
+		subject  string
+		expected bool
+	}{
+		{"commit: Fix bug", true},
+		{"commit (initial): Initial commit", true},
+		{"commit (amend): Fix bug properly", true},
+		{"commit (merge): Merge branch 'feature'", true},
+		{"cherry-pick: Add feature", true},
+		{"revert: Revert \"Add feature\"", true},
+		{"am: Apply patch", true},
+		{"merge feature: Merge made by the 'ort' strategy.", true},
+		{"merge feature: Fast-forward", false},
+		{"pull: Merge made by the 'ort' strategy.", true},
+		{"pull origin main: Fast-forward", false},
+		{"pull --rebase (pick): Add feature", false},
+		{"rebase (pick): Add feature", false},
+		{"checkout: moving from main to feature", false},
+		{"reset: moving to HEAD~1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			entry := ReflogEntry{Subject: tt.subject}
+			if got := entry.CreatesCommit(); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestReflogEntryIsAmend(t *testing.T) {
+	if !(ReflogEntry{Subject: "commit (amend): Fix"}).IsAmend() {
+		t.Error("expected amend entry")
+	}
+
+	if (ReflogEntry{Subject: "commit: commit (amend): Fix"}).IsAmend() {
+		t.Error("message text should not count as an amend")
+	}
+}
+
+func TestCommitsCreatedSince(t *testing.T) {
+	ctx := context.Background()
+	head := "cccccccccccccccccccccccccccccccccccccccc"
+
+	setup := func(count string, reflog string) *NotesManager {
+		mockGit := NewMockGitExecutor()
+		nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+		mockGit.SetResponse([]string{"rev-parse", "--verify", "--quiet", "HEAD^{commit}"}, []byte(head+"\n"), nil)
+		mockGit.SetResponse([]string{"rev-list", "--walk-reflogs", "--count", "HEAD"}, []byte(count+"\n"), nil)
+		mockGit.SetResponse([]string{"reflog", "show", "--format=%H%x09%gs", "-n", "4", "HEAD"}, []byte(reflog), nil)
+		return nm
+	}
+
+	t.Run("chained commits oldest first", func(t *testing.T) {
+		nm := setup("13",
+			head+"\tcommit: Second\n"+
+				"bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb\tcheckout: moving from main to feature\n"+
+				"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\tcommit: First\n"+
+				"9999999999999999999999999999999999999999\tcommit: Before the command\n")
+
+		commits, err := nm.CommitsCreatedSince(ctx, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(commits) != 2 {
+			t.Fatalf("expected 2 commits, got %d: %v", len(commits), commits)
+		}
+
+		if commits[0].Message() != "First" || commits[1].Hash != head {
+			t.Errorf("unexpected commits: %v", commits)
+		}
+
+		if commits[0].Previous != "9999999999999999999999999999999999999999" {
+			t.Errorf("expected previous HEAD from the entry before the command, got %s", commits[0].Previous)
+		}
+	})
+
+	t.Run("amend records the pre-amend commit", func(t *testing.T) {
+		mockGit := NewMockGitExecutor()
+		nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+		mockGit.SetResponse([]string{"rev-parse", "--verify", "--quiet", "HEAD^{commit}"}, []byte(head+"\n"), nil)
+		mockGit.SetResponse([]string{"rev-list", "--walk-reflogs", "--count", "HEAD"}, []byte("6\n"), nil)
+		mockGit.SetResponse([]string{"reflog", "show", "--format=%H%x09%gs", "-n", "2", "HEAD"}, []byte(
+			head+"\tcommit (amend): Fix bug\n"+
+				"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\tcommit: Fix bug\n"), nil)
+
+		commits, err := nm.CommitsCreatedSince(ctx, 5)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(commits) != 1 || !commits[0].IsAmend() {
+			t.Fatalf("expected 1 amend, got %v", commits)
+		}
+
+		if commits[0].Previous != "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa" {
+			t.Errorf("expected pre-amend commit, got %s", commits[0].Previous)
+		}
+	})
+
+	t.Run("no reflog movement", func(t *testing.T) {
+		nm := setup("10", "")
+
+		commits, err := nm.CommitsCreatedSince(ctx, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(commits) != 0 {
+			t.Errorf("expected no commits, got %v", commits)
+		}
+	})
+
+	t.Run("unborn branch", func(t *testing.T) {
+		mockGit := NewMockGitExecutor()
+		nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+		mockGit.SetResponse([]string{"rev-parse", "--verify", "--quiet", "HEAD^{commit}"}, nil, ErrUnknownRevision)
+
+		count, err := nm.HeadReflogCount(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if count != 0 {
+			t.Errorf("expected 0 entries, got %d", count)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package notes
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/imjasonh/cnotes/internal/config"
+)
+
+// RewriteMapping is one line of the old→new commit mapping git passes to the
+// post-rewrite hook on stdin
+type RewriteMapping struct {
+	OldCommit string
+	NewCommit string
+}
+
+// ParseRewriteMappings parses the post-rewrite hook input:
+// "<old-sha> SP <new-sha> [SP <extra-info>] LF"
+func ParseRewriteMappings(r io.Reader) ([]RewriteMapping, error) {
+	var mappings []RewriteMapping
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		mappings = append(mappings, RewriteMapping{OldCommit: fields[0], NewCommit: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rewrite mappings: %w", err)
+	}
+
+	return mappings, nil
+}
+
+// FullNotesRef returns the fully qualified notes ref, e.g. refs/notes/claude-conversations
+func (nm *NotesManager) FullNotesRef() string {
+	if strings.HasPrefix(nm.notesRef, "refs/") {
+		return nm.notesRef
+	}
+	return "refs/notes/" + nm.notesRef
+}
+
+// RewriteNotes carries notes from rewritten commits to their replacements.
+// When several commits were squashed into one, their notes are merged into a
+// single note instead of git's raw concatenation. It returns the number of
+// notes written.
+func (nm *NotesManager) RewriteNotes(ctx context.Context, mappings []RewriteMapping) (int, error) {
+	// Group old commits by the commit that replaced them, keeping git's order
+	var targets []string
+	sources := make(map[string][]string)
+	for _, mapping := range mappings {
+		if _, ok := sources[mapping.NewCommit]; !ok {
+			targets = append(targets, mapping.NewCommit)
+		}
+		sources[mapping.NewCommit] = append(sources[mapping.NewCommit], mapping.OldCommit)
+	}
+
+	written := 0
+	for _, target := range targets {
+		var collected []ConversationNote
+		for _, source := range sources[target] {
+			note, err := nm.GetConversationNote(ctx, source)
+			if err != nil {
+				return written, fmt.Errorf("failed to read note for %s: %w", source, err)
+			}
+			if note != nil {
+				collected = append(collected, *note)
+			}
+		}
+		if len(collected) == 0 {
+			continue
+		}
+
+		merged := MergeConversationNotes(collected)
+		// Overwrite whatever git copied itself, which concatenates raw JSON on squash
+		if err := nm.MoveConversationNote(ctx, "", target, merged); err != nil {
+			return written, err
+		}
+		written++
+	}
+
+	return written, nil
+}
+
+// MergeConversationNotes combines the notes of several commits, oldest first,
+// into a single note for the commit that replaced them. Entries of the same
+// session are merged into one.
+func MergeConversationNotes(notes []ConversationNote) ConversationNote {
+	if len(notes) == 0 {
+		return ConversationNote{}
+	}
+
+	// Start from the first note's summary, for notes without entries
+	merged := notes[0]
+	merged.Entries = nil
+	merged.Amendments = nil
+	merged.Redactions = nil
+	merged.Sessions = append([]string(nil), notes[0].Sessions...)
+	merged.ToolsUsed = append([]string(nil), notes[0].ToolsUsed...)
+	for _, note := range notes {
+		merged.Merge(note)
+	}
+
+	return merged
+}
+
+// ConfigureNotesRewrite configures git to carry notes over when commits are
+// rewritten by git commit --amend and git rebase
+func (nm *NotesManager) ConfigureNotesRewrite(ctx context.Context) error {
+	ref := nm.FullNotesRef()
+
+	// notes.rewriteRef is multi-valued; only add ours if it isn't there yet
+	if _, err := nm.git.Execute(ctx, nm.workDir, "config", "--fixed-value", "--get", "notes.rewriteRef", ref); err != nil {
+		if _, err := nm.git.Execute(ctx, nm.workDir, "config", "--add", "notes.rewriteRef", ref); err != nil {
+			return fmt.Errorf("failed to set notes.rewriteRef: %w", err)
+		}
+	}
+
+	for _, key := range []string{"notes.rewrite.rebase", "notes.rewrite.amend"} {
+		if _, err := nm.git.Execute(ctx, nm.workDir, "config", key, "true"); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// UnconfigureNotesRewrite reverses ConfigureNotesRewrite
+func (nm *NotesManager) UnconfigureNotesRewrite(ctx context.Context) {
+	// git config exits 5 when there is nothing to unset, which is fine here
+	_, _ = nm.git.Execute(ctx, nm.workDir, "config", "--fixed-value", "--unset-all", "notes.rewriteRef", nm.FullNotesRef())
+
+	for _, key := range []string{"notes.rewrite.rebase", "notes.rewrite.amend"} {
+		_, _ = nm.git.Execute(ctx, nm.workDir, "config", "--unset", key)
+	}
+}
+
+// strictestPrivacyLevel returns whichever privacy level captured less, so a
+// merged note doesn't claim to hold more than it does. Notes from before
+// privacy levels were recorded have none.
+func strictestPrivacyLevel(a, b string) string {
+	rank := map[string]int{config.PrivacyMinimal: 1, config.PrivacyStandard: 2, config.PrivacyFull: 3}
+	if a == "" || (b != "" && rank[b] < rank[a]) {
+		return b
+	}
+	return a
+}
+
+// mergeStrings adds the strings, such as sessions or tools, not yet in a list
+// to it
+func mergeStrings(list, more []string) []string {
+	for _, s := range more {
+		if !containsString(list, s) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+func containsString(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
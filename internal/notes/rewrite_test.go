@@ -0,0 +1,246 @@
+package notes
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRewriteMappings(t *testing.T) {
+	input := "aaa111 bbb222\nccc333 bbb222 extra-info\n\nmalformed\nddd444 eee555\n"
+
+	mappings, err := ParseRewriteMappings(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []RewriteMapping{
+		{OldCommit: "aaa111", NewCommit: "bbb222"},
+		{OldCommit: "ccc333", NewCommit: "bbb222"},
+		{OldCommit: "ddd444", NewCommit: "eee555"},
+	}
+
+	if len(mappings) != len(expected) {
+		t.Fatalf("expected %d mappings, got %d", len(expected), len(mappings))
+	}
+
+	for i := range expected {
+		if mappings[i] != expected[i] {
+			t.Errorf("mapping %d: expected %+v, got %+v", i, expected[i], mappings[i])
+		}
+	}
+}
+
+func TestFullNotesRef(t *testing.T) {
+	nm := NewNotesManager("/test/dir")
+	if ref := nm.FullNotesRef(); ref != "refs/notes/claude-conversations" {
+		t.Errorf("unexpected ref: %s", ref)
+	}
+
+	nm.SetNotesRef("refs/notes/custom")
+	if ref := nm.FullNotesRef(); ref != "refs/notes/custom" {
+		t.Errorf("unexpected ref: %s", ref)
+	}
+}
+
+func TestMergeConversationNotes(t *testing.T) {
+	base := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	note := func(entry SessionEntry, amendments []Amendment, redactions map[string]int) ConversationNote {
+		var note ConversationNote
+		note.AddEntry(entry)
+		note.Amendments = amendments
+		note.Redactions = redactions
+		return note
+	}
+
+	merged := MergeConversationNotes([]ConversationNote{
+		note(SessionEntry{
+			SessionID:           "session-1",
+			Sessions:            []string{"session-1"},
+			Timestamp:           base.Add(time.Hour),
+			ConversationExcerpt: "User: First change",
+			Events:              []Event{{Role: RoleUser, Text: "First change"}},
+			Excluded:            map[string]int{"tool": 2},
+			ToolsUsed:           []string{"Bash", "Edit"},
+			CommitContext:       "Git command: git commit -m first",
+			LastEventTime:       base.Add(time.Hour),
+			PrivacyLevel:        "full",
+		}, nil, map[string]int{"github-token": 1}),
+		note(SessionEntry{
+			SessionID:           "session-1",
+			Sessions:            []string{"session-1", "session-2"},
+			Subagents:           []Subagent{{ToolUseID: "toolu_1", Description: "Find tests"}},
+			Timestamp:           base,
+			ConversationExcerpt: "User: Second change",
+			Events:              []Event{{Role: RoleUser, Text: "Second change"}},
+			Excluded:            map[string]int{"tool": 1, "user": 1},
+			ToolsUsed:           []string{"Bash", "Write"},
+			CommitContext:       "Git command: git commit -m second",
+			LastEventTime:       base.Add(2 * time.Hour),
+			PrivacyLevel:        "minimal",
+		}, []Amendment{{AmendedCommit: "abc123"}}, map[string]int{"github-token": 1, "jwt": 2}),
+		note(SessionEntry{
+			SessionID:           "session-3",
+			Timestamp:           base.Add(3 * time.Hour),
+			ConversationExcerpt: "User: Third change",
+			Events:              []Event{{Role: RoleUser, Text: "Third change"}},
+		}, nil, nil),
+	})
+
+	// The squashed commits from the same session share an entry
+	if len(merged.Entries) != 2 || merged.Entries[1].SessionID != "session-3" {
+		t.Fatalf("expected an entry for each session, got %+v", merged.Entries)
+	}
+	entry := merged.Entries[0]
+
+	if entry.ConversationExcerpt != "User: First change\n\nUser: Second change" {
+		t.Errorf("unexpected excerpt: %q", entry.ConversationExcerpt)
+	}
+
+	if len(entry.Events) != 2 || entry.Events[0].Text != "First change" || entry.Events[1].Text != "Second change" {
+		t.Errorf("expected events to be joined, got %+v", entry.Events)
+	}
+
+	if entry.Excluded["tool"] != 3 || entry.Excluded["user"] != 1 {
+		t.Errorf("expected excluded counts to be summed, got %v", entry.Excluded)
+	}
+
+	if entry.CommitContext != "Git command: git commit -m first\nGit command: git commit -m second" {
+		t.Errorf("unexpected commit context: %q", entry.CommitContext)
+	}
+
+	if strings.Join(merged.ToolsUsed, ",") != "Bash,Edit,Write" {
+		t.Errorf("unexpected tools: %v", merged.ToolsUsed)
+	}
+
+	if strings.Join(merged.Sessions, ",") != "session-1,session-2,session-3" {
+		t.Errorf("unexpected sessions: %v", merged.Sessions)
+	}
+
+	if len(entry.Subagents) != 1 || entry.Subagents[0].ToolUseID != "toolu_1" {
+		t.Errorf("expected subagents to be kept, got %+v", entry.Subagents)
+	}
+
+	if merged.Redactions["github-token"] != 2 || merged.Redactions["jwt"] != 2 {
+		t.Errorf("expected redaction counts to be summed, got %v", merged.Redactions)
+	}
+
+	if merged.PrivacyLevel != "minimal" {
+		t.Errorf("expected the strictest privacy level, got %q", merged.PrivacyLevel)
+	}
+
+	if !merged.Timestamp.Equal(base) {
+		t.Errorf("expected earliest timestamp, got %v", merged.Timestamp)
+	}
+
+	if !merged.LastEventTime.Equal(base.Add(2 * time.Hour)) {
+		t.Errorf("expected latest event time, got %v", merged.LastEventTime)
+	}
+
+	if len(merged.Amendments) != 1 {
+		t.Errorf("expected amendments to be kept, got %d", len(merged.Amendments))
+	}
+
+	// The merged note must still be a single JSON document
+	data, _ := json.Marshal(merged)
+	var decoded ConversationNote
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Errorf("merged note is not valid JSON: %v", err)
+	}
+
+	t.Run("older notes without events", func(t *testing.T) {
+		merged := MergeConversationNotes([]ConversationNote{
+			note(SessionEntry{SessionID: "session-1", ConversationExcerpt: "👤 User: First change"}, nil, nil),
+			note(SessionEntry{SessionID: "session-1", ConversationExcerpt: "User: Second change", Events: []Event{{Role: RoleUser, Text: "Second change"}}}, nil, nil),
+		})
+
+		// Rendering the events alone would lose the first note's conversation
+		if merged.Entries[0].Events != nil {
+			t.Errorf("expected no events, got %+v", merged.Entries[0].Events)
+		}
+		if merged.Entries[0].ConversationExcerpt != "👤 User: First change\n\nUser: Second change" {
+			t.Errorf("unexpected excerpt: %q", merged.Entries[0].ConversationExcerpt)
+		}
+	})
+}
+
+func TestRewriteNotes(t *testing.T) {
+	ctx := context.Background()
+	mockGit := NewMockGitExecutor()
+	nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+
+	note := func(session, excerpt string) ConversationNote {
+		var note ConversationNote
+		note.AddEntry(SessionEntry{SessionID: session, ConversationExcerpt: excerpt})
+		note.SchemaVersion = CurrentSchemaVersion
+		return note
+	}
+	note1, _ := json.Marshal(note("session-1", "first"))
+	note2, _ := json.Marshal(note("session-1", "second"))
+	note3, _ := json.Marshal(note("session-2", "third"))
+
+	mockGit.SetResponse([]string{"notes", "--ref", "claude-conversations", "show", "old1"}, note1, nil)
+	mockGit.SetResponse([]string{"notes", "--ref", "claude-conversations", "show", "old2"}, note2, nil)
+	mockGit.SetResponse([]string{"notes", "--ref", "claude-conversations", "show", "old3"}, note3, nil)
+	mockGit.SetResponse([]string{"notes", "--ref", "claude-conversations", "show", "old4"}, nil, ErrNoNote)
+
+	squashed, _ := json.MarshalIndent(note("session-1", "first\n\nsecond"), "", "  ")
+	moved, _ := json.MarshalIndent(note("session-2", "third"), "", "  ")
+	mockGit.SetInputResponse([]string{"notes", "--ref", "claude-conversations", "add", "-f", "-F", "-", "new1"}, squashed, nil, nil)
+	mockGit.SetInputResponse([]string{"notes", "--ref", "claude-conversations", "add", "-f", "-F", "-", "new2"}, moved, nil, nil)
+
+	written, err := nm.RewriteNotes(ctx, []RewriteMapping{
+		{OldCommit: "old1", NewCommit: "new1"},
+		{OldCommit: "old2", NewCommit: "new1"},
+		{OldCommit: "old3", NewCommit: "new2"},
+		{OldCommit: "old4", NewCommit: "new3"},
+	})
+	if err != nil {
+		t.Fatalf("failed to rewrite notes: %v", err)
+	}
+
+	if written != 2 {
+		t.Errorf("expected 2 notes written, got %d", written)
+	}
+}
+
+func TestConfigureNotesRewrite(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("adds rewrite ref when missing", func(t *testing.T) {
+		mockGit := NewMockGitExecutor()
+		nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+		mockGit.SetResponse([]string{"config", "--add", "notes.rewriteRef", "refs/notes/claude-conversations"}, nil, nil)
+		mockGit.SetResponse([]string{"config", "notes.rewrite.rebase", "true"}, nil, nil)
+		mockGit.SetResponse([]string{"config", "notes.rewrite.amend", "true"}, nil, nil)
+
+		if err := nm.ConfigureNotesRewrite(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(mockGit.GetExecutedCommands()) != 4 {
+			t.Errorf("expected 4 git commands, got %d", len(mockGit.GetExecutedCommands()))
+		}
+	})
+
+	t.Run("does not duplicate rewrite ref", func(t *testing.T) {
+		mockGit := NewMockGitExecutor()
+		nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+		mockGit.SetResponse([]string{"config", "--fixed-value", "--get", "notes.rewriteRef", "refs/notes/claude-conversations"}, []byte("refs/notes/claude-conversations\n"), nil)
+		mockGit.SetResponse([]string{"config", "notes.rewrite.rebase", "true"}, nil, nil)
+		mockGit.SetResponse([]string{"config", "notes.rewrite.amend", "true"}, nil, nil)
+
+		if err := nm.ConfigureNotesRewrite(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, cmd := range mockGit.GetExecutedCommands() {
+			if cmd.args[1] == "--add" {
+				t.Error("rewrite ref should not be added twice")
+			}
+		}
+	})
+}
@@ -0,0 +1,242 @@
+package notes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CurrentSchemaVersion is the version of the ConversationNote schema this
+// cnotes writes. Bump it, and register a migration, whenever a change to the
+// schema would make notes written before it decode wrongly.
+const CurrentSchemaVersion = 2
+
+// ErrNewerSchema reports a note written by a newer cnotes, which this one
+// can't decode without losing what it doesn't know about
+var ErrNewerSchema = errors.New("note was written by a newer version of cnotes")
+
+// errInvalidNote reports a note that isn't a conversation note at all
+var errInvalidNote = errors.New("not a conversation note")
+
+// migration upgrades a note, as a decoded JSON object, from one schema
+// version to the next
+type migration struct {
+	description string
+	migrate     func(note map[string]any)
+}
+
+// migrations upgrade notes one schema version at a time: migrations[v]
+// upgrades a note from version v to v+1. Notes from before schema versions
+// were recorded are version 0.
+var migrations = []migration{
+	{"record the session in sessions", migrateSessions},
+	{"move the conversation into a list of session entries", migrateEntries},
+}
+
+// migrateSessions fills in the sessions list, which notes from before
+// attribution policies left out since they only had the one session
+func migrateSessions(note map[string]any) {
+	withSessions := func(object map[string]any) {
+		if _, ok := object["sessions"]; ok {
+			return
+		}
+		if session, ok := object["session_id"].(string); ok && session != "" {
+			object["sessions"] = []any{session}
+		}
+	}
+
+	withSessions(note)
+	amendments, _ := note["amendments"].([]any)
+	for _, amendment := range amendments {
+		if amendment, ok := amendment.(map[string]any); ok {
+			withSessions(amendment)
+		}
+	}
+}
+
+// migrateEntries moves the note's conversation into the first of a list of
+// session entries, which notes from before several sessions could contribute
+// to one didn't have. The summary fields stay where they are, but since the
+// note's models include its amendments' usage, the entry's models don't.
+func migrateEntries(note map[string]any) {
+	entry := make(map[string]any)
+	for _, key := range []string{"session_id", "sessions", "timestamp", "tools_used", "last_event_time", "privacy_level"} {
+		if value, ok := note[key]; ok {
+			entry[key] = value
+		}
+	}
+	for _, key := range []string{"conversation_excerpt", "events", "excluded", "commit_context", "subagents"} {
+		if value, ok := note[key]; ok {
+			entry[key] = value
+			delete(note, key)
+		}
+	}
+
+	var models []ModelUsage
+	var amendments []struct {
+		Models []ModelUsage `json:"models"`
+	}
+	if remarshal(note["models"], &models) == nil && remarshal(note["amendments"], &amendments) == nil {
+		for _, amendment := range amendments {
+			for _, amended := range amendment.Models {
+				for i := range models {
+					if models[i].Model == amended.Model {
+						models[i].subtract(amended)
+					}
+				}
+			}
+		}
+		var original []ModelUsage
+		for _, usage := range models {
+			if usage.Messages > 0 {
+				original = append(original, usage)
+			}
+		}
+		entry["models"] = original
+	}
+
+	note["entries"] = []any{entry}
+}
+
+// remarshal converts a decoded JSON value into v
+func remarshal(value any, v any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// decodeNote decodes a note written with any schema version up to the
+// current one, migrating it to the current schema. Older versions of git's
+// notes rewriting concatenated the notes of squashed commits, so several
+// notes in a row are merged into one. It reports whether the note needed
+// migrating or merging, so that rewriting it would change it.
+func decodeNote(data []byte) (ConversationNote, bool, error) {
+	var notes []ConversationNote
+	migrated := false
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	for {
+		var object map[string]any
+		if err := decoder.Decode(&object); err == io.EOF {
+			break
+		} else if err != nil {
+			return ConversationNote{}, false, fmt.Errorf("%w: %v", errInvalidNote, err)
+		}
+
+		note, upgraded, err := migrateNote(object)
+		if err != nil {
+			return ConversationNote{}, false, err
+		}
+		notes = append(notes, note)
+		migrated = migrated || upgraded
+	}
+
+	switch len(notes) {
+	case 0:
+		return ConversationNote{}, false, fmt.Errorf("%w: note is empty", errInvalidNote)
+	case 1:
+		return notes[0], migrated, nil
+	}
+	return MergeConversationNotes(notes), true, nil
+}
+
+// migrateNote upgrades a decoded note to the current schema, reporting
+// whether it had to
+func migrateNote(object map[string]any) (ConversationNote, bool, error) {
+	version := 0
+	if number, ok := object["schema_version"].(json.Number); ok {
+		v, err := number.Int64()
+		if err != nil {
+			return ConversationNote{}, false, fmt.Errorf("%w: invalid schema version %s", errInvalidNote, number)
+		}
+		version = int(v)
+	}
+	if version > CurrentSchemaVersion {
+		return ConversationNote{}, false, fmt.Errorf("%w: it has schema version %d, but this cnotes reads up to %d; upgrade cnotes to read it",
+			ErrNewerSchema, version, CurrentSchemaVersion)
+	}
+
+	upgraded := version < CurrentSchemaVersion
+	for ; version < CurrentSchemaVersion; version++ {
+		migrations[version].migrate(object)
+	}
+	object["schema_version"] = CurrentSchemaVersion
+
+	data, err := json.Marshal(object)
+	if err != nil {
+		return ConversationNote{}, false, err
+	}
+	var note ConversationNote
+	if err := json.Unmarshal(data, &note); err != nil {
+		return ConversationNote{}, false, fmt.Errorf("%w: %v", errInvalidNote, err)
+	}
+	return note, upgraded, nil
+}
+
+// MigrateNotes rewrites every note in the notes ref that was written with an
+// older schema to the current one, in one notes commit on top of the ref. Notes
+// that aren't conversation notes are left alone, but a note from a newer cnotes
+// fails the migration. It returns how many notes were rewritten.
+func (nm *NotesManager) MigrateNotes(ctx context.Context) (int, error) {
+	ref := nm.FullNotesRef()
+	output, err := nm.git.Execute(ctx, nm.workDir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if errors.Is(err, ErrUnknownRevision) {
+		// No notes, so nothing to migrate
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", ref, err)
+	}
+	tip := strings.TrimSpace(string(output))
+	output, err = nm.git.Execute(ctx, nm.workDir, "rev-parse", tip+"^{tree}")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", ref, err)
+	}
+	tree := strings.TrimSpace(string(output))
+
+	migrated := 0
+	rewriter, err := newHistoryRewriter(ctx, nm, func(commit string, data []byte) ([]byte, error) {
+		note, changed, err := decodeNote(data)
+		if errors.Is(err, ErrNewerSchema) {
+			return nil, err
+		}
+		if err != nil || !changed {
+			// Not a conversation note, or already current
+			return nil, nil
+		}
+		migrated++
+		return nm.marshalNote(note)
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer rewriter.close()
+
+	newTree, err := rewriter.tree(ctx, tree, "")
+	if err != nil {
+		return 0, err
+	}
+	if newTree == tree {
+		return 0, nil
+	}
+
+	message := fmt.Sprintf("cnotes: migrate notes to schema version %d", CurrentSchemaVersion)
+	output, err = nm.git.Execute(ctx, nm.workDir, "commit-tree", newTree, "-p", tip, "-m", message)
+	if err != nil {
+		return 0, fmt.Errorf("failed to commit migrated notes: %w", err)
+	}
+	commit := strings.TrimSpace(string(output))
+
+	if _, err := nm.git.Execute(ctx, nm.workDir, "update-ref", "-m", message, ref, commit, tip); err != nil {
+		return 0, fmt.Errorf("failed to update %s: %w", ref, err)
+	}
+
+	return migrated, nil
+}
@@ -0,0 +1,210 @@
+package notes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestMigrationsCoverEveryVersion(t *testing.T) {
+	if len(migrations) != CurrentSchemaVersion {
+		t.Errorf("expected a migration to each of %d schema versions, got %d", CurrentSchemaVersion, len(migrations))
+	}
+}
+
+func TestDecodeNote(t *testing.T) {
+	t.Run("current", func(t *testing.T) {
+		data, _ := json.Marshal(ConversationNote{SchemaVersion: CurrentSchemaVersion, SessionID: "session-1", Sessions: []string{"session-1"}})
+		note, migrated, err := decodeNote(data)
+		if err != nil {
+			t.Fatalf("failed to decode note: %v", err)
+		}
+		if migrated || note.SessionID != "session-1" {
+			t.Errorf("expected the note as it is, got %+v, migrated %t", note, migrated)
+		}
+	})
+
+	t.Run("before schema versions", func(t *testing.T) {
+		data := `{
+			"session_id": "session-1",
+			"conversation_excerpt": "first",
+			"amendments": [{"session_id": "session-2", "conversation_excerpt": "second"}]
+		}`
+		note, migrated, err := decodeNote([]byte(data))
+		if err != nil {
+			t.Fatalf("failed to decode note: %v", err)
+		}
+		if !migrated || note.SchemaVersion != CurrentSchemaVersion {
+			t.Errorf("expected the note migrated to version %d, got version %d, migrated %t", CurrentSchemaVersion, note.SchemaVersion, migrated)
+		}
+		if strings.Join(note.Sessions, ",") != "session-1" {
+			t.Errorf("expected the session recorded in sessions, got %v", note.Sessions)
+		}
+		if len(note.Amendments) != 1 || strings.Join(note.Amendments[0].Sessions, ",") != "session-2" {
+			t.Errorf("expected the amendment's session recorded in sessions, got %+v", note.Amendments)
+		}
+		if len(note.Entries) != 1 || note.Entries[0].SessionID != "session-1" || note.Entries[0].ConversationExcerpt != "first" {
+			t.Errorf("expected the conversation moved into an entry, got %+v", note.Entries)
+		}
+	})
+
+	t.Run("before session entries", func(t *testing.T) {
+		data := `{
+			"schema_version": 1,
+			"session_id": "session-1",
+			"sessions": ["session-1"],
+			"conversation_excerpt": "first",
+			"events": [{"role": "user", "text": "Fix the bug"}],
+			"commit_context": "Git command: git commit",
+			"models": [{"model": "claude-sonnet-4", "messages": 3, "input_tokens": 300, "estimated_cost_usd": 3}],
+			"amendments": [{"session_id": "session-2", "models": [{"model": "claude-sonnet-4", "messages": 1, "input_tokens": 100, "estimated_cost_usd": 1}]}]
+		}`
+		note, migrated, err := decodeNote([]byte(data))
+		if err != nil {
+			t.Fatalf("failed to decode note: %v", err)
+		}
+		if !migrated || len(note.Entries) != 1 {
+			t.Fatalf("expected the note migrated into one entry, got %+v, migrated %t", note, migrated)
+		}
+
+		entry := note.Entries[0]
+		if entry.SessionID != "session-1" || entry.ConversationExcerpt != "first" || len(entry.Events) != 1 || entry.CommitContext != "Git command: git commit" {
+			t.Errorf("expected the conversation moved into the entry, got %+v", entry)
+		}
+		// The amendment's usage stays with the amendment
+		if usage := entry.TotalUsage(); usage.Messages != 2 || usage.InputTokens != 200 || usage.EstimatedCostUSD != 2 {
+			t.Errorf("expected the entry's usage without the amendment's, got %+v", usage)
+		}
+		if usage := note.TotalUsage(); usage.Messages != 3 {
+			t.Errorf("expected the note's usage to still include the amendment's, got %+v", usage)
+		}
+	})
+
+	t.Run("concatenated by git", func(t *testing.T) {
+		data := `{"session_id": "session-1", "conversation_excerpt": "first"}
+
+{"schema_version": 1, "session_id": "session-2", "sessions": ["session-2"], "conversation_excerpt": "second"}`
+		note, migrated, err := decodeNote([]byte(data))
+		if err != nil {
+			t.Fatalf("failed to decode note: %v", err)
+		}
+		if !migrated || len(note.Entries) != 2 || note.Entries[1].ConversationExcerpt != "second" || strings.Join(note.Sessions, ",") != "session-1,session-2" {
+			t.Errorf("expected the notes merged, got %+v, migrated %t", note, migrated)
+		}
+	})
+
+	t.Run("newer", func(t *testing.T) {
+		data := `{"schema_version": 99, "session_id": "session-1"}`
+		_, _, err := decodeNote([]byte(data))
+		if !errors.Is(err, ErrNewerSchema) {
+			t.Fatalf("expected ErrNewerSchema, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "schema version 99") {
+			t.Errorf("expected the error to name the note's version, got %v", err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, _, err := decodeNote([]byte("not json")); err == nil {
+			t.Error("expected an error for invalid JSON")
+		}
+	})
+}
+
+func TestGetConversationNoteNewerSchema(t *testing.T) {
+	ctx := context.Background()
+	mockGit := NewMockGitExecutor()
+	nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+
+	mockGit.SetResponse([]string{"notes", "--ref", "claude-conversations", "show", "abc123"}, []byte(`{"schema_version": 99}`), nil)
+
+	if _, err := nm.GetConversationNote(ctx, "abc123"); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("expected ErrNewerSchema, got %v", err)
+	}
+	if exists, err := nm.HasConversationNote(ctx, "abc123"); err != nil || !exists {
+		t.Errorf("expected a note from a newer cnotes to count as a note, got %t, %v", exists, err)
+	}
+}
+
+func TestMigrateNotes(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		output, err := (&RealGitExecutor{}).Execute(ctx, dir, args...)
+		if err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	git("init", "-q")
+	git("config", "user.name", "Test")
+	git("config", "user.email", "test@example.com")
+	var commits []string
+	for _, message := range []string{"first", "second", "third"} {
+		git("commit", "-q", "--allow-empty", "-m", message)
+		commits = append(commits, git("rev-parse", "HEAD"))
+	}
+
+	nm := NewNotesManager(dir)
+	current, _ := json.Marshal(ConversationNote{SchemaVersion: CurrentSchemaVersion, SessionID: "session-3", Sessions: []string{"session-3"}})
+	for commit, note := range map[string]string{
+		commits[0]: `{"session_id": "session-1", "conversation_excerpt": "first"}`,
+		commits[1]: "not a conversation note",
+		commits[2]: string(current),
+	} {
+		git("notes", "--ref", "claude-conversations", "add", "-m", note, commit)
+	}
+	tip := git("rev-parse", "refs/notes/claude-conversations")
+
+	migrated, err := nm.MigrateNotes(ctx)
+	if err != nil {
+		t.Fatalf("failed to migrate notes: %v", err)
+	}
+	if migrated != 1 {
+		t.Errorf("expected 1 note migrated, got %d", migrated)
+	}
+	if parent := git("rev-parse", "refs/notes/claude-conversations^"); parent != tip {
+		t.Errorf("expected one notes commit on top of %s, got parent %s", tip, parent)
+	}
+
+	data := git("notes", "--ref", "claude-conversations", "show", commits[0])
+	var note map[string]any
+	if err := json.Unmarshal([]byte(data), &note); err != nil {
+		t.Fatalf("failed to parse migrated note: %v", err)
+	}
+	entries, _ := note["entries"].([]any)
+	if note["schema_version"] != float64(CurrentSchemaVersion) || len(entries) != 1 || entries[0].(map[string]any)["conversation_excerpt"] != "first" {
+		t.Errorf("expected the note rewritten at version %d, got %s", CurrentSchemaVersion, data)
+	}
+	if other := git("notes", "--ref", "claude-conversations", "show", commits[1]); other != "not a conversation note" {
+		t.Errorf("expected other notes to be left alone, got %q", other)
+	}
+
+	t.Run("already current", func(t *testing.T) {
+		migrated, err := nm.MigrateNotes(ctx)
+		if err != nil || migrated != 0 {
+			t.Errorf("expected nothing to migrate, got %d, %v", migrated, err)
+		}
+	})
+
+	t.Run("newer", func(t *testing.T) {
+		git("notes", "--ref", "claude-conversations", "add", "-f", "-m", `{"schema_version": 99}`, commits[1])
+		tip := git("rev-parse", "refs/notes/claude-conversations")
+
+		if _, err := nm.MigrateNotes(ctx); !errors.Is(err, ErrNewerSchema) {
+			t.Errorf("expected ErrNewerSchema, got %v", err)
+		}
+		if got := git("rev-parse", "refs/notes/claude-conversations"); got != tip {
+			t.Error("expected the notes ref to be left alone")
+		}
+	})
+}
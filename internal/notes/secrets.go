@@ -0,0 +1,342 @@
+package notes
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/imjasonh/cnotes/internal/redact"
+)
+
+// SecretFinding is a secret the redactor found in a stored note
+type SecretFinding struct {
+	Commit string
+	Field  string // JSON path of the text it's in, e.g. amendments[0].conversation_excerpt
+	RuleID string
+	Secret string
+}
+
+// ScanNote returns the secrets the redactor finds in a note's conversation text
+func ScanNote(commit string, note ConversationNote, redactor *redact.Redactor) []SecretFinding {
+	var findings []SecretFinding
+	note.eachText(func(field string, text *string) {
+		for _, finding := range redactor.Find(*text) {
+			findings = append(findings, SecretFinding{
+				Commit: commit,
+				Field:  field,
+				RuleID: finding.RuleID,
+				Secret: (*text)[finding.Start:finding.End],
+			})
+		}
+	})
+	return findings
+}
+
+// RedactNote rewrites a commit's note with the secrets the notes manager's
+// redactor finds removed. It reports false, writing nothing, if the commit
+// has no note or the note holds no secrets.
+func (nm *NotesManager) RedactNote(ctx context.Context, commit string) (bool, error) {
+	note, err := nm.GetConversationNote(ctx, commit)
+	if err != nil {
+		return false, err
+	}
+	if note == nil || len(ScanNote(commit, *note, nm.redactor)) == 0 {
+		return false, nil
+	}
+
+	if err := nm.MoveConversationNote(ctx, "", commit, *note); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RewriteNotesHistory rewrites every commit of the notes ref with the notes
+// redacted, so that secrets are gone from the ref's history and not just its
+// tip. Only the notes of the given commits are redacted, or every note if
+// there are none. It returns how many notes commits were rewritten; their
+// authors, dates and messages are kept.
+func (nm *NotesManager) RewriteNotesHistory(ctx context.Context, commits []string) (int, error) {
+	ref := nm.FullNotesRef()
+	output, err := nm.git.Execute(ctx, nm.workDir, "rev-list", "--reverse", "--topo-order", ref)
+	if errors.Is(err, ErrUnknownRevision) {
+		// No notes, so no history to rewrite
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to list %s history: %w", ref, err)
+	}
+	history := strings.Fields(string(output))
+	if len(history) == 0 {
+		return 0, nil
+	}
+
+	var annotated map[string]bool
+	if len(commits) > 0 {
+		annotated = make(map[string]bool)
+		for _, commit := range commits {
+			annotated[commit] = true
+		}
+	}
+	rewriter, err := newHistoryRewriter(ctx, nm, func(commit string, data []byte) ([]byte, error) {
+		if annotated != nil && !annotated[commit] {
+			return nil, nil
+		}
+		note, _, err := decodeNote(data)
+		if err != nil || len(ScanNote(commit, note, nm.redactor)) == 0 {
+			// Not a note this cnotes can read, or nothing to redact
+			return nil, nil
+		}
+		return nm.marshalNote(note)
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer rewriter.close()
+
+	rewritten := 0
+	for _, commit := range history {
+		newCommit, err := rewriter.commit(ctx, commit)
+		if err != nil {
+			return 0, err
+		}
+		if newCommit != commit {
+			rewritten++
+		}
+	}
+
+	oldTip := history[len(history)-1]
+	newTip := rewriter.commits[oldTip]
+	if newTip == oldTip {
+		return 0, nil
+	}
+
+	if _, err := nm.git.Execute(ctx, nm.workDir, "update-ref", "-m", "cnotes: redact secrets from history", ref, newTip, oldTip); err != nil {
+		return 0, fmt.Errorf("failed to update %s: %w", ref, err)
+	}
+
+	return rewritten, nil
+}
+
+// historyRewriter rewrites the objects of a notes ref's history, remembering
+// what each old object became
+type historyRewriter struct {
+	nm        *NotesManager
+	transform noteTransform
+	objects   ObjectReader      // Reads the old objects, without a git process for each
+	blobs     map[string]string // By path and blob
+	trees     map[string]string // By path and tree
+	commits   map[string]string
+}
+
+// noteTransform rewrites the note of a commit, returning nil to leave it as
+// it is
+type noteTransform func(commit string, data []byte) ([]byte, error)
+
+// newHistoryRewriter returns a historyRewriter that rewrites notes with
+// transform. It must be closed when done.
+func newHistoryRewriter(ctx context.Context, nm *NotesManager, transform noteTransform) (*historyRewriter, error) {
+	objects, err := nm.git.ReadObjects(ctx, nm.workDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notes objects: %w", err)
+	}
+
+	return &historyRewriter{
+		nm:        nm,
+		transform: transform,
+		objects:   objects,
+		blobs:     make(map[string]string),
+		trees:     make(map[string]string),
+		commits:   make(map[string]string),
+	}, nil
+}
+
+// close stops reading objects. A failed read has already been reported.
+func (r *historyRewriter) close() {
+	r.objects.Close()
+}
+
+// read returns the contents of an object, which must be of the given type
+func (r *historyRewriter) read(object, objectType string) ([]byte, error) {
+	actualType, data, err := r.objects.ReadObject(object)
+	if err != nil {
+		return nil, err
+	}
+	if actualType != objectType {
+		return nil, fmt.Errorf("%s is a %s, not a %s", object, actualType, objectType)
+	}
+	return data, nil
+}
+
+// commit rewrites a notes commit onto its rewritten tree and parents
+func (r *historyRewriter) commit(ctx context.Context, commit string) (string, error) {
+	raw, err := r.read(commit, "commit")
+	if err != nil {
+		return "", fmt.Errorf("failed to read notes commit %s: %w", commit, err)
+	}
+
+	header, message, _ := strings.Cut(string(raw), "\n\n")
+	var lines []string
+	changed := false
+	for _, line := range strings.Split(header, "\n") {
+		if tree, ok := strings.CutPrefix(line, "tree "); ok {
+			newTree, err := r.tree(ctx, tree, "")
+			if err != nil {
+				return "", err
+			}
+			changed = changed || newTree != tree
+			line = "tree " + newTree
+		} else if parent, ok := strings.CutPrefix(line, "parent "); ok {
+			if newParent, ok := r.commits[parent]; ok {
+				changed = changed || newParent != parent
+				line = "parent " + newParent
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	if !changed {
+		r.commits[commit] = commit
+		return commit, nil
+	}
+
+	data := strings.Join(lines, "\n") + "\n\n" + message
+	newCommit, err := r.write(ctx, "commit", []byte(data))
+	if err != nil {
+		return "", err
+	}
+	r.commits[commit] = newCommit
+	return newCommit, nil
+}
+
+// tree rewrites a notes tree, whose paths spell the annotated commits' hashes
+// split into fan-out directories
+func (r *historyRewriter) tree(ctx context.Context, tree, prefix string) (string, error) {
+	// Which notes get redacted depends on their paths, so the same tree at
+	// another path may be rewritten differently
+	key := prefix + ":" + tree
+	if newTree, ok := r.trees[key]; ok {
+		return newTree, nil
+	}
+
+	data, err := r.read(tree, "tree")
+	if err != nil {
+		return "", fmt.Errorf("failed to read notes tree %s: %w", tree, err)
+	}
+	entries, err := parseTree(data, len(tree)/2)
+	if err != nil {
+		return "", fmt.Errorf("failed to read notes tree %s: %w", tree, err)
+	}
+
+	var lines []string
+	changed := false
+	for _, entry := range entries {
+		var newObject string
+		switch entry.objectType {
+		case "tree":
+			newObject, err = r.tree(ctx, entry.object, prefix+entry.name)
+		case "blob":
+			newObject, err = r.blob(ctx, entry.object, prefix+entry.name)
+		default:
+			newObject = entry.object
+		}
+		if err != nil {
+			return "", err
+		}
+
+		changed = changed || newObject != entry.object
+		lines = append(lines, fmt.Sprintf("%s %s %s\t%s", entry.mode, entry.objectType, newObject, entry.name))
+	}
+
+	newTree := tree
+	if changed {
+		output, err := r.nm.git.ExecuteWithInput(ctx, r.nm.workDir, []byte(strings.Join(lines, "\n")+"\n"), "mktree")
+		if err != nil {
+			return "", fmt.Errorf("failed to write notes tree: %w", err)
+		}
+		newTree = strings.TrimSpace(string(output))
+	}
+	r.trees[key] = newTree
+	return newTree, nil
+}
+
+// blob rewrites the note of the commit a notes tree path names
+func (r *historyRewriter) blob(ctx context.Context, blob, path string) (string, error) {
+	// A note that didn't change shares its blob with earlier notes commits
+	key := path + ":" + blob
+	if newBlob, ok := r.blobs[key]; ok {
+		return newBlob, nil
+	}
+
+	data, err := r.read(blob, "blob")
+	if err != nil {
+		return "", fmt.Errorf("failed to read note %s: %w", blob, err)
+	}
+
+	commit := strings.ReplaceAll(path, "/", "")
+	rewritten, err := r.transform(commit, data)
+	if err != nil {
+		return "", fmt.Errorf("note for %s: %w", commit, err)
+	}
+	if rewritten == nil {
+		r.blobs[key] = blob
+		return blob, nil
+	}
+
+	newBlob, err := r.write(ctx, "blob", append(rewritten, '\n'))
+	if err != nil {
+		return "", err
+	}
+	r.blobs[key] = newBlob
+	return newBlob, nil
+}
+
+// write stores an object in the repository and returns its hash
+func (r *historyRewriter) write(ctx context.Context, objectType string, data []byte) (string, error) {
+	output, err := r.nm.git.ExecuteWithInput(ctx, r.nm.workDir, data, "hash-object", "-t", objectType, "-w", "--stdin")
+	if err != nil {
+		return "", fmt.Errorf("failed to write notes %s: %w", objectType, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// treeEntry is an entry of a tree object
+type treeEntry struct {
+	mode       string
+	objectType string
+	object     string
+	name       string
+}
+
+// parseTree parses the contents of a tree object, whose entries hold object
+// hashes of hashSize bytes
+func parseTree(data []byte, hashSize int) ([]treeEntry, error) {
+	var entries []treeEntry
+	for len(data) > 0 {
+		// Format is: <mode> SP <name> NUL <hash>, with the hash in binary
+		header, rest, ok := bytes.Cut(data, []byte{0})
+		mode, name, hasName := strings.Cut(string(header), " ")
+		if !ok || !hasName || len(rest) < hashSize {
+			return nil, errors.New("malformed tree object")
+		}
+
+		objectType := "blob"
+		switch mode {
+		case "40000":
+			objectType = "tree"
+		case "160000":
+			objectType = "commit" // A submodule
+		}
+
+		entries = append(entries, treeEntry{
+			mode:       mode,
+			objectType: objectType,
+			object:     hex.EncodeToString(rest[:hashSize]),
+			name:       name,
+		})
+		data = rest[hashSize:]
+	}
+	return entries, nil
+}
@@ -0,0 +1,213 @@
+package notes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/imjasonh/cnotes/internal/redact"
+)
+
+func TestScanNote(t *testing.T) {
+	note := ConversationNote{
+		Entries: []SessionEntry{{
+			ConversationExcerpt: "👤 User: use password: hunter2",
+			Events: []Event{
+				{Role: RoleUser, Text: "use password: hunter2"},
+				{Role: RoleTool, Tool: "Bash", Input: "env", Result: "TOKEN=s3cr3t"},
+			},
+			CommitContext: "Git command: git commit -m clean",
+			Subagents:     []Subagent{{Result: "found TOKEN=abc123"}},
+		}},
+		Amendments: []Amendment{{SessionEntry: SessionEntry{
+			ConversationExcerpt: "already password: [REDACTED]",
+			Subagents:           []Subagent{{Prompt: "log in with password=swordfish"}},
+		}}},
+	}
+
+	findings := ScanNote("abc123", note, redact.New(redact.KeywordRules([]string{"password", "token"}), nil))
+
+	var got []string
+	for _, finding := range findings {
+		if finding.Commit != "abc123" {
+			t.Errorf("unexpected commit %q", finding.Commit)
+		}
+		got = append(got, finding.Field+" "+finding.RuleID+" "+finding.Secret)
+	}
+
+	expected := []string{
+		"entries[0].conversation_excerpt keyword:password hunter2",
+		"entries[0].events[0].text keyword:password hunter2",
+		"entries[0].events[1].result keyword:token s3cr3t",
+		"entries[0].subagents[0].result keyword:token abc123",
+		"amendments[0].subagents[0].prompt keyword:password swordfish",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected findings:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestRedactNote(t *testing.T) {
+	ctx := context.Background()
+	mockGit := NewMockGitExecutor()
+	nm := NewNotesManagerWithExecutor("/test/dir", mockGit)
+	nm.SetRedactor(redact.New(redact.KeywordRules([]string{"password"}), nil))
+
+	note := func(excerpt string) ConversationNote {
+		return ConversationNote{
+			SchemaVersion: CurrentSchemaVersion,
+			SessionID:     "session-1",
+			Entries:       []SessionEntry{{SessionID: "session-1", ConversationExcerpt: excerpt}},
+		}
+	}
+	secret, _ := json.Marshal(note("password: hunter2"))
+	clean, _ := json.Marshal(note("nothing to see"))
+	mockGit.SetResponse([]string{"notes", "--ref", "claude-conversations", "show", "secret"}, secret, nil)
+	mockGit.SetResponse([]string{"notes", "--ref", "claude-conversations", "show", "clean"}, clean, nil)
+
+	redactedNote := note("password: [REDACTED]")
+	redactedNote.Redactions = map[string]int{"keyword:password": 1}
+	redacted, _ := json.MarshalIndent(redactedNote, "", "  ")
+	mockGit.SetInputResponse([]string{"notes", "--ref", "claude-conversations", "add", "-f", "-F", "-", "secret"}, redacted, nil, nil)
+
+	ok, err := nm.RedactNote(ctx, "secret")
+	if err != nil {
+		t.Fatalf("failed to redact note: %v", err)
+	}
+	if !ok {
+		t.Error("expected the note with a secret to be rewritten")
+	}
+
+	ok, err = nm.RedactNote(ctx, "clean")
+	if err != nil {
+		t.Fatalf("failed to redact note: %v", err)
+	}
+	if ok {
+		t.Error("expected the clean note to be left alone")
+	}
+	for _, cmd := range mockGit.GetExecutedCommands() {
+		if len(cmd.args) > 3 && cmd.args[3] == "add" && cmd.args[len(cmd.args)-1] == "clean" {
+			t.Error("clean note was rewritten")
+		}
+	}
+}
+
+func TestRewriteNotesHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		output, err := (&RealGitExecutor{}).Execute(ctx, dir, args...)
+		if err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	git("init", "-q")
+	git("config", "user.name", "Test")
+	git("config", "user.email", "test@example.com")
+	var commits []string
+	for _, message := range []string{"first", "second"} {
+		git("commit", "-q", "--allow-empty", "-m", message)
+		commits = append(commits, git("rev-parse", "HEAD"))
+	}
+
+	nm := NewNotesManager(dir)
+	nm.SetRedactor(redact.New(redact.KeywordRules([]string{"password"}), nil))
+
+	// The secret is written to the first commit's note, which is later replaced
+	for _, note := range []struct {
+		commit  string
+		excerpt string
+	}{
+		{commits[0], "password: hunter2"},
+		{commits[1], "password: swordfish"},
+		{commits[0], "nothing to see"},
+	} {
+		// Written before session entries
+		data, _ := json.Marshal(map[string]string{"session_id": "session-1", "conversation_excerpt": note.excerpt})
+		git("notes", "--ref", "claude-conversations", "add", "-f", "-m", string(data), note.commit)
+	}
+	messages := git("log", "--format=%an %ad %s", "refs/notes/claude-conversations")
+
+	t.Run("only the given commits", func(t *testing.T) {
+		rewritten, err := nm.RewriteNotesHistory(ctx, []string{commits[1]})
+		if err != nil {
+			t.Fatalf("failed to rewrite history: %v", err)
+		}
+		if rewritten != 2 {
+			t.Errorf("expected the last 2 notes commits to be rewritten, got %d", rewritten)
+		}
+		history := git("log", "-p", "refs/notes/claude-conversations")
+		if strings.Contains(history, "swordfish") || !strings.Contains(history, "hunter2") {
+			t.Errorf("expected only the second commit's notes to be redacted:\n%s", history)
+		}
+	})
+
+	t.Run("every commit", func(t *testing.T) {
+		rewritten, err := nm.RewriteNotesHistory(ctx, nil)
+		if err != nil {
+			t.Fatalf("failed to rewrite history: %v", err)
+		}
+		if rewritten != 3 {
+			t.Errorf("expected 3 notes commits to be rewritten, got %d", rewritten)
+		}
+
+		history := git("log", "-p", "refs/notes/claude-conversations")
+		if strings.Contains(history, "hunter2") || strings.Contains(history, "swordfish") {
+			t.Errorf("secret left in notes history:\n%s", history)
+		}
+		if got := git("log", "--format=%an %ad %s", "refs/notes/claude-conversations"); got != messages {
+			t.Errorf("expected authors, dates and messages to be kept, got:\n%s\nwant:\n%s", got, messages)
+		}
+
+		note, err := nm.GetConversationNote(ctx, commits[0])
+		if err != nil || note == nil || len(note.Entries) != 1 || note.Entries[0].ConversationExcerpt != "nothing to see" {
+			t.Errorf("expected the current note to be kept, got %+v, %v", note, err)
+		}
+	})
+
+	t.Run("nothing left to redact", func(t *testing.T) {
+		rewritten, err := nm.RewriteNotesHistory(ctx, nil)
+		if err != nil {
+			t.Fatalf("failed to rewrite history: %v", err)
+		}
+		if rewritten != 0 {
+			t.Errorf("expected nothing to be rewritten, got %d", rewritten)
+		}
+	})
+}
+
+func TestParseTree(t *testing.T) {
+	hash := func(b byte) []byte { return bytes.Repeat([]byte{b}, 20) }
+	var data []byte
+	data = append(append(data, "40000 ab\x00"...), hash(0xab)...)
+	data = append(append(data, "100644 cdef\x00"...), hash(0x01)...)
+	data = append(append(data, "160000 module\x00"...), hash(0xff)...)
+
+	entries, err := parseTree(data, 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []treeEntry{
+		{mode: "40000", objectType: "tree", object: strings.Repeat("ab", 20), name: "ab"},
+		{mode: "100644", objectType: "blob", object: strings.Repeat("01", 20), name: "cdef"},
+		{mode: "160000", objectType: "commit", object: strings.Repeat("ff", 20), name: "module"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("expected %+v, got %+v", want, entries)
+	}
+
+	if _, err := parseTree(data[:len(data)-1], 20); err == nil {
+		t.Error("expected a truncated tree to fail")
+	}
+}
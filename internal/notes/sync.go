@@ -0,0 +1,253 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrPushRejected is returned by PushNotes when the remote has notes the local
+// notes don't include yet, which FetchNotes merges in
+var ErrPushRejected = errors.New("the remote has notes that haven't been fetched")
+
+// FetchResult tells what fetching a remote's notes did to the local notes
+type FetchResult struct {
+	Found   bool // Whether the remote has notes at all
+	Updated bool // Whether the local notes ref changed
+	Merged  int  // Notes both sides changed, merged entry by entry
+	Skipped int  // Notes both sides changed that couldn't be read, kept as they were locally
+}
+
+// RemoteNotesRef returns the ref a remote's notes are fetched into before
+// they're merged, e.g. refs/notes/remotes/origin/claude-conversations
+func (nm *NotesManager) RemoteNotesRef(remote string) string {
+	return "refs/notes/remotes/" + remote + "/" + strings.TrimPrefix(nm.FullNotesRef(), "refs/notes/")
+}
+
+// FetchNotes fetches a remote's notes and merges them into the local notes.
+// A note only one side changed is taken from that side, like git does. A
+// note both sides changed, such as when two people's sessions contributed to
+// the same commit, is merged by combining the session entries of the two,
+// rather than by git's text strategies, which would keep only one side or
+// concatenate their JSON.
+func (nm *NotesManager) FetchNotes(ctx context.Context, remote string) (FetchResult, error) {
+	var result FetchResult
+	ref := nm.FullNotesRef()
+	tracking := nm.RemoteNotesRef(remote)
+
+	// Fetching a ref the remote doesn't have fails, so look for it first
+	output, err := nm.git.Execute(ctx, nm.workDir, "ls-remote", remote, ref)
+	if err != nil {
+		return result, fmt.Errorf("failed to list the notes of %s: %w", remote, err)
+	}
+	if strings.TrimSpace(string(output)) == "" {
+		return result, nil
+	}
+	result.Found = true
+
+	if _, err := nm.git.Execute(ctx, nm.workDir, "fetch", "-q", remote, "+"+ref+":"+tracking); err != nil {
+		return result, fmt.Errorf("failed to fetch notes from %s: %w", remote, err)
+	}
+
+	local, err := nm.resolveNotesRef(ctx, ref)
+	if err != nil {
+		return result, err
+	}
+	theirs, err := nm.resolveNotesRef(ctx, tracking)
+	if err != nil {
+		return result, err
+	}
+	if local == theirs {
+		return result, nil
+	}
+
+	var base string
+	if local != "" {
+		if base, err = nm.mergeBase(ctx, local, theirs); err != nil {
+			return result, err
+		}
+	}
+	if base == theirs {
+		// The local notes already include the remote's
+		return result, nil
+	}
+
+	// Notes both sides changed are only there to merge if the local notes
+	// have changes of their own; otherwise git fast-forwards
+	var conflicts map[string]string
+	if local != "" && base != local {
+		if conflicts, err = nm.conflictingNotes(ctx, base, local, theirs); err != nil {
+			return result, err
+		}
+	}
+
+	// Keep the local version of notes both sides changed, to merge below
+	if _, err := nm.git.Execute(ctx, nm.workDir, "notes", "--ref", ref, "merge", "-q", "-s", "ours", tracking); err != nil {
+		return result, fmt.Errorf("failed to merge notes from %s: %w", remote, err)
+	}
+	result.Updated = true
+
+	result.Merged, result.Skipped, err = nm.mergeConflictingNotes(ctx, conflicts)
+	return result, err
+}
+
+// PushNotes pushes the local notes to a remote. It reports false if there are
+// no local notes to push. If the remote has notes the local notes don't
+// include, the error matches ErrPushRejected; fetching them merges them in.
+func (nm *NotesManager) PushNotes(ctx context.Context, remote string) (bool, error) {
+	ref := nm.FullNotesRef()
+	local, err := nm.resolveNotesRef(ctx, ref)
+	if err != nil || local == "" {
+		return false, err
+	}
+
+	if _, err := nm.git.Execute(ctx, nm.workDir, "push", "-q", remote, ref+":"+ref); err != nil {
+		var gitErr *GitError
+		if errors.As(err, &gitErr) && strings.Contains(gitErr.Stderr, "[rejected]") {
+			return false, fmt.Errorf("failed to push notes to %s: %w", remote, ErrPushRejected)
+		}
+		return false, fmt.Errorf("failed to push notes to %s: %w", remote, err)
+	}
+
+	return true, nil
+}
+
+// resolveNotesRef returns the commit a notes ref points to, or "" if there is
+// no such ref
+func (nm *NotesManager) resolveNotesRef(ctx context.Context, ref string) (string, error) {
+	commit, err := nm.ResolveCommit(ctx, ref)
+	if errors.Is(err, ErrUnknownRevision) {
+		return "", nil
+	}
+	return commit, err
+}
+
+// mergeBase returns the best common ancestor of two commits, or "" if they
+// have no history in common
+func (nm *NotesManager) mergeBase(ctx context.Context, a, b string) (string, error) {
+	output, err := nm.git.Execute(ctx, nm.workDir, "merge-base", a, b)
+	if errors.Is(err, ErrUnknownRevision) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find the merge base of %s and %s: %w", a, b, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// conflictingNotes returns the remote's version of each note that both the
+// local and the remote notes changed since base, by commit
+func (nm *NotesManager) conflictingNotes(ctx context.Context, base, local, theirs string) (map[string]string, error) {
+	trees := make([]map[string]string, 3)
+	for i, rev := range []string{base, local, theirs} {
+		blobs, err := nm.noteBlobs(ctx, rev)
+		if err != nil {
+			return nil, err
+		}
+		trees[i] = blobs
+	}
+	baseBlobs, localBlobs, theirBlobs := trees[0], trees[1], trees[2]
+
+	conflicts := make(map[string]string)
+	for commit, blob := range theirBlobs {
+		// A note one side removed is left to git's merge
+		mine, ok := localBlobs[commit]
+		if ok && mine != blob && mine != baseBlobs[commit] && blob != baseBlobs[commit] {
+			conflicts[commit] = blob
+		}
+	}
+	return conflicts, nil
+}
+
+// noteBlobs returns the note blob of each commit annotated in a notes commit,
+// or nothing if rev is ""
+func (nm *NotesManager) noteBlobs(ctx context.Context, rev string) (map[string]string, error) {
+	blobs := make(map[string]string)
+	if rev == "" {
+		return blobs, nil
+	}
+
+	output, err := nm.git.Execute(ctx, nm.workDir, "ls-tree", "-r", rev)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notes in %s: %w", rev, err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		// Format is: <mode> SP <type> SP <object> TAB <path>, where the path
+		// is the annotated commit, maybe split into directories like ab/cdef
+		info, path, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(info)
+		if !ok || len(fields) != 3 || fields[1] != "blob" {
+			continue
+		}
+		if commit := strings.ReplaceAll(path, "/", ""); isHexHash(commit) {
+			blobs[commit] = fields[2]
+		}
+	}
+	return blobs, nil
+}
+
+// mergeConflictingNotes merges the remote's version of each note both sides
+// changed, by commit, into the local one. A note either side can't read is
+// left as it is locally. It returns how many notes were merged and skipped.
+func (nm *NotesManager) mergeConflictingNotes(ctx context.Context, conflicts map[string]string) (int, int, error) {
+	if len(conflicts) == 0 {
+		return 0, 0, nil
+	}
+
+	commits := make([]string, 0, len(conflicts))
+	for commit := range conflicts {
+		commits = append(commits, commit)
+	}
+	sort.Strings(commits)
+
+	// Read all of the remote's notes before writing any
+	reader, err := nm.git.ReadObjects(ctx, nm.workDir)
+	if err != nil {
+		return 0, 0, err
+	}
+	theirNotes := make(map[string]ConversationNote)
+	skipped := 0
+	for _, commit := range commits {
+		_, data, err := reader.ReadObject(conflicts[commit])
+		if err != nil {
+			reader.Close()
+			return 0, 0, fmt.Errorf("failed to read the remote's note for %s: %w", commit, err)
+		}
+		note, _, err := decodeNote(data)
+		if errors.Is(err, ErrNewerSchema) || errors.Is(err, errInvalidNote) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			reader.Close()
+			return 0, 0, fmt.Errorf("failed to decode the remote's note for %s: %w", commit, err)
+		}
+		theirNotes[commit] = note
+	}
+	if err := reader.Close(); err != nil {
+		return 0, 0, err
+	}
+
+	merged := 0
+	for _, commit := range commits {
+		note, ok := theirNotes[commit]
+		if !ok {
+			continue
+		}
+		written, err := nm.addConversationNote(ctx, commit, note)
+		if errors.Is(err, ErrNewerSchema) || errors.Is(err, errInvalidNote) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			return merged, skipped, fmt.Errorf("failed to merge note for %s: %w", commit, err)
+		}
+		if written {
+			merged++
+		}
+	}
+
+	return merged, skipped, nil
+}
@@ -0,0 +1,173 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncNotes(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	ctx := context.Background()
+	root := t.TempDir()
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		output, err := (&RealGitExecutor{}).Execute(ctx, dir, args...)
+		if err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	remote := filepath.Join(root, "remote.git")
+	git(root, "init", "-q", "--bare", remote)
+	clone := func(name string) (string, *NotesManager) {
+		dir := filepath.Join(root, name)
+		git(root, "clone", "-q", remote, dir)
+		git(dir, "config", "user.name", "Test")
+		git(dir, "config", "user.email", "test@example.com")
+		return dir, NewNotesManager(dir)
+	}
+	entry := func(session string, minutes int) ConversationNote {
+		var note ConversationNote
+		note.AddEntry(SessionEntry{
+			SessionID:           session,
+			Timestamp:           time.Date(2023, 1, 1, 12, minutes, 0, 0, time.UTC),
+			ConversationExcerpt: "Conversation of " + session,
+		})
+		return note
+	}
+	sessions := func(nm *NotesManager, commit string) string {
+		t.Helper()
+		note, err := nm.GetConversationNote(ctx, commit)
+		if err != nil || note == nil {
+			t.Fatalf("expected a note for %s, got %v", commit, err)
+		}
+		var ids []string
+		for _, entry := range note.Entries {
+			ids = append(ids, entry.SessionID)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	alice, aliceNotes := clone("alice")
+	git(alice, "commit", "-q", "--allow-empty", "-m", "Shared commit")
+	shared := git(alice, "rev-parse", "HEAD")
+	git(alice, "push", "-q", "origin", "HEAD")
+	bob, bobNotes := clone("bob")
+
+	t.Run("remote without notes", func(t *testing.T) {
+		result, err := bobNotes.FetchNotes(ctx, "origin")
+		if err != nil {
+			t.Fatalf("failed to fetch notes: %v", err)
+		}
+		if result.Found || result.Updated {
+			t.Errorf("expected nothing to fetch, got %+v", result)
+		}
+		if pushed, err := bobNotes.PushNotes(ctx, "origin"); pushed || err != nil {
+			t.Errorf("expected nothing to push, got %v, %v", pushed, err)
+		}
+	})
+
+	t.Run("fast-forward", func(t *testing.T) {
+		if err := aliceNotes.AddConversationNote(ctx, shared, entry("session-1", 0)); err != nil {
+			t.Fatalf("failed to add note: %v", err)
+		}
+		if pushed, err := aliceNotes.PushNotes(ctx, "origin"); !pushed || err != nil {
+			t.Fatalf("expected the notes pushed, got %v, %v", pushed, err)
+		}
+
+		result, err := bobNotes.FetchNotes(ctx, "origin")
+		if err != nil {
+			t.Fatalf("failed to fetch notes: %v", err)
+		}
+		if !result.Found || !result.Updated || result.Merged != 0 {
+			t.Errorf("expected a fast-forward, got %+v", result)
+		}
+		if got := sessions(bobNotes, shared); got != "session-1" {
+			t.Errorf("expected alice's note, got entries %s", got)
+		}
+
+		result, err = bobNotes.FetchNotes(ctx, "origin")
+		if err != nil || result.Updated {
+			t.Errorf("expected the notes up to date, got %+v, %v", result, err)
+		}
+	})
+
+	t.Run("diverged", func(t *testing.T) {
+		if err := aliceNotes.AddConversationNote(ctx, shared, entry("session-2", 10)); err != nil {
+			t.Fatalf("failed to add note: %v", err)
+		}
+		if _, err := aliceNotes.PushNotes(ctx, "origin"); err != nil {
+			t.Fatalf("failed to push notes: %v", err)
+		}
+
+		// Bob annotates the same commit in another session, and one only he has
+		if err := bobNotes.AddConversationNote(ctx, shared, entry("session-3", 20)); err != nil {
+			t.Fatalf("failed to add note: %v", err)
+		}
+		git(bob, "commit", "-q", "--allow-empty", "-m", "Bob's commit")
+		own := git(bob, "rev-parse", "HEAD")
+		if err := bobNotes.AddConversationNote(ctx, own, entry("session-4", 30)); err != nil {
+			t.Fatalf("failed to add note: %v", err)
+		}
+
+		if _, err := bobNotes.PushNotes(ctx, "origin"); !errors.Is(err, ErrPushRejected) {
+			t.Fatalf("expected the push rejected, got %v", err)
+		}
+
+		result, err := bobNotes.FetchNotes(ctx, "origin")
+		if err != nil {
+			t.Fatalf("failed to fetch notes: %v", err)
+		}
+		if !result.Updated || result.Merged != 1 || result.Skipped != 0 {
+			t.Errorf("expected one note merged, got %+v", result)
+		}
+		// Bob's entries come first, then those only the remote had
+		if got := sessions(bobNotes, shared); got != "session-1,session-3,session-2" {
+			t.Errorf("expected both sides' entries, got %s", got)
+		}
+		if got := sessions(bobNotes, own); got != "session-4" {
+			t.Errorf("expected bob's own note kept, got %s", got)
+		}
+
+		if _, err := bobNotes.PushNotes(ctx, "origin"); err != nil {
+			t.Fatalf("failed to push the merged notes: %v", err)
+		}
+		result, err = aliceNotes.FetchNotes(ctx, "origin")
+		if err != nil || !result.Updated || result.Merged != 0 {
+			t.Errorf("expected alice to fast-forward, got %+v, %v", result, err)
+		}
+		if got := sessions(aliceNotes, shared); got != "session-1,session-3,session-2" {
+			t.Errorf("expected the merged note, got %s", got)
+		}
+	})
+
+	t.Run("unreadable note", func(t *testing.T) {
+		git(alice, "notes", "--ref", "claude-conversations", "add", "-f", "-m", "just text", shared)
+		if _, err := aliceNotes.PushNotes(ctx, "origin"); err != nil {
+			t.Fatalf("failed to push notes: %v", err)
+		}
+		if err := bobNotes.AddConversationNote(ctx, shared, entry("session-5", 40)); err != nil {
+			t.Fatalf("failed to add note: %v", err)
+		}
+
+		result, err := bobNotes.FetchNotes(ctx, "origin")
+		if err != nil {
+			t.Fatalf("failed to fetch notes: %v", err)
+		}
+		if result.Merged != 0 || result.Skipped != 1 {
+			t.Errorf("expected the note skipped, got %+v", result)
+		}
+		if got := sessions(bobNotes, shared); !strings.HasSuffix(got, "session-5") {
+			t.Errorf("expected bob's note kept, got %s", got)
+		}
+	})
+}
@@ -0,0 +1,149 @@
+// Package redact removes secrets from conversation text before cnotes stores
+// it in a git note
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Placeholder replaces each redacted secret
+const Placeholder = "[REDACTED]"
+
+// Finding is a secret a rule detected in some text
+type Finding struct {
+	RuleID string
+	Start  int // Byte offsets of the secret in the text
+	End    int
+}
+
+// Redactor replaces secrets in text with Placeholder
+type Redactor struct {
+	rules     []Rule
+	allowlist []*regexp.Regexp
+}
+
+// New returns a redactor that detects secrets with the given rules, ignoring
+// any that fall within a match of one of the allowlist patterns
+func New(rules []Rule, allowlist []*regexp.Regexp) *Redactor {
+	return &Redactor{rules: rules, allowlist: allowlist}
+}
+
+// Rules returns the IDs of the redactor's rules, in the order they apply
+func (r *Redactor) Rules() []string {
+	var ids []string
+	for _, rule := range r.rules {
+		ids = append(ids, rule.ID)
+	}
+	return ids
+}
+
+// Only returns a redactor with just the rules with the given IDs and the same
+// allowlist, or an error naming an ID none of the rules have
+func (r *Redactor) Only(ids []string) (*Redactor, error) {
+	var rules []Rule
+	for _, id := range ids {
+		found := false
+		for _, rule := range r.rules {
+			if rule.ID == id {
+				rules = append(rules, rule)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown rule %q; rules are %s", id, strings.Join(r.Rules(), ", "))
+		}
+	}
+	return New(rules, r.allowlist), nil
+}
+
+// With returns a redactor with more rules after the redactor's own
+func (r *Redactor) With(rules ...Rule) *Redactor {
+	return New(append(append([]Rule(nil), r.rules...), rules...), r.allowlist)
+}
+
+// Find returns the secrets in text, in order. Where rules overlap, the
+// findings are merged and credited to the rule whose match starts first,
+// or the earlier rule if they start together.
+func (r *Redactor) Find(text string) []Finding {
+	if text == "" {
+		return nil
+	}
+
+	var allowed [][]int
+	for _, pattern := range r.allowlist {
+		allowed = append(allowed, pattern.FindAllStringIndex(text, -1)...)
+	}
+
+	var candidates []Finding
+	for _, rule := range r.rules {
+		for _, span := range rule.find(text) {
+			secret := text[span[0]:span[1]]
+			if strings.Contains(secret, Placeholder) || within(span, allowed) {
+				continue
+			}
+			candidates = append(candidates, Finding{RuleID: rule.ID, Start: span[0], End: span[1]})
+		}
+	}
+
+	// Stable, so rules listed first win ties
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Start < candidates[j].Start
+	})
+
+	var findings []Finding
+	for _, candidate := range candidates {
+		if n := len(findings); n > 0 && candidate.Start < findings[n-1].End {
+			findings[n-1].End = max(findings[n-1].End, candidate.End)
+			continue
+		}
+		findings = append(findings, candidate)
+	}
+
+	return findings
+}
+
+// Redact returns text with every secret replaced by Placeholder
+func (r *Redactor) Redact(text string) string {
+	redacted, _ := r.RedactFindings(text)
+	return redacted
+}
+
+// RedactFindings returns text with every secret replaced by Placeholder, and
+// the secrets that were replaced
+func (r *Redactor) RedactFindings(text string) (string, []Finding) {
+	findings := r.Find(text)
+	if len(findings) == 0 {
+		return text, nil
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, finding := range findings {
+		b.WriteString(text[last:finding.Start])
+		b.WriteString(Placeholder)
+		last = finding.End
+	}
+	b.WriteString(text[last:])
+
+	return b.String(), findings
+}
+
+// Count adds how many secrets each rule found to counts
+func Count(counts map[string]int, findings []Finding) {
+	for _, finding := range findings {
+		counts[finding.RuleID]++
+	}
+}
+
+// within reports whether a span lies inside one of the allowed spans
+func within(span []int, allowed [][]int) bool {
+	for _, a := range allowed {
+		if span[0] >= a[0] && span[1] <= a[1] {
+			return true
+		}
+	}
+	return false
+}